@@ -0,0 +1,72 @@
+// Command orvalho-bindgen generates host glue and TypeScript declarations
+// from a contract file. It is meant to be run from go:generate:
+//
+//	//go:generate go run orvalho/cmd/orvalho-bindgen -go kv_gen.go -ts kv.d.ts kv.xml
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"orvalho/pkg/contract"
+)
+
+func main() {
+	goOut := flag.String("go", "", "write the Go host glue to this `file`")
+	pkg := flag.String("package", "", "package `name` of the Go file (defaults to $GOPACKAGE, then the output directory name)")
+	tsOut := flag.String("ts", "", "write TypeScript declarations to this `file`")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: orvalho-bindgen [-go file] [-package name] [-ts file] contract.xml\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 || (*goOut == "" && *tsOut == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *goOut, *pkg, *tsOut); err != nil {
+		fmt.Fprintln(os.Stderr, "orvalho-bindgen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(input, goOut, pkg, tsOut string) error {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	c, err := contract.Parse(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", input, err)
+	}
+	source := filepath.Base(input)
+
+	if goOut != "" {
+		if pkg == "" {
+			pkg = os.Getenv("GOPACKAGE")
+		}
+		if pkg == "" {
+			abs, err := filepath.Abs(goOut)
+			if err != nil {
+				return err
+			}
+			pkg = filepath.Base(filepath.Dir(abs))
+		}
+		code, err := contract.GenerateGo(c, contract.GoOptions{Package: pkg, Source: source})
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(goOut, code, 0o644); err != nil {
+			return err
+		}
+	}
+	if tsOut != "" {
+		if err := os.WriteFile(tsOut, contract.GenerateTS(c, source), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
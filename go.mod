@@ -3,16 +3,22 @@ module orvalho
 go 1.24.3
 
 require (
-	filippo.io/age v1.3.1 // indirect
+	filippo.io/age v1.3.1
+	github.com/btcsuite/btcd/btcutil v1.1.6
+	github.com/dop251/goja v0.0.0-20251201205617-2bb4c724c0f9
+	github.com/evanw/esbuild v0.25.12
+	github.com/stellar/go v0.0.0-20251023205731-8cd5ab33bcdd
+	github.com/tetratelabs/wazero v1.11.0
+	github.com/tyler-smith/go-bip39 v1.1.0
+	go.etcd.io/bbolt v1.4.3
+	golang.org/x/crypto v0.46.0
+)
+
+require (
 	filippo.io/hpke v0.4.0 // indirect
-	github.com/btcsuite/btcd/btcutil v1.1.6 // indirect
 	github.com/dlclark/regexp2 v1.11.4 // indirect
-	github.com/dop251/goja v0.0.0-20251201205617-2bb4c724c0f9 // indirect
 	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
 	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
-	github.com/stellar/go v0.0.0-20251023205731-8cd5ab33bcdd // indirect
-	github.com/tyler-smith/go-bip39 v1.1.0 // indirect
-	golang.org/x/crypto v0.46.0 // indirect
 	golang.org/x/sys v0.39.0 // indirect
 	golang.org/x/text v0.32.0 // indirect
 )
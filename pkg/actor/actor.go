@@ -1,6 +1,13 @@
 package actor
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrShutdown is returned by actors, and the Scheduler, once they were shut down.
+var ErrShutdown = errors.New("actor is shut down")
 
 // Actor defines the interface for a step-based actor.
 type Actor interface {
@@ -10,3 +17,26 @@ type Actor interface {
 	// It returns error if the execution failed.
 	Tick(ctx context.Context) (bool, error)
 }
+
+// Waiter is implemented by actors that know when they next need to run.
+// Drivers use it to sleep until the next deadline or external event instead of polling Tick.
+type Waiter interface {
+	Actor
+	// NextDeadline returns when the earliest scheduled work (e.g., a timer) is due.
+	// It returns false if nothing is scheduled.
+	NextDeadline() (time.Time, bool)
+	// Wake returns a channel that receives a value whenever an external event arrives for the actor.
+	Wake() <-chan struct{}
+}
+
+// Shutdowner is implemented by actors holding resources that must be released
+// when they stop, like timers, open files or engine memory.
+type Shutdowner interface {
+	Actor
+	// Shutdown stops the actor for good. A graceful shutdown first lets
+	// in-flight work finish, until ctx is done; otherwise it is abandoned.
+	// Either way the actor's resources are released before it returns, and
+	// later calls to Tick fail with ErrShutdown. Drivers stop ticking the
+	// actor before calling Shutdown.
+	Shutdown(ctx context.Context, graceful bool) error
+}
@@ -0,0 +1,137 @@
+// Package binding describes host functionality exposed to actors in a way
+// both runtimes understand, so a binding is written once and exposed
+// identically to JS and WASM actors. Bindings are usually generated from a
+// contract by orvalho-bindgen rather than written by hand.
+//
+// A JS actor sees a binding named "kv" as env.KV, with one method per
+// contract method in camelCase. Async methods return a Promise. A bytes
+// argument is an ArrayBuffer, typed array or DataView; anything else throws
+// a TypeError.
+//
+// A WASM actor imports each method from the module "orvalho:kv" under its
+// contract name, as
+//
+//	method(args_ptr i32, args_len i32, out_ptr i32) -> status i32
+//
+// where args is a JSON array of the arguments, bytes encoded as base64
+// strings. The host allocates the reply with the guest's alloc export and
+// stores its pointer and length as two little-endian u32 at out_ptr: the
+// JSON result when status is StatusOK, the error message when it is
+// StatusError. WASM calls are always synchronous.
+package binding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidArgument is returned by Args.Decode when an argument does not
+// fit the parameter it is decoded into.
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// Status codes returned by binding methods to WASM guests.
+const (
+	StatusOK    = 0
+	StatusError = 1
+)
+
+// Binding is a named set of host methods.
+type Binding struct {
+	// Name is the contract name, a snake_case identifier like "kv".
+	Name    string
+	Methods []Method
+	// Close, if set, releases what the methods hold, like open files or
+	// transactions, once the actor the binding was given to shuts down.
+	Close func() error
+}
+
+// Method is one host method of a binding.
+type Method struct {
+	// Name is the method name from the contract, e.g. "list_keys".
+	Name string
+	// Async methods return a Promise to JS actors and run off the event loop.
+	Async bool
+	// Call runs the method. It must be safe to call from any goroutine.
+	Call func(ctx context.Context, args Args) (any, error)
+}
+
+// EnvName is the property JS actors find the binding under, e.g. "KV".
+func (b Binding) EnvName() string {
+	return strings.ToUpper(b.Name)
+}
+
+// WASMModule is the module WASM actors import the binding's methods from.
+func (b Binding) WASMModule() string {
+	return "orvalho:" + b.Name
+}
+
+// JSName converts a snake_case method name to the camelCase name JS actors use.
+func JSName(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// Args are the arguments of a call: plain Go values from JS actors, []byte
+// for binary data and JSON-compatible values for everything else, or
+// json.RawMessage from WASM actors.
+type Args []any
+
+// Decode stores argument i in the value pointed to by v, converting it the
+// way encoding/json would. A missing argument decodes as null.
+//
+// Bytes must be passed as bytes: base64 strings are only accepted inside
+// raw JSON, as encoding/json encodes them, so a JS string is never taken
+// for binary data.
+func (a Args) Decode(i int, v any) error {
+	var arg any
+	if i < len(a) {
+		arg = a[i]
+	}
+	if p, ok := v.(*[]byte); ok {
+		switch x := arg.(type) {
+		case []byte:
+			*p = x
+			return nil
+		case nil, json.RawMessage:
+		default:
+			return fmt.Errorf("%w %d: expected bytes, got %T", ErrInvalidArgument, i, arg)
+		}
+	}
+
+	data, ok := arg.(json.RawMessage)
+	if !ok {
+		var err error
+		if data, err = json.Marshal(arg); err != nil {
+			return fmt.Errorf("%w %d: %w", ErrInvalidArgument, i, err)
+		}
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w %d: %w", ErrInvalidArgument, i, err)
+	}
+	return nil
+}
+
+// DecodeArgs splits a JSON array of arguments, leaving each one as raw
+// JSON until Decode knows what it decodes into.
+func DecodeArgs(data []byte) (Args, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("decoding arguments: %w", err)
+	}
+	args := make(Args, len(raw))
+	for i, arg := range raw {
+		args[i] = arg
+	}
+	return args, nil
+}
@@ -0,0 +1,51 @@
+package binding
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	args, err := DecodeArgs([]byte(`["key", 9007199254740993, "aGk=", {"n": 1}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var key string
+	var big int64
+	var data []byte
+	var record struct {
+		N int `json:"n"`
+	}
+	var missing *string
+	for i, v := range []any{&key, &big, &data, &record, &missing} {
+		if err := args.Decode(i, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if key != "key" || big != 9007199254740993 || string(data) != "hi" || record.N != 1 || missing != nil {
+		t.Errorf("unexpected arguments %q %d %q %+v %v", key, big, data, record, missing)
+	}
+
+	// Bytes from JS arrive as []byte rather than base64.
+	if err := (Args{[]byte("raw")}).Decode(0, &data); err != nil || string(data) != "raw" {
+		t.Errorf("unexpected bytes %q, %v", data, err)
+	}
+	if err := args.Decode(0, &big); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument decoding a string into a number, got %v", err)
+	}
+	// A string from JS is text, not base64.
+	if err := (Args{"aGk="}).Decode(0, &data); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument decoding a string into bytes, got %v", err)
+	}
+}
+
+func TestNames(t *testing.T) {
+	b := Binding{Name: "kv"}
+	if b.EnvName() != "KV" || b.WASMModule() != "orvalho:kv" {
+		t.Errorf("unexpected names %q %q", b.EnvName(), b.WASMModule())
+	}
+	if got := JSName("list_keys_by_prefix"); got != "listKeysByPrefix" {
+		t.Errorf("unexpected JS name %q", got)
+	}
+}
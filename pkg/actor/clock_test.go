@@ -0,0 +1,52 @@
+package actor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestVirtualClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewVirtualClock(start)
+
+	soon := c.After(10 * time.Millisecond)
+	later := c.After(time.Second)
+
+	c.Advance(9 * time.Millisecond)
+	select {
+	case <-soon:
+		t.Fatal("channel fired before its deadline")
+	default:
+	}
+
+	c.Advance(time.Millisecond)
+	select {
+	case now := <-soon:
+		if !now.Equal(start.Add(10 * time.Millisecond)) {
+			t.Errorf("unexpected fire time %v", now)
+		}
+	default:
+		t.Fatal("channel did not fire at its deadline")
+	}
+	select {
+	case <-later:
+		t.Fatal("later channel fired too early")
+	default:
+	}
+
+	c.Set(start.Add(time.Hour))
+	if !c.Now().Equal(start.Add(time.Hour)) {
+		t.Errorf("unexpected Now %v", c.Now())
+	}
+	select {
+	case <-later:
+	default:
+		t.Error("Set should fire due channels")
+	}
+
+	select {
+	case <-c.After(0):
+	default:
+		t.Error("After(0) should fire immediately")
+	}
+}
@@ -0,0 +1,210 @@
+// Package wasmtest assembles small WebAssembly modules for tests, so
+// fixtures can be written next to the test instead of checked in as binaries.
+package wasmtest
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Value types.
+const (
+	I32 byte = 0x7f
+	I64 byte = 0x7e
+)
+
+// Instructions without immediates.
+var (
+	Unreachable = []byte{0x00}
+	Drop        = []byte{0x1a}
+	End         = []byte{0x0b}
+	Return      = []byte{0x0f}
+	I32Add      = []byte{0x6a}
+	I32Eq       = []byte{0x46}
+	I64Add      = []byte{0x7c}
+	I64Eqz      = []byte{0x50}
+	MemoryGrow  = []byte{0x40, 0x00}
+	MemorySize  = []byte{0x3f, 0x00}
+)
+
+// I32Const pushes v.
+func I32Const(v int32) []byte { return append([]byte{0x41}, sleb(int64(v))...) }
+
+// I64Const pushes v.
+func I64Const(v int64) []byte { return append([]byte{0x42}, sleb(v)...) }
+
+// LocalGet pushes local i.
+func LocalGet(i uint32) []byte { return append([]byte{0x20}, uleb(uint64(i))...) }
+
+// LocalSet pops into local i.
+func LocalSet(i uint32) []byte { return append([]byte{0x21}, uleb(uint64(i))...) }
+
+// GlobalGet pushes global i.
+func GlobalGet(i uint32) []byte { return append([]byte{0x23}, uleb(uint64(i))...) }
+
+// GlobalSet pops into global i.
+func GlobalSet(i uint32) []byte { return append([]byte{0x24}, uleb(uint64(i))...) }
+
+// Call calls function f.
+func Call(f uint32) []byte { return append([]byte{0x10}, uleb(uint64(f))...) }
+
+// I32Load loads from the address on the stack.
+func I32Load() []byte { return []byte{0x28, 0x02, 0x00} }
+
+// I32Store stores the value on the stack at the address below it.
+func I32Store() []byte { return []byte{0x36, 0x02, 0x00} }
+
+// Loop starts a loop block; Br(0) inside it jumps back to the start.
+func Loop() []byte { return []byte{0x03, 0x40} }
+
+// If starts a block run when the i32 on the stack is non-zero.
+func If() []byte { return []byte{0x04, 0x40} }
+
+// Br branches to the enclosing block depth levels up.
+func Br(depth uint32) []byte { return append([]byte{0x0c}, uleb(uint64(depth))...) }
+
+// Code concatenates instructions.
+func Code(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+type funcType struct{ params, results []byte }
+
+type function struct {
+	typ    uint32
+	locals []byte
+	body   []byte
+}
+
+type global struct {
+	typ  byte
+	init []byte
+}
+
+// Module is a WebAssembly module under construction. Imports must be added
+// before functions, since they share the function index space.
+type Module struct {
+	types    []funcType
+	imports  [][]byte
+	nimports uint32
+	funcs    []function
+	memory   []byte
+	globals  []global
+	exports  [][]byte
+	data     [][]byte
+}
+
+func (m *Module) typeIndex(params, results []byte) uint32 {
+	for i, t := range m.types {
+		if bytes.Equal(t.params, params) && bytes.Equal(t.results, results) {
+			return uint32(i)
+		}
+	}
+	m.types = append(m.types, funcType{params, results})
+	return uint32(len(m.types) - 1)
+}
+
+// Import imports a function and returns its index.
+func (m *Module) Import(module, name string, params, results []byte) uint32 {
+	if len(m.funcs) > 0 {
+		panic("wasmtest: imports must be added before functions")
+	}
+	entry := Code(str(module), str(name), []byte{0x00}, uleb(uint64(m.typeIndex(params, results))))
+	m.imports = append(m.imports, entry)
+	m.nimports++
+	return m.nimports - 1
+}
+
+// ImportMemory imports the memory instead of defining one.
+func (m *Module) ImportMemory(module, name string, min uint32) {
+	m.imports = append(m.imports, Code(str(module), str(name), []byte{0x02, 0x00}, uleb(uint64(min))))
+}
+
+// Func defines a function and returns its index. locals lists the types of
+// its locals after the parameters; body must not include the final End.
+func (m *Module) Func(params, results, locals []byte, body ...[]byte) uint32 {
+	m.funcs = append(m.funcs, function{typ: m.typeIndex(params, results), locals: locals, body: Code(body...)})
+	return m.nimports + uint32(len(m.funcs)-1)
+}
+
+// Memory defines a memory of min pages and exports it as "memory".
+func (m *Module) Memory(min uint32) {
+	m.memory = Code([]byte{0x00}, uleb(uint64(min)))
+	m.exports = append(m.exports, Code(str("memory"), []byte{0x02, 0x00}))
+}
+
+// Global defines a mutable global initialized to init and returns its index.
+func (m *Module) Global(typ byte, init []byte) uint32 {
+	m.globals = append(m.globals, global{typ: typ, init: init})
+	return uint32(len(m.globals) - 1)
+}
+
+// Export exports function f as name.
+func (m *Module) Export(name string, f uint32) {
+	m.exports = append(m.exports, Code(str(name), []byte{0x00}, uleb(uint64(f))))
+}
+
+// Data places data in memory at offset.
+func (m *Module) Data(offset int32, data []byte) {
+	m.data = append(m.data, Code([]byte{0x00}, I32Const(offset), End, uleb(uint64(len(data))), data))
+}
+
+// Bytes encodes the module.
+func (m *Module) Bytes() []byte {
+	out := []byte("\x00asm\x01\x00\x00\x00")
+
+	var types [][]byte
+	for _, t := range m.types {
+		types = append(types, Code([]byte{0x60}, vec(t.params), vec(t.results)))
+	}
+	out = section(out, 1, types)
+	out = section(out, 2, m.imports)
+
+	var funcs, code [][]byte
+	for _, f := range m.funcs {
+		funcs = append(funcs, uleb(uint64(f.typ)))
+		var locals [][]byte
+		for _, l := range f.locals {
+			locals = append(locals, []byte{0x01, l})
+		}
+		body := Code(uleb(uint64(len(locals))), bytes.Join(locals, nil), f.body, End)
+		code = append(code, Code(uleb(uint64(len(body))), body))
+	}
+	out = section(out, 3, funcs)
+	if m.memory != nil {
+		out = section(out, 5, [][]byte{m.memory})
+	}
+	var globals [][]byte
+	for _, g := range m.globals {
+		globals = append(globals, Code([]byte{g.typ, 0x01}, g.init, End))
+	}
+	out = section(out, 6, globals)
+	out = section(out, 7, m.exports)
+	out = section(out, 10, code)
+	out = section(out, 11, m.data)
+	return out
+}
+
+func section(out []byte, id byte, entries [][]byte) []byte {
+	if len(entries) == 0 {
+		return out
+	}
+	content := Code(uleb(uint64(len(entries))), bytes.Join(entries, nil))
+	return Code(out, []byte{id}, uleb(uint64(len(content))), content)
+}
+
+func vec(b []byte) []byte { return Code(uleb(uint64(len(b))), b) }
+
+func str(s string) []byte { return vec([]byte(s)) }
+
+func uleb(v uint64) []byte { return binary.AppendUvarint(nil, v) }
+
+func sleb(v int64) []byte {
+	var out []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
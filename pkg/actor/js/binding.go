@@ -0,0 +1,128 @@
+package js
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/debug"
+
+	"orvalho/pkg/actor/binding"
+
+	"github.com/dop251/goja"
+)
+
+// bindingObject builds the env object for a binding, one guarded function
+// per method.
+func (r *Runtime) bindingObject(b binding.Binding) *goja.Object {
+	obj := r.vm.NewObject()
+	for _, m := range b.Methods {
+		name := b.EnvName() + "." + binding.JSName(m.Name)
+		obj.Set(binding.JSName(m.Name), r.guard(name, r.bindingMethod(name, m)))
+	}
+	return obj
+}
+
+// bindingMethod calls m. Sync calls block the event loop, so they end with
+// the handler's budget; async calls run until the runtime shuts down.
+func (r *Runtime) bindingMethod(name string, m binding.Method) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		args := make(binding.Args, len(call.Arguments))
+		for i, arg := range call.Arguments {
+			args[i] = r.exportArg(arg)
+		}
+
+		if !m.Async {
+			ctx, cancel := r.boundContext(r.callContext())
+			defer cancel()
+			result, err := m.Call(ctx, args)
+			if err != nil && ctx.Err() != nil {
+				// The interrupt takes effect as soon as control is back in the script.
+				r.interruptFor(ctx)
+				return goja.Undefined()
+			}
+			if err != nil {
+				panic(r.bindingError(name, err))
+			}
+			value, err := r.bindingResult(result)
+			if err != nil {
+				panic(r.vm.NewGoError(fmt.Errorf("%s: %w", name, err)))
+			}
+			return value
+		}
+
+		promise, resolve, reject := r.vm.NewPromise()
+		r.pendingOps++
+		go func() {
+			result, err := r.callAsync(name, m, args)
+			r.enqueue(func() error {
+				r.pendingOps--
+				if err != nil {
+					reject(r.bindingError(name, err))
+					return nil
+				}
+				value, err := r.bindingResult(result)
+				if err != nil {
+					reject(r.vm.NewGoError(fmt.Errorf("%s: %w", name, err)))
+					return nil
+				}
+				resolve(value)
+				return nil
+			})
+		}()
+		return r.vm.ToValue(promise)
+	}
+}
+
+// callAsync calls m off the event loop. It is the error boundary guard is
+// for sync methods: a panic is logged and becomes an internal error.
+func (r *Runtime) callAsync(name string, m binding.Method, args binding.Args) (result any, err error) {
+	defer func() {
+		if x := recover(); x != nil {
+			r.logger.Error("binding panicked", "binding", name, "panic", x, "stack", string(debug.Stack()))
+			result, err = nil, fmt.Errorf("%s: internal error: %v", name, x)
+		}
+	}()
+	return m.Call(r.lifetime, args)
+}
+
+// exportArg converts a JS argument to a Go value the method may keep:
+// ArrayBuffers, typed arrays and DataViews become copies of their bytes.
+func (r *Runtime) exportArg(v goja.Value) any {
+	if data, ok := r.bufferSource(v); ok {
+		return data
+	}
+	return v.Export()
+}
+
+// bindingError converts an error from a method to JS. Arguments that do
+// not fit the method's parameters make a TypeError.
+func (r *Runtime) bindingError(name string, err error) *goja.Object {
+	if errors.Is(err, binding.ErrInvalidArgument) {
+		return r.vm.NewTypeError("%s: %s", name, err)
+	}
+	return r.vm.NewGoError(err)
+}
+
+// bindingResult converts a method's result to JS: bytes become an
+// ArrayBuffer and records cross as JSON, so their fields follow json tags.
+// It fails if the result has no JSON form, e.g. holds a NaN.
+func (r *Runtime) bindingResult(result any) (goja.Value, error) {
+	switch x := result.(type) {
+	case nil:
+		return goja.Null(), nil
+	case []byte:
+		if x == nil {
+			return goja.Null(), nil
+		}
+		return r.vm.ToValue(r.vm.NewArrayBuffer(x)), nil
+	case string, bool, int32, int64, uint32, uint64, float64:
+		return r.vm.ToValue(x), nil
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	parse, _ := goja.AssertFunction(r.vm.Get("JSON").ToObject(r.vm).Get("parse"))
+	return parse(goja.Undefined(), r.vm.ToValue(string(data)))
+}
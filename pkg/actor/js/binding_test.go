@@ -0,0 +1,186 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/binding"
+
+	"github.com/dop251/goja"
+)
+
+type testEntry struct {
+	Key  string `json:"key"`
+	Size int    `json:"size"`
+}
+
+func testBinding(stored map[string][]byte) binding.Binding {
+	return binding.Binding{
+		Name: "kv",
+		Methods: []binding.Method{
+			{
+				Name:  "get",
+				Async: true,
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					var key string
+					if err := args.Decode(0, &key); err != nil {
+						return nil, err
+					}
+					return stored[key], nil
+				},
+			},
+			{
+				Name: "put",
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					var key string
+					var value []byte
+					if err := args.Decode(0, &key); err != nil {
+						return nil, err
+					}
+					if err := args.Decode(1, &value); err != nil {
+						return nil, err
+					}
+					if key == "" {
+						return nil, errors.New("empty key")
+					}
+					stored[key] = value
+					return nil, nil
+				},
+			},
+			{
+				Name: "list_keys",
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					var entries []testEntry
+					for key, value := range stored {
+						entries = append(entries, testEntry{Key: key, Size: len(value)})
+					}
+					return entries, nil
+				},
+			},
+		},
+	}
+}
+
+func TestWithBinding(t *testing.T) {
+	stored := map[string][]byte{}
+	r, err := LoadFiles(map[string]string{
+		"main.js": `
+			export default {
+				async message(data, env) {
+					env.KV.put("greeting", new TextEncoder().encode("hello"));
+					try {
+						env.KV.put("", new Uint8Array(1));
+					} catch (e) {
+						globalThis.putError = e.message;
+					}
+					const value = await env.KV.get("greeting");
+					globalThis.isBuffer = value instanceof ArrayBuffer;
+					globalThis.value = new TextDecoder().decode(value);
+					globalThis.missing = await env.KV.get("nope");
+					globalThis.entries = JSON.stringify(env.KV.listKeys());
+				},
+			};
+		`,
+	}, "main.js", WithBinding(testBinding(stored)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Deliver("go")
+	runToIdle(t, r)
+
+	if string(stored["greeting"]) != "hello" {
+		t.Errorf("put did not store the bytes, got %q", stored["greeting"])
+	}
+	if got := r.vm.Get("putError"); got == nil || got.String() != "empty key" {
+		t.Errorf("expected the method error to be thrown, got %v", got)
+	}
+	if !r.vm.Get("isBuffer").ToBoolean() || r.vm.Get("value").String() != "hello" {
+		t.Errorf("get should resolve to an ArrayBuffer, got %v", r.vm.Get("value"))
+	}
+	if got := r.vm.Get("missing"); !goja.IsNull(got) {
+		t.Errorf("a nil result should be null, got %v", got)
+	}
+	if got := r.vm.Get("entries").String(); got != `[{"key":"greeting","size":5}]` {
+		t.Errorf("records should follow their json tags, got %s", got)
+	}
+}
+
+func TestAsyncBindingFailures(t *testing.T) {
+	cancelled := make(chan error, 1)
+	r, err := LoadFiles(map[string]string{
+		"main.js": `
+			export default {
+				async message(data, env) {
+					try {
+						await env.BROKEN.explode();
+					} catch (e) {
+						globalThis.rejection = e.message;
+					}
+					try {
+						await env.BROKEN.nan();
+					} catch (e) {
+						globalThis.unencodable = e.message;
+					}
+					env.BROKEN.hang();
+				},
+			};
+		`,
+	}, "main.js", WithBinding(binding.Binding{
+		Name: "broken",
+		Methods: []binding.Method{
+			{
+				Name:  "explode",
+				Async: true,
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					panic("boom")
+				},
+			},
+			{
+				Name:  "nan",
+				Async: true,
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					return map[string]float64{"v": math.NaN()}, nil
+				},
+			},
+			{
+				Name:  "hang",
+				Async: true,
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					<-ctx.Done()
+					cancelled <- context.Cause(ctx)
+					return nil, ctx.Err()
+				},
+			},
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Deliver("go")
+	ctx := context.Background()
+	for {
+		if _, err := r.Tick(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if r.vm.Get("unencodable") != nil {
+			break
+		}
+		<-r.Wake()
+	}
+
+	if got := r.vm.Get("rejection").String(); got != "BROKEN.explode: internal error: boom" {
+		t.Errorf("a panicking async method should reject its promise, got %q", got)
+	}
+	if got := r.vm.Get("unencodable").String(); got != "BROKEN.nan: json: unsupported value: NaN" {
+		t.Errorf("a result with no JSON form should reject the promise, got %q", got)
+	}
+	if err := r.Shutdown(ctx, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-cancelled; !errors.Is(err, actor.ErrShutdown) {
+		t.Errorf("shutting down should cancel running calls, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// BudgetExceededError is the interrupt value used when a script outruns its
+// time budget. Tick returns it wrapped in a *goja.InterruptedError; use
+// errors.As to detect it.
+type BudgetExceededError struct {
+	// Scope is "tick" or "handler".
+	Scope string
+	Limit time.Duration
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("%s budget of %v exceeded", e.Scope, e.Limit)
+}
+
+// Stats counts how the runtime has been behaving.
+type Stats struct {
+	Ticks           uint64
+	TickOverruns    uint64
+	HandlerOverruns uint64
+}
+
+// Stats returns the runtime's counters. It is safe to call at any time.
+func (r *Runtime) Stats() Stats {
+	return Stats{
+		Ticks:           r.ticks.Load(),
+		TickOverruns:    r.tickBudget.overruns.Load(),
+		HandlerOverruns: r.handlerBudget.overruns.Load(),
+	}
+}
+
+// watchdog interrupts the VM when a call runs longer than its limit.
+// Budgets are measured in wall time, regardless of the runtime's clock.
+type watchdog struct {
+	scope    string
+	limit    time.Duration
+	overruns atomic.Uint64
+
+	mu       sync.Mutex
+	gen      uint64    // bumped per call, so a late timer can't interrupt the next one
+	fired    bool      // the running call has been interrupted
+	deadline time.Time // of the running call, zero between calls
+}
+
+// run calls fn, interrupting vm if it is still running after the limit.
+func (w *watchdog) run(vm *goja.Runtime, fn func() error) error {
+	if w.limit <= 0 {
+		return fn()
+	}
+
+	w.mu.Lock()
+	w.gen++
+	gen := w.gen
+	w.fired = false
+	w.deadline = time.Now().Add(w.limit)
+	w.mu.Unlock()
+
+	timer := time.AfterFunc(w.limit, func() { w.expire(vm, gen) })
+	err := fn()
+	timer.Stop()
+
+	w.mu.Lock()
+	w.gen++
+	w.deadline = time.Time{}
+	if w.fired && err == nil {
+		// The timer fired as fn was returning; don't let the pending
+		// interrupt hit whatever runs next.
+		vm.ClearInterrupt()
+	}
+	w.mu.Unlock()
+	return err
+}
+
+// expire interrupts vm for call gen, unless that call has finished or
+// already been interrupted.
+func (w *watchdog) expire(vm *goja.Runtime, gen uint64) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.gen != gen || w.fired {
+		return
+	}
+	w.fired = true
+	w.overruns.Add(1)
+	vm.Interrupt(&BudgetExceededError{Scope: w.scope, Limit: w.limit})
+}
+
+// budgetExpiry is the cancellation cause of a context bounded by a watchdog.
+type budgetExpiry struct {
+	watchdog *watchdog
+	gen      uint64
+}
+
+func (e *budgetExpiry) Error() string {
+	return fmt.Sprintf("%s budget of %v exceeded", e.watchdog.scope, e.watchdog.limit)
+}
+
+// bound limits ctx to the deadline of the running call, for host work that
+// blocks the event loop where VM interrupts can't reach it.
+func (w *watchdog) bound(ctx context.Context) (context.Context, context.CancelFunc) {
+	w.mu.Lock()
+	deadline, gen := w.deadline, w.gen
+	w.mu.Unlock()
+	if deadline.IsZero() {
+		return ctx, func() {}
+	}
+	return context.WithDeadlineCause(ctx, deadline, &budgetExpiry{watchdog: w, gen: gen})
+}
+
+// boundContext limits ctx to both budgets of the running Tick.
+func (r *Runtime) boundContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancelTick := r.tickBudget.bound(ctx)
+	ctx, cancelHandler := r.handlerBudget.bound(ctx)
+	return ctx, func() {
+		cancelHandler()
+		cancelTick()
+	}
+}
+
+// interruptFor interrupts the VM when ctx, returned by boundContext, has
+// ended, so the script stops as if it had been running itself.
+func (r *Runtime) interruptFor(ctx context.Context) {
+	var expiry *budgetExpiry
+	switch cause := context.Cause(ctx); {
+	case cause == nil:
+	case errors.As(cause, &expiry):
+		expiry.watchdog.expire(r.vm, expiry.gen)
+	default:
+		r.vm.Interrupt(cause)
+	}
+}
@@ -0,0 +1,86 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestHandlerBudget(t *testing.T) {
+	r := New(`while (true) {}`, WithHandlerBudget(20*time.Millisecond))
+
+	start := time.Now()
+	_, err := r.Tick(context.Background())
+	if time.Since(start) > time.Second {
+		t.Fatal("runaway script was not interrupted in time")
+	}
+
+	var budget *BudgetExceededError
+	if !errors.As(err, &budget) {
+		t.Fatalf("expected BudgetExceededError, got %v", err)
+	}
+	if budget.Scope != "handler" || budget.Limit != 20*time.Millisecond {
+		t.Errorf("unexpected budget error %+v", budget)
+	}
+	if stats := r.Stats(); stats.HandlerOverruns != 1 || stats.TickOverruns != 0 || stats.Ticks != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestTickBudget(t *testing.T) {
+	// Each timer stays under the handler budget, but together they overrun the tick.
+	r := New(`
+		function spin(ms) { var end = Date.now() + ms; while (Date.now() < end) {} }
+		for (var i = 0; i < 10; i++) setTimeout(function() { spin(15); }, 0);
+	`, WithHandlerBudget(100*time.Millisecond), WithTickBudget(40*time.Millisecond))
+	ctx := context.Background()
+
+	if _, err := r.Tick(ctx); err != nil {
+		t.Fatal(err)
+	}
+	_, err := r.Tick(ctx)
+	var budget *BudgetExceededError
+	if !errors.As(err, &budget) || budget.Scope != "tick" {
+		t.Fatalf("expected tick budget error, got %v", err)
+	}
+	if stats := r.Stats(); stats.TickOverruns != 1 || stats.HandlerOverruns != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestBudgetDoesNotLeakIntoNextHandler(t *testing.T) {
+	r := New(`
+		var count = 0;
+		setInterval(function() { count++; }, 1);
+	`, WithHandlerBudget(time.Millisecond))
+	ctx := context.Background()
+	for i := 0; i < 50; i++ {
+		if _, err := r.Tick(ctx); err != nil {
+			t.Fatalf("well-behaved handler was interrupted on tick %d: %v", i, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestRunawayFetchHandler(t *testing.T) {
+	r := New(`
+		addEventListener("fetch", function(event) {
+			if (event.request.url.endsWith("/spin")) while (true) {}
+			event.respondWith(new Response("ok"));
+		});
+	`, WithHandlerBudget(20*time.Millisecond))
+
+	if resp := serve(t, r, httptest.NewRequest("GET", "/spin", nil)); resp.StatusCode != http.StatusInternalServerError {
+		t.Errorf("expected 500 for a runaway handler, got %d", resp.StatusCode)
+	}
+	// The actor itself survives and keeps serving.
+	if resp := serve(t, r, httptest.NewRequest("GET", "/", nil)); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected 200 after the runaway request, got %d", resp.StatusCode)
+	}
+	if got := r.Stats().HandlerOverruns; got != 1 {
+		t.Errorf("expected one handler overrun, got %d", got)
+	}
+}
@@ -0,0 +1,134 @@
+package js
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// cloneObject is a plain object copied out of the VM, keeping its key order.
+type cloneObject struct {
+	keys   []string
+	values []any
+}
+
+func (o *cloneObject) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(o.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// cloneValue copies v out of the VM with structured clone semantics, so it
+// can be handed to another actor without sharing anything. Primitives, plain
+// objects, arrays, Dates and binary data are supported; binary data arrives
+// as an ArrayBuffer, and other objects as plain objects of their own
+// enumerable properties. Functions, symbols, collections and cyclic values
+// throw a DataCloneError.
+func (r *Runtime) cloneValue(v goja.Value) any {
+	return r.clone(v, map[*goja.Object]bool{})
+}
+
+func (r *Runtime) clone(v goja.Value, path map[*goja.Object]bool) any {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		switch x := v.Export().(type) {
+		case *big.Int:
+			return new(big.Int).Set(x)
+		case nil, bool, int64, float64, string:
+			return x
+		}
+		panic(r.dataCloneError("%s could not be cloned", v.String()))
+	}
+
+	if path[obj] {
+		panic(r.dataCloneError("cyclic values could not be cloned"))
+	}
+	path[obj] = true
+	defer delete(path, obj)
+
+	if _, ok := goja.AssertFunction(obj); ok {
+		panic(r.dataCloneError("functions could not be cloned"))
+	}
+	switch x := obj.Export().(type) {
+	case []any:
+		values := make([]any, len(x))
+		for i := range values {
+			values[i] = r.clone(obj.Get(strconv.Itoa(i)), path)
+		}
+		return values
+	case time.Time:
+		return x
+	}
+	if data, ok := r.bufferSource(obj); ok {
+		return data
+	}
+	for _, class := range []string{"Map", "Set", "WeakMap", "WeakSet", "Promise"} {
+		if obj.Get("constructor") == r.vm.Get(class) {
+			panic(r.dataCloneError("%s objects could not be cloned", class))
+		}
+	}
+
+	keys := obj.Keys()
+	clone := &cloneObject{keys: keys, values: make([]any, len(keys))}
+	for i, key := range keys {
+		clone.values[i] = r.clone(obj.Get(key), path)
+	}
+	return clone
+}
+
+func (r *Runtime) dataCloneError(format string, args ...any) *goja.Object {
+	err := r.vm.NewTypeError(fmt.Sprintf(format, args...))
+	err.Set("name", "DataCloneError")
+	return err
+}
+
+// importClone creates fresh JS values from a value copied by cloneValue,
+// here or in another runtime.
+func (r *Runtime) importClone(v any) goja.Value {
+	switch x := v.(type) {
+	case nil:
+		return goja.Null()
+	case []byte:
+		return r.vm.ToValue(r.vm.NewArrayBuffer(bytes.Clone(x)))
+	case *big.Int:
+		return r.vm.ToValue(new(big.Int).Set(x))
+	case time.Time:
+		date, err := r.vm.New(r.vm.Get("Date"), r.vm.ToValue(x.UnixMilli()))
+		if err != nil {
+			panic(err)
+		}
+		return date
+	case []any:
+		values := make([]any, len(x))
+		for i, value := range x {
+			values[i] = r.importClone(value)
+		}
+		return r.vm.NewArray(values...)
+	case *cloneObject:
+		obj := r.vm.NewObject()
+		for i, key := range x.keys {
+			obj.Set(key, r.importClone(x.values[i]))
+		}
+		return obj
+	}
+	return r.vm.ToValue(v)
+}
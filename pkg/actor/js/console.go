@@ -0,0 +1,280 @@
+package js
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// installConsole sets up the console global, routed to the runtime's logger.
+func (r *Runtime) installConsole() {
+	base := r.logger
+	if base == nil {
+		base = slog.Default()
+	}
+	r.logger = slog.New(r.logs.Handler(base.Handler()))
+	if r.id != "" {
+		r.logger = r.logger.With("actor", r.id)
+	}
+	if r.version != "" {
+		r.logger = r.logger.With("version", r.version)
+	}
+
+	timers := make(map[string]time.Time)
+	counts := make(map[string]int)
+	label := func(call goja.FunctionCall) string {
+		if goja.IsUndefined(call.Argument(0)) {
+			return "default"
+		}
+		return call.Argument(0).String()
+	}
+
+	console := r.vm.NewObject()
+	console.Set("log", r.consoleMethod(slog.LevelInfo))
+	console.Set("info", r.consoleMethod(slog.LevelInfo))
+	console.Set("warn", r.consoleMethod(slog.LevelWarn))
+	console.Set("error", r.consoleMethod(slog.LevelError))
+	console.Set("debug", r.consoleMethod(slog.LevelDebug))
+	console.Set("trace", func(call goja.FunctionCall) goja.Value {
+		msg := "Trace"
+		if len(call.Arguments) > 0 {
+			msg += ": " + r.format(call.Arguments)
+		}
+		r.log(slog.LevelDebug, msg+"\n"+r.stackTrace())
+		return goja.Undefined()
+	})
+	console.Set("assert", func(call goja.FunctionCall) goja.Value {
+		if call.Argument(0).ToBoolean() {
+			return goja.Undefined()
+		}
+		msg := "Assertion failed"
+		if len(call.Arguments) > 1 {
+			msg += ": " + r.format(call.Arguments[1:])
+		}
+		r.log(slog.LevelError, msg)
+		return goja.Undefined()
+	})
+	console.Set("count", func(call goja.FunctionCall) goja.Value {
+		name := label(call)
+		counts[name]++
+		r.log(slog.LevelInfo, fmt.Sprintf("%s: %d", name, counts[name]))
+		return goja.Undefined()
+	})
+	console.Set("countReset", func(call goja.FunctionCall) goja.Value {
+		delete(counts, label(call))
+		return goja.Undefined()
+	})
+	console.Set("time", func(call goja.FunctionCall) goja.Value {
+		timers[label(call)] = r.clock.Now()
+		return goja.Undefined()
+	})
+	elapsed := func(call goja.FunctionCall, end bool) goja.Value {
+		name := label(call)
+		start, ok := timers[name]
+		if !ok {
+			r.log(slog.LevelWarn, fmt.Sprintf("Timer '%s' does not exist", name))
+			return goja.Undefined()
+		}
+		if end {
+			delete(timers, name)
+		}
+		msg := fmt.Sprintf("%s: %s", name, r.clock.Now().Sub(start))
+		if len(call.Arguments) > 1 {
+			msg += " " + r.format(call.Arguments[1:])
+		}
+		r.log(slog.LevelInfo, msg)
+		return goja.Undefined()
+	}
+	console.Set("timeLog", func(call goja.FunctionCall) goja.Value { return elapsed(call, false) })
+	console.Set("timeEnd", func(call goja.FunctionCall) goja.Value { return elapsed(call, true) })
+	console.Set("table", func(call goja.FunctionCall) goja.Value {
+		data, ok := call.Argument(0).(*goja.Object)
+		if !ok {
+			r.log(slog.LevelInfo, r.format(call.Arguments))
+			return goja.Undefined()
+		}
+		r.log(slog.LevelInfo, r.table(data))
+		return goja.Undefined()
+	})
+	r.vm.Set("console", console)
+}
+
+func (r *Runtime) consoleMethod(level slog.Level) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		r.log(level, r.format(call.Arguments))
+		return goja.Undefined()
+	}
+}
+
+// log emits msg tagged with the script location that called console.
+func (r *Runtime) log(level slog.Level, msg string) {
+	var attrs []slog.Attr
+	if loc := r.callerLocation(); loc != "" {
+		attrs = append(attrs, slog.String("location", loc))
+	}
+	r.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// callerLocation returns "file:line:column" of the innermost script frame.
+func (r *Runtime) callerLocation() string {
+	for _, frame := range r.vm.CaptureCallStack(0, nil) {
+		if frame.SrcName() == "<native>" {
+			continue
+		}
+		pos := frame.Position()
+		return fmt.Sprintf("%s:%d:%d", frame.SrcName(), pos.Line, pos.Column)
+	}
+	return ""
+}
+
+func (r *Runtime) stackTrace() string {
+	var b strings.Builder
+	for _, frame := range r.vm.CaptureCallStack(0, nil) {
+		if frame.SrcName() == "<native>" {
+			continue
+		}
+		pos := frame.Position()
+		fmt.Fprintf(&b, "    at %s (%s:%d:%d)\n", frame.FuncName(), frame.SrcName(), pos.Line, pos.Column)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// format renders console arguments like browsers do, including %s/%d/%i/%f/%o/%O/%j substitutions.
+func (r *Runtime) format(args []goja.Value) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	var parts []string
+	rest := args
+	if first, ok := args[0].Export().(string); ok && strings.Contains(first, "%") {
+		rest = args[1:]
+		var b strings.Builder
+		for i := 0; i < len(first); i++ {
+			if first[i] != '%' || i+1 == len(first) {
+				b.WriteByte(first[i])
+				continue
+			}
+			verb := first[i+1]
+			if verb == '%' {
+				b.WriteByte('%')
+				i++
+				continue
+			}
+			if !strings.ContainsRune("sdifoOj", rune(verb)) || len(rest) == 0 {
+				b.WriteByte('%')
+				continue
+			}
+			arg := rest[0]
+			rest = rest[1:]
+			i++
+			switch verb {
+			case 's':
+				b.WriteString(r.inspect(arg, true))
+			case 'd', 'i':
+				b.WriteString(strconv.FormatInt(arg.ToInteger(), 10))
+			case 'f':
+				b.WriteString(strconv.FormatFloat(arg.ToFloat(), 'g', -1, 64))
+			default:
+				b.WriteString(r.inspect(arg, false))
+			}
+		}
+		parts = append(parts, b.String())
+	}
+
+	for _, arg := range rest {
+		parts = append(parts, r.inspect(arg, true))
+	}
+	return strings.Join(parts, " ")
+}
+
+// inspect converts a value to the text shown in logs. Top-level strings are
+// printed verbatim, objects as JSON and errors with their stack.
+func (r *Runtime) inspect(v goja.Value, bareStrings bool) string {
+	if v == nil || goja.IsUndefined(v) {
+		return "undefined"
+	}
+	if s, ok := v.Export().(string); ok && bareStrings {
+		return s
+	}
+
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		if _, isString := v.Export().(string); isString {
+			return strconv.Quote(v.String())
+		}
+		return v.String()
+	}
+	if _, isFunc := goja.AssertFunction(obj); isFunc {
+		return fmt.Sprintf("[Function: %s]", obj.Get("name"))
+	}
+	if stack := obj.Get("stack"); stack != nil && !goja.IsUndefined(stack) {
+		return stack.String()
+	}
+
+	stringify, _ := goja.AssertFunction(r.vm.Get("JSON").ToObject(r.vm).Get("stringify"))
+	if s, err := stringify(goja.Undefined(), obj); err == nil && !goja.IsUndefined(s) {
+		return s.String()
+	}
+	return obj.String()
+}
+
+// table renders an array or object of rows as an aligned text table.
+func (r *Runtime) table(data *goja.Object) string {
+	const indexColumn, valuesColumn = "(index)", "Values"
+
+	header := []string{indexColumn}
+	seen := map[string]bool{}
+	hasValues := false
+	var rows []map[string]string
+
+	for _, index := range data.Keys() {
+		row := map[string]string{indexColumn: index}
+		if v, ok := data.Get(index).(*goja.Object); ok {
+			for _, key := range v.Keys() {
+				if !seen[key] {
+					seen[key] = true
+					header = append(header, key)
+				}
+				row[key] = r.inspect(v.Get(key), false)
+			}
+		} else {
+			hasValues = true
+			row[valuesColumn] = r.inspect(data.Get(index), false)
+		}
+		rows = append(rows, row)
+	}
+	if hasValues {
+		header = append(header, valuesColumn)
+	}
+
+	widths := make([]int, len(header))
+	for i, name := range header {
+		widths[i] = len(name)
+		for _, row := range rows {
+			widths[i] = max(widths[i], len(row[name]))
+		}
+	}
+
+	var b strings.Builder
+	writeRow := func(cell func(name string) string) {
+		for i, name := range header {
+			if i > 0 {
+				b.WriteString(" | ")
+			}
+			text := cell(name)
+			b.WriteString(text + strings.Repeat(" ", widths[i]-len(text)))
+		}
+		b.WriteString("\n")
+	}
+	writeRow(func(name string) string { return name })
+	for _, row := range rows {
+		writeRow(func(name string) string { return row[name] })
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,93 @@
+package js
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"orvalho/pkg/actor"
+)
+
+func TestConsole(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	r := New(`console.log("hello %s, you are %d", "world", 42.7, { a: 1 });
+console.warn(new Error("careful"));
+console.error([1, "two"]);
+console.debug("quiet");`,
+		WithID("photos"), WithVersion("1.2.0"), WithLogger(logger))
+
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := r.Logs().Tail(0)
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 log entries, got %d", len(entries))
+	}
+
+	first := entries[0]
+	if first.Message != `hello world, you are 42 {"a":1}` {
+		t.Errorf("unexpected message %q", first.Message)
+	}
+	if first.Level != slog.LevelInfo {
+		t.Errorf("expected info level, got %v", first.Level)
+	}
+	attrs := map[string]string{}
+	for _, a := range first.Attrs {
+		attrs[a.Key] = a.Value.String()
+	}
+	if attrs["actor"] != "photos" || attrs["version"] != "1.2.0" || attrs["location"] != "script.js:1:12" {
+		t.Errorf("unexpected attrs %v", attrs)
+	}
+
+	if entries[1].Level != slog.LevelWarn || !strings.Contains(entries[1].Message, "Error: careful") {
+		t.Errorf("unexpected warn entry %+v", entries[1])
+	}
+	if entries[2].Level != slog.LevelError || entries[2].Message != `[1,"two"]` {
+		t.Errorf("unexpected error entry %+v", entries[2])
+	}
+	if entries[3].Level != slog.LevelDebug {
+		t.Errorf("expected debug level, got %v", entries[3].Level)
+	}
+	if !strings.Contains(out.String(), "actor=photos") {
+		t.Errorf("expected logs forwarded to the slog logger, got %q", out.String())
+	}
+}
+
+func TestConsoleHelpers(t *testing.T) {
+	logs := actor.NewLogBuffer(10)
+	r := New(`
+		console.count(); console.count();
+		console.assert(1 === 2, "math is broken");
+		console.time("t"); console.timeEnd("t");
+		console.table([{ a: 1, b: "x" }, { a: 2 }]);
+	`, WithLogBuffer(logs), WithLogger(slog.New(slog.DiscardHandler)))
+
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var msgs []string
+	for _, e := range logs.Tail(0) {
+		msgs = append(msgs, e.Message)
+	}
+	if len(msgs) != 5 {
+		t.Fatalf("expected 5 entries, got %q", msgs)
+	}
+	if msgs[0] != "default: 1" || msgs[1] != "default: 2" {
+		t.Errorf("unexpected count output %q", msgs[:2])
+	}
+	if msgs[2] != "Assertion failed: math is broken" {
+		t.Errorf("unexpected assert output %q", msgs[2])
+	}
+	if !strings.HasPrefix(msgs[3], "t: ") {
+		t.Errorf("unexpected timeEnd output %q", msgs[3])
+	}
+	want := "(index) | a | b  \n0       | 1 | \"x\"\n1       | 2 |    "
+	if msgs[4] != want {
+		t.Errorf("unexpected table:\n%s\nwant:\n%s", msgs[4], want)
+	}
+}
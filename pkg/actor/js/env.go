@@ -0,0 +1,106 @@
+package js
+
+import (
+	"fmt"
+	"runtime/debug"
+	"slices"
+
+	"github.com/dop251/goja"
+)
+
+// DeviceFunc is one method of a native device API. Arguments arrive exported
+// to Go values; the result is converted back to JS and an error is thrown.
+// It runs on the event loop, so it should return quickly.
+type DeviceFunc func(args []any) (any, error)
+
+// Device is a native device API exposed as env.DEVICES.<name>, keyed by method name.
+type Device map[string]DeviceFunc
+
+// injectEnv builds the env object handed to handlers. It holds every binding
+// passed to WithBinding, and with capabilities, one binding per granted
+// capability the host provided a backend for:
+//
+//   - fetch when network access is granted
+//   - each granted secret, by name, as a string
+//   - DEVICES.<name> for each granted device
+//   - ACTORS when other actors are granted and the host provided a registry
+//
+// Without capabilities, ACTORS reaches every actor in the registry.
+func (r *Runtime) injectEnv() *goja.Object {
+	env := r.vm.NewObject()
+	for _, b := range r.bindings {
+		env.Set(b.EnvName(), r.bindingObject(b))
+	}
+	if r.registry != nil && (r.caps == nil || len(r.caps.Actors) > 0) {
+		env.Set("ACTORS", r.actorsObject())
+	}
+	if r.caps == nil {
+		return env
+	}
+
+	if r.caps.Network != nil {
+		env.Set("fetch", r.guard("fetch", r.fetch))
+	}
+
+	for _, name := range r.caps.Secrets {
+		if value, ok := r.secrets[name]; ok {
+			env.Set(name, value)
+		}
+	}
+
+	if len(r.caps.Devices) > 0 {
+		devices := r.vm.NewObject()
+		for _, name := range r.caps.Devices {
+			if device, ok := r.devices[name]; ok {
+				devices.Set(name, r.deviceObject(name, device))
+			}
+		}
+		env.Set("DEVICES", devices)
+	}
+	return env
+}
+
+func (r *Runtime) deviceObject(name string, device Device) *goja.Object {
+	obj := r.vm.NewObject()
+	methods := make([]string, 0, len(device))
+	for method := range device {
+		methods = append(methods, method)
+	}
+	slices.Sort(methods)
+
+	for _, method := range methods {
+		fn := device[method]
+		obj.Set(method, r.guard("DEVICES."+name+"."+method, func(call goja.FunctionCall) goja.Value {
+			args := make([]any, len(call.Arguments))
+			for i, arg := range call.Arguments {
+				args[i] = arg.Export()
+			}
+			result, err := fn(args)
+			if err != nil {
+				panic(r.vm.NewGoError(err))
+			}
+			return r.vm.ToValue(result)
+		}))
+	}
+	return obj
+}
+
+// guard is the error boundary around a binding. A Go panic inside fn is
+// logged and rethrown as a JS exception instead of crashing the process.
+// JS exceptions and interrupts pass through untouched.
+func (r *Runtime) guard(name string, fn func(goja.FunctionCall) goja.Value) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		defer func() {
+			x := recover()
+			switch x.(type) {
+			case nil:
+				return
+			case goja.Value, *goja.Exception, *goja.InterruptedError, *goja.StackOverflowError:
+				panic(x)
+			}
+			r.logger.Error("binding panicked", "binding", name, "panic", x, "stack", string(debug.Stack()))
+			panic(r.vm.NewGoError(fmt.Errorf("%s: internal error: %v", name, x)))
+		}()
+		return fn(call)
+	}
+}
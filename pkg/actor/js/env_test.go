@@ -0,0 +1,136 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"orvalho/pkg/actor/manifest"
+)
+
+func TestEnvOnlyHasGrantedBindings(t *testing.T) {
+	r, err := LoadFiles(map[string]string{
+		"main.js": `
+			export default {
+				message(data, env) {
+					globalThis.keys = Object.keys(env).sort().join(",");
+					globalThis.devices = Object.keys(env.DEVICES).join(",");
+					globalThis.token = env.API_TOKEN;
+					globalThis.photo = env.DEVICES.camera.capture(640);
+				},
+			};
+		`,
+	}, "main.js",
+		WithCapabilities(manifest.CapabilitySet{
+			Network: &manifest.NetworkCapability{Hosts: []string{"api.example.com"}},
+			Secrets: []string{"API_TOKEN"},
+			Devices: []string{"camera"},
+		}),
+		WithSecrets(map[string]string{"API_TOKEN": "s3cret", "ADMIN_TOKEN": "nope"}),
+		WithDevices(map[string]Device{
+			"camera": {"capture": func(args []any) (any, error) {
+				return map[string]any{"width": args[0]}, nil
+			}},
+			"gpu": {"compute": func([]any) (any, error) { return nil, nil }},
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Deliver("go")
+	runToIdle(t, r)
+
+	if got := r.vm.Get("keys").String(); got != "API_TOKEN,DEVICES,fetch" {
+		t.Errorf("unexpected env bindings %q", got)
+	}
+	if got := r.vm.Get("devices").String(); got != "camera" {
+		t.Errorf("ungranted devices should be hidden, got %q", got)
+	}
+	if got := r.vm.Get("token").String(); got != "s3cret" {
+		t.Errorf("unexpected secret %q", got)
+	}
+	if got := r.vm.Get("photo").ToObject(r.vm).Get("width").ToInteger(); got != 640 {
+		t.Errorf("unexpected device result %d", got)
+	}
+}
+
+func TestEnvEmptyWithoutCapabilities(t *testing.T) {
+	r, err := LoadFiles(map[string]string{
+		"main.js": `export default { message(data, env) { globalThis.keys = Object.keys(env).length; } };`,
+	}, "main.js", WithCapabilities(manifest.CapabilitySet{}), WithSecrets(map[string]string{"API_TOKEN": "s3cret"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Deliver("go")
+	runToIdle(t, r)
+
+	if got := r.vm.Get("keys").ToInteger(); got != 0 {
+		t.Errorf("expected an empty env, got %d bindings", got)
+	}
+	if r.hostAllowed("api.example.com") {
+		t.Error("fetch should not reach any host without network access")
+	}
+}
+
+func TestTimersRequireCapability(t *testing.T) {
+	r := New(`var kind = typeof setTimeout;`, WithCapabilities(manifest.CapabilitySet{}))
+	runToIdle(t, r)
+	if got := r.vm.Get("kind").String(); got != "undefined" {
+		t.Errorf("setTimeout should not exist without the timers capability, got %q", got)
+	}
+
+	r = New(`
+		setTimeout(function() {}, 10);
+		try {
+			setTimeout(function() {}, 10);
+		} catch (e) {
+			var caught = e.message;
+		}
+	`, WithCapabilities(manifest.CapabilitySet{Timers: &manifest.TimerCapability{Max: 1}}))
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.vm.Get("caught"); got == nil || !strings.Contains(got.String(), "too many timers") {
+		t.Errorf("expected the second timer to be refused, got %v", got)
+	}
+}
+
+func TestBindingPanicBecomesException(t *testing.T) {
+	r, err := LoadFiles(map[string]string{
+		"main.js": `
+			export default {
+				message(data, env) {
+					try {
+						env.DEVICES.usb.list();
+					} catch (e) {
+						globalThis.caught = e.message;
+					}
+					env.DEVICES.usb.fail();
+				},
+			};
+		`,
+	}, "main.js",
+		WithCapabilities(manifest.CapabilitySet{Devices: []string{"usb"}}),
+		WithDevices(map[string]Device{"usb": {
+			"list": func([]any) (any, error) { panic("driver exploded") },
+			"fail": func([]any) (any, error) { return nil, errors.New("device busy") },
+		}}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Deliver("go")
+
+	ctx := context.Background()
+	var tickErr error
+	for i := 0; i < 3 && tickErr == nil; i++ {
+		_, tickErr = r.Tick(ctx)
+	}
+	if got := r.vm.Get("caught"); got == nil || !strings.Contains(got.String(), "driver exploded") {
+		t.Errorf("expected the panic to be catchable in JS, got %v", got)
+	}
+	if tickErr == nil || !strings.Contains(tickErr.Error(), "device busy") {
+		t.Errorf("expected the device error to surface from Tick, got %v", tickErr)
+	}
+}
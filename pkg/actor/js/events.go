@@ -0,0 +1,94 @@
+package js
+
+import (
+	"orvalho/pkg/actor"
+
+	"github.com/dop251/goja"
+)
+
+// listener is a callback registered with addEventListener.
+type listener struct {
+	value    goja.Value // kept to match removeEventListener calls
+	callback goja.Callable
+}
+
+func (r *Runtime) addEventListener(call goja.FunctionCall) goja.Value {
+	typ := call.Argument(0).String()
+	fn, ok := goja.AssertFunction(call.Argument(1))
+	if !ok {
+		return goja.Undefined()
+	}
+	for _, l := range r.listeners[typ] {
+		if l.value.StrictEquals(call.Argument(1)) {
+			return goja.Undefined()
+		}
+	}
+	r.listeners[typ] = append(r.listeners[typ], listener{value: call.Argument(1), callback: fn})
+	return goja.Undefined()
+}
+
+func (r *Runtime) removeEventListener(call goja.FunctionCall) goja.Value {
+	typ := call.Argument(0).String()
+	list := r.listeners[typ]
+	for i, l := range list {
+		if l.value.StrictEquals(call.Argument(1)) {
+			r.listeners[typ] = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+	return goja.Undefined()
+}
+
+// newEvent creates the event object handed to listeners.
+func (r *Runtime) newEvent(typ string) *goja.Object {
+	event := r.vm.NewObject()
+	event.Set("type", typ)
+	return event
+}
+
+// dispatchEvent calls every listener registered for the event's type.
+// It reports whether there was any listener at all.
+func (r *Runtime) dispatchEvent(typ string, event *goja.Object) (bool, error) {
+	// Copy so listeners may add or remove listeners while being called.
+	list := append([]listener(nil), r.listeners[typ]...)
+	for _, l := range list {
+		if _, err := l.callback(goja.Undefined(), event); err != nil {
+			return true, err
+		}
+	}
+	return len(list) > 0, nil
+}
+
+// newExecutionContext creates the ctx argument handed to default export handlers.
+func (r *Runtime) newExecutionContext() *goja.Object {
+	ctx := r.vm.NewObject()
+	ctx.Set("waitUntil", r.waitUntil)
+	ctx.Set("passThroughOnException", func() {})
+	return ctx
+}
+
+// waitUntil keeps track of a promise whose outcome nobody awaits.
+func (r *Runtime) waitUntil(call goja.FunctionCall) goja.Value {
+	if err := r.settle(call.Argument(0), func(goja.Value) {}, func(goja.Value) {}); err != nil {
+		panic(r.vm.NewGoError(err))
+	}
+	return goja.Undefined()
+}
+
+// dispatchMessage hands a mailbox message to the default export's message
+// handler, or to the actor's "message" listeners.
+func (r *Runtime) dispatchMessage(msg any) error {
+	if m, ok := msg.(actor.Message); ok {
+		return r.dispatchActorMessage(m)
+	}
+
+	if handler, ok := r.defaultHandler("message"); ok {
+		_, err := handler(r.defaultExport, r.vm.ToValue(msg), r.env, r.newExecutionContext())
+		return err
+	}
+
+	event := r.newEvent("message")
+	event.Set("data", r.vm.ToValue(msg))
+	_, err := r.dispatchEvent("message", event)
+	return err
+}
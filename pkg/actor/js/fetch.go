@@ -0,0 +1,227 @@
+package js
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/dop251/goja"
+)
+
+// MaxResponseBodySize is the largest response body fetch() reads into an actor.
+const MaxResponseBodySize = 32 << 20
+
+// maxRedirects matches the limit browsers and Go's http.Client use.
+const maxRedirects = 20
+
+// ErrHostNotAllowed is returned when an actor fetches a host it was not granted.
+var ErrHostNotAllowed = errors.New("host not allowed")
+
+// fetchResponse is an outbound response read off the event loop.
+type fetchResponse struct {
+	status     int
+	statusText string
+	header     http.Header
+	body       []byte
+	url        string
+	redirected bool
+}
+
+// hostAllowed reports whether the actor may connect to host.
+// Patterns are exact host names, "*.example.com" for any subdomain, or "*" for any host.
+func (r *Runtime) hostAllowed(host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range r.allowedHosts {
+		pattern = strings.ToLower(pattern)
+		switch {
+		case pattern == "*", pattern == host:
+			return true
+		case strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]):
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Runtime) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	if !r.hostAllowed(u.Hostname()) {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, u.Hostname())
+	}
+	return nil
+}
+
+// fetch implements the global fetch(). The request runs on its own goroutine;
+// the returned Promise settles on the event loop once the response is read.
+func (r *Runtime) fetch(call goja.FunctionCall) goja.Value {
+	promise, resolve, reject := r.vm.NewPromise()
+
+	request, err := r.vm.New(r.vm.Get("Request"), call.Argument(0), call.Argument(1))
+	if err != nil {
+		reject(errorValue(err))
+		return r.vm.ToValue(promise)
+	}
+
+	req, redirect, err := r.outboundRequest(request)
+	if err != nil {
+		reject(r.vm.NewTypeError("fetch failed: %s", err))
+		return r.vm.ToValue(promise)
+	}
+
+	// Requests end with the runtime, so shutting down closes their sockets.
+	ctx, cancel := context.WithCancel(r.lifetime)
+	req = req.WithContext(ctx)
+
+	// Wire the AbortSignal, if any, to the Go request until it settles.
+	var signal *goja.Object
+	detach := func() {}
+	if s, ok := request.Get("signal").(*goja.Object); ok {
+		signal = s
+		if signal.Get("aborted").ToBoolean() {
+			cancel()
+			reject(signal.Get("reason"))
+			return r.vm.ToValue(promise)
+		}
+		onAbort := r.vm.ToValue(func(goja.FunctionCall) goja.Value {
+			cancel()
+			return goja.Undefined()
+		})
+		addListener, _ := goja.AssertFunction(signal.Get("addEventListener"))
+		addListener(signal, r.vm.ToValue("abort"), onAbort)
+		detach = func() {
+			removeListener, _ := goja.AssertFunction(signal.Get("removeEventListener"))
+			removeListener(signal, r.vm.ToValue("abort"), onAbort)
+		}
+	}
+
+	client := *r.httpClient
+	client.CheckRedirect = func(next *http.Request, via []*http.Request) error {
+		switch redirect {
+		case "manual":
+			return http.ErrUseLastResponse
+		case "error":
+			return errors.New("redirect not allowed")
+		}
+		if len(via) >= maxRedirects {
+			return errors.New("too many redirects")
+		}
+		return r.checkURL(next.URL)
+	}
+
+	r.pendingOps++
+	go func() {
+		resp, err := doFetch(&client, req)
+		cancel()
+		r.enqueue(func() error {
+			r.pendingOps--
+			detach()
+			switch {
+			case err != nil && signal != nil && signal.Get("aborted").ToBoolean():
+				reject(signal.Get("reason"))
+			case err != nil:
+				reject(r.vm.NewTypeError("fetch failed: %s", err))
+			default:
+				r.resolveFetch(resp, resolve, reject)
+			}
+			return nil
+		})
+	}()
+
+	return r.vm.ToValue(promise)
+}
+
+// outboundRequest converts a JS Request into a Go request, enforcing the host allowlist.
+func (r *Runtime) outboundRequest(request *goja.Object) (*http.Request, string, error) {
+	u, err := url.Parse(request.Get("url").String())
+	if err != nil {
+		return nil, "", err
+	}
+	if err := r.checkURL(u); err != nil {
+		return nil, "", err
+	}
+
+	var body io.Reader
+	if buf, ok := request.Get("_body").Export().(goja.ArrayBuffer); ok {
+		body = bytes.NewReader(append([]byte(nil), buf.Bytes()...))
+	}
+	req, err := http.NewRequest(request.Get("method").String(), u.String(), body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if err := r.exportHeaders(request.Get("headers"), req.Header); err != nil {
+		return nil, "", err
+	}
+
+	return req, request.Get("redirect").String(), nil
+}
+
+// doFetch performs the request and reads the whole response. It runs off the event loop.
+func doFetch(client *http.Client, req *http.Request) (*fetchResponse, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxResponseBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > MaxResponseBodySize {
+		return nil, fmt.Errorf("response body exceeds %d bytes", MaxResponseBodySize)
+	}
+
+	return &fetchResponse{
+		status:     resp.StatusCode,
+		statusText: strings.TrimSpace(strings.TrimPrefix(resp.Status, fmt.Sprint(resp.StatusCode))),
+		header:     resp.Header,
+		body:       body,
+		url:        resp.Request.URL.String(),
+		redirected: resp.Request.URL.String() != req.URL.String(),
+	}, nil
+}
+
+// resolveFetch builds the JS Response for resp. Must run on the event loop.
+func (r *Runtime) resolveFetch(resp *fetchResponse, resolve, reject func(any) error) {
+	var headers []any
+	for name, values := range resp.header {
+		for _, value := range values {
+			headers = append(headers, []any{name, value})
+		}
+	}
+
+	init := r.vm.NewObject()
+	init.Set("status", resp.status)
+	init.Set("statusText", resp.statusText)
+	init.Set("headers", headers)
+
+	var body goja.Value = goja.Null()
+	if len(resp.body) > 0 {
+		body = r.vm.ToValue(r.vm.NewArrayBuffer(resp.body))
+	}
+	response, err := r.vm.New(r.vm.Get("Response"), body, init)
+	if err != nil {
+		reject(errorValue(err))
+		return
+	}
+	response.Set("url", resp.url)
+	response.Set("redirected", resp.redirected)
+	resolve(response)
+}
+
+// errorValue unwraps the JS value thrown by a failed call, so it can be rethrown as is.
+func errorValue(err error) any {
+	var ex *goja.Exception
+	if errors.As(err, &ex) {
+		return ex.Value()
+	}
+	return err
+}
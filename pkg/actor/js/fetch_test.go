@@ -0,0 +1,191 @@
+package js
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newEchoServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		w.Header().Set("X-Method", req.Method)
+		w.Header().Set("X-Token", req.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(body)
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, req *http.Request) {
+		http.Redirect(w, req, "/echo", http.StatusFound)
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, req *http.Request) {
+		select {
+		case <-req.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func serverHost(t *testing.T, srv *httptest.Server) string {
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u.Hostname()
+}
+
+func TestFetch(t *testing.T) {
+	srv := newEchoServer(t)
+	r := New(`
+		var result;
+		fetch(BASE + "/echo", {
+			method: "POST",
+			headers: { Authorization: "Bearer abc" },
+			body: JSON.stringify({ hello: "world" }),
+		}).then(function(resp) {
+			return resp.json().then(function(body) {
+				result = [resp.status, resp.ok, resp.headers.get("x-method"), resp.headers.get("x-token"), body.hello].join(" ");
+			});
+		}, function(err) {
+			result = "error: " + err;
+		});
+	`, WithAllowedHosts(serverHost(t, srv)))
+	r.vm.Set("BASE", srv.URL)
+
+	runToIdle(t, r)
+	if got := r.vm.Get("result").String(); got != "202 true POST Bearer abc world" {
+		t.Errorf("unexpected fetch result %q", got)
+	}
+}
+
+func TestFetchRedirects(t *testing.T) {
+	srv := newEchoServer(t)
+	r := New(`
+		var follow, manual, error;
+		fetch(BASE + "/redirect").then(function(resp) {
+			follow = resp.status + " " + resp.redirected + " " + resp.url.endsWith("/echo");
+		});
+		fetch(BASE + "/redirect", { redirect: "manual" }).then(function(resp) {
+			manual = resp.status + " " + resp.headers.get("location");
+		});
+		fetch(BASE + "/redirect", { redirect: "error" }).catch(function(err) {
+			error = err.name;
+		});
+	`, WithAllowedHosts(serverHost(t, srv)))
+	r.vm.Set("BASE", srv.URL)
+
+	runToIdle(t, r)
+	if got := r.vm.Get("follow").String(); got != "202 true true" {
+		t.Errorf("unexpected followed redirect %q", got)
+	}
+	if got := r.vm.Get("manual").String(); got != "302 /echo" {
+		t.Errorf("unexpected manual redirect %q", got)
+	}
+	if got := r.vm.Get("error").String(); got != "TypeError" {
+		t.Errorf("expected TypeError for redirect: error, got %q", got)
+	}
+}
+
+func TestFetchRequiresNetworkCapability(t *testing.T) {
+	srv := newEchoServer(t)
+	script := `
+		var outcome;
+		fetch(BASE + "/echo").then(function() { outcome = "allowed"; }, function(err) { outcome = err.name + ": " + err.message; });
+	`
+
+	r := New(script)
+	r.vm.Set("BASE", srv.URL)
+	runToIdle(t, r)
+	if got := r.vm.Get("outcome").String(); got != "TypeError: fetch failed: host not allowed: 127.0.0.1" {
+		t.Errorf("expected rejection without network capability, got %q", got)
+	}
+
+	r = New(script, WithAllowedHosts("example.com", "*.example.org"))
+	r.vm.Set("BASE", srv.URL)
+	runToIdle(t, r)
+	if got := r.vm.Get("outcome").String(); got == "allowed" {
+		t.Error("fetch to a host outside the allowlist should be rejected")
+	}
+}
+
+func TestFetchAbort(t *testing.T) {
+	srv := newEchoServer(t)
+	r := New(`
+		var outcome, early;
+		var controller = new AbortController();
+		fetch(BASE + "/slow", { signal: controller.signal }).catch(function(err) { outcome = err.name; });
+		setTimeout(function() { controller.abort(); }, 10);
+
+		fetch(BASE + "/echo", { signal: AbortSignal.abort("stop") }).catch(function(reason) { early = reason; });
+
+		var kept = new AbortController();
+		fetch(BASE + "/echo", { signal: kept.signal });
+	`, WithAllowedHosts("*"))
+	r.vm.Set("BASE", srv.URL)
+
+	start := time.Now()
+	runToIdle(t, r)
+	if time.Since(start) > 2*time.Second {
+		t.Error("aborted fetch did not return early")
+	}
+	if got := r.vm.Get("outcome").String(); got != "AbortError" {
+		t.Errorf("expected AbortError, got %q", got)
+	}
+	if got := r.vm.Get("early").String(); got != "stop" {
+		t.Errorf("expected already-aborted signal to reject with its reason, got %q", got)
+	}
+	if got, _ := r.vm.RunString("kept.signal._listeners.length"); got.ToInteger() != 0 {
+		t.Errorf("a settled fetch should stop listening to its signal, %v listeners left", got)
+	}
+}
+
+func TestFetchEndsWithRuntime(t *testing.T) {
+	started, closed := make(chan struct{}), make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		close(started)
+		<-req.Context().Done()
+		close(closed)
+	}))
+	defer srv.Close()
+	r := New(`fetch(BASE).catch(() => {});`, WithAllowedHosts("*"))
+	r.vm.Set("BASE", srv.URL)
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	if err := r.Shutdown(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Error("shutting down should cancel outbound requests")
+	}
+}
+
+func TestHostAllowed(t *testing.T) {
+	r := New("", WithAllowedHosts("api.example.com", "*.example.org"))
+	cases := map[string]bool{
+		"api.example.com":   true,
+		"API.EXAMPLE.COM":   true,
+		"www.example.com":   false,
+		"a.b.example.org":   true,
+		"example.org":       false,
+		"evilexample.org":   false,
+		"api.example.com.x": false,
+	}
+	for host, want := range cases {
+		if got := r.hostAllowed(host); got != want {
+			t.Errorf("hostAllowed(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"orvalho/pkg/actor"
+
+	"github.com/dop251/goja"
+)
+
+// MaxRequestBodySize is the largest request body ServeHTTP hands to an actor.
+const MaxRequestBodySize = 16 << 20
+
+var (
+	// ErrNoFetchHandler is returned when the actor has neither a default.fetch export nor a "fetch" listener.
+	ErrNoFetchHandler = errors.New("actor has no fetch handler")
+	// ErrNoResponse is returned when the actor went idle without responding.
+	ErrNoResponse = errors.New("actor did not respond")
+)
+
+// response is a JS Response converted to Go.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// fetchResult carries the outcome of a fetch event back to ServeHTTP.
+type fetchResult struct {
+	response *response
+	err      error
+}
+
+// ServeHTTP turns req into a JS Request, dispatches it as a "fetch" event
+// and writes back the Response the actor responds with, like a Service Worker.
+// It drives the event loop itself until the response settles, so it works
+// whether or not a Scheduler is also ticking the runtime.
+func (r *Runtime) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !r.enter() {
+		http.Error(w, actor.ErrShutdown.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer r.inflight.Done()
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, MaxRequestBodySize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	// Only the first outcome counts; later ones (e.g. a listener throwing
+	// after another one responded) are dropped.
+	done := make(chan fetchResult, 1)
+	finish := func(result fetchResult) {
+		select {
+		case done <- result:
+		default:
+		}
+	}
+	r.enqueue(func() error {
+		if err := r.dispatchFetch(req, body, finish); err != nil {
+			finish(fetchResult{err: err})
+		}
+		return nil
+	})
+
+	result, err := r.runUntil(req.Context(), done)
+	if err == nil {
+		err = result.err
+	}
+	switch {
+	case errors.Is(err, ErrNoFetchHandler):
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	case errors.Is(err, actor.ErrShutdown):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case req.Context().Err() != nil:
+		return // nobody is left to answer
+	case err != nil:
+		// The details may hold script internals, keep them to the logs.
+		r.logger.Error("fetch failed", "method", req.Method, "url", req.URL.String(), "error", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	resp := result.response
+	for name, values := range resp.header {
+		w.Header()[name] = values
+	}
+	w.WriteHeader(resp.status)
+	if req.Method != http.MethodHead {
+		w.Write(resp.body)
+	}
+}
+
+// runUntil ticks the runtime until done receives a result or ctx is done,
+// sleeping between ticks until the next timer or external event. The wake
+// channel is shared with whoever else drives the runtime, so a wake-up
+// taken here is passed on if work is left once the result is in.
+//
+// The ticks run whatever work is due, not just this request's, so they
+// don't end with ctx, and their failures are left for the next Tick to
+// report rather than taken for this request's.
+func (r *Runtime) runUntil(ctx context.Context, done <-chan fetchResult) (fetchResult, error) {
+	defer func() {
+		r.mutex.Lock()
+		more := !r.closed && r.hasWork()
+		r.mutex.Unlock()
+		if more {
+			r.signal()
+		}
+	}()
+	for {
+		select {
+		case result := <-done:
+			return result, nil
+		default:
+		}
+
+		more, err := r.serveStep()
+		if errors.Is(err, actor.ErrShutdown) {
+			return fetchResult{}, err
+		}
+		if err != nil {
+			r.fail(err)
+			if ctx.Err() != nil {
+				return fetchResult{}, ctx.Err()
+			}
+			continue
+		}
+
+		select {
+		case result := <-done:
+			return result, nil
+		default:
+		}
+		if !more {
+			return fetchResult{}, ErrNoResponse
+		}
+
+		var timeout <-chan time.Time
+		if deadline, ok := r.NextDeadline(); ok {
+			timeout = r.clock.After(deadline.Sub(r.clock.Now()))
+		}
+		select {
+		case result := <-done:
+			return result, nil
+		case <-ctx.Done():
+			return fetchResult{}, ctx.Err()
+		case <-r.wake:
+		case <-timeout:
+		}
+	}
+}
+
+// serveStep runs one step for ServeHTTP. Unlike Tick, it leaves a failure
+// waiting to be reported in place.
+func (r *Runtime) serveStep() (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.step(context.Background())
+}
+
+// fail keeps err for the next Tick to report and wakes the runtime's
+// driver up to take it. Only the first failure is kept, later ones are
+// logged.
+func (r *Runtime) fail(err error) {
+	r.mutex.Lock()
+	kept := r.failure == nil
+	if kept {
+		r.failure = err
+	}
+	r.mutex.Unlock()
+	if !kept {
+		r.logger.Error("tick failed while serving a request", "error", err)
+	}
+	r.signal()
+}
+
+// dispatchFetch hands req to the default export's fetch handler, or dispatches
+// it as a "fetch" event to the actor's listeners.
+// finish is called with the outcome once the response settles. Must run on the event loop.
+func (r *Runtime) dispatchFetch(req *http.Request, body []byte, finish func(fetchResult)) error {
+	request, err := r.newRequest(req, body)
+	if err != nil {
+		return err
+	}
+
+	respond := func(v goja.Value) error {
+		return r.settle(v,
+			func(v goja.Value) {
+				resp, err := r.exportResponse(v)
+				finish(fetchResult{response: resp, err: err})
+			},
+			func(reason goja.Value) {
+				finish(fetchResult{err: fmt.Errorf("fetch handler rejected: %s", reason)})
+			},
+		)
+	}
+
+	if handler, ok := r.defaultHandler("fetch"); ok {
+		result, err := handler(r.defaultExport, request, r.env, r.newExecutionContext())
+		if err != nil {
+			return err
+		}
+		return respond(result)
+	}
+
+	responded := false
+	event := r.newEvent("fetch")
+	event.Set("request", request)
+	event.Set("respondWith", func(call goja.FunctionCall) goja.Value {
+		if responded {
+			panic(r.vm.NewTypeError("respondWith has already been called"))
+		}
+		responded = true
+		if err := respond(call.Argument(0)); err != nil {
+			panic(r.vm.NewGoError(err))
+		}
+		return goja.Undefined()
+	})
+	event.Set("waitUntil", r.waitUntil)
+
+	handled, err := r.dispatchEvent("fetch", event)
+	if err != nil {
+		return err
+	}
+	if !handled {
+		return ErrNoFetchHandler
+	}
+	if !responded {
+		return ErrNoResponse
+	}
+	return nil
+}
+
+// newRequest converts an incoming Go request into a JS Request.
+func (r *Runtime) newRequest(req *http.Request, body []byte) (*goja.Object, error) {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	url := scheme + "://" + req.Host + req.URL.RequestURI()
+
+	var headers []any
+	for name, values := range req.Header {
+		for _, value := range values {
+			headers = append(headers, []any{name, value})
+		}
+	}
+
+	init := r.vm.NewObject()
+	init.Set("method", req.Method)
+	init.Set("headers", headers)
+	if len(body) > 0 && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		init.Set("body", r.vm.NewArrayBuffer(body))
+	}
+	return r.vm.New(r.vm.Get("Request"), r.vm.ToValue(url), init)
+}
+
+// exportResponse converts a JS Response into Go.
+func (r *Runtime) exportResponse(v goja.Value) (*response, error) {
+	obj, ok := v.(*goja.Object)
+	if !ok || !r.vm.InstanceOf(obj, r.vm.Get("Response").ToObject(r.vm)) {
+		return nil, fmt.Errorf("fetch handler must respond with a Response, got %s", v)
+	}
+
+	resp := &response{
+		status: int(obj.Get("status").ToInteger()),
+		header: make(http.Header),
+	}
+	if resp.status == 0 {
+		return nil, errors.New("fetch handler responded with Response.error()")
+	}
+
+	if err := r.exportHeaders(obj.Get("headers"), resp.header); err != nil {
+		return nil, err
+	}
+
+	if buf, ok := obj.Get("_body").Export().(goja.ArrayBuffer); ok {
+		// Copy, the script may still write to the buffer once we leave the event loop.
+		resp.body = append([]byte(nil), buf.Bytes()...)
+	}
+	return resp, nil
+}
+
+// exportHeaders adds the entries of a JS Headers object to header.
+func (r *Runtime) exportHeaders(headers goja.Value, header http.Header) error {
+	obj, ok := headers.(*goja.Object)
+	if !ok || !r.vm.InstanceOf(obj, r.vm.Get("Headers").ToObject(r.vm)) {
+		return fmt.Errorf("headers must be a Headers object, got %s", headers)
+	}
+	entries, ok := goja.AssertFunction(obj.Get("entries"))
+	if !ok {
+		return errors.New("headers.entries is not a function")
+	}
+	iter, err := entries(obj)
+	if err != nil {
+		return err
+	}
+	r.vm.ForOf(iter, func(pair goja.Value) bool {
+		kv := pair.ToObject(r.vm)
+		header.Add(kv.Get("0").String(), kv.Get("1").String())
+		return true
+	})
+	return nil
+}
@@ -0,0 +1,177 @@
+package js
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(t *testing.T, h http.Handler, req *http.Request) *http.Response {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec.Result()
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := New(`
+		addEventListener("fetch", function(event) {
+			var req = event.request;
+			event.respondWith(req.text().then(function(body) {
+				return new Response(req.method + " " + req.url + " " + body, {
+					status: 201,
+					headers: { "X-Echo": req.headers.get("x-test") },
+				});
+			}));
+		});
+	`)
+
+	req := httptest.NewRequest("POST", "http://phone.local/echo?x=1", strings.NewReader("hello"))
+	req.Header.Set("X-Test", "yes")
+	resp := serve(t, r, req)
+
+	if resp.StatusCode != 201 {
+		t.Errorf("expected status 201, got %d", resp.StatusCode)
+	}
+	if got := resp.Header.Get("X-Echo"); got != "yes" {
+		t.Errorf("expected X-Echo header 'yes', got %q", got)
+	}
+	if got := readBody(t, resp); got != "POST http://phone.local/echo?x=1 hello" {
+		t.Errorf("unexpected body %q", got)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("expected text/plain content type, got %q", ct)
+	}
+}
+
+func TestServeHTTPWaitsForTimers(t *testing.T) {
+	r := New(`
+		addEventListener("fetch", function(event) {
+			event.respondWith(new Promise(function(resolve) {
+				setTimeout(function() {
+					resolve(Response.json({ ok: true }));
+				}, 20);
+			}));
+		});
+	`)
+	resp := serve(t, r, httptest.NewRequest("GET", "/", nil))
+	if resp.StatusCode != 200 {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if got := readBody(t, resp); got != `{"ok":true}` {
+		t.Errorf("unexpected body %q", got)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+}
+
+func TestServeHTTPPassesOnWakeUps(t *testing.T) {
+	r := New(`
+		addEventListener("fetch", function(event) {
+			event.respondWith(new Promise(function(resolve) {
+				setTimeout(function() {
+					setTimeout(function() {}, 1000);
+					resolve(new Response("later"));
+				}, 10);
+			}));
+		});
+	`)
+	serve(t, r, httptest.NewRequest("GET", "/", nil))
+
+	// The request took the wake-up its own task signalled, and left a timer
+	// a scheduler driving the runtime must hear about.
+	select {
+	case <-r.Wake():
+	default:
+		t.Error("expected a wake-up for the work left behind")
+	}
+}
+
+func TestServeHTTPErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		status int
+	}{
+		{"no handler", `var x = 1;`, http.StatusNotImplemented},
+		{"no response", `addEventListener("fetch", function() {});`, http.StatusInternalServerError},
+		{"throws", `addEventListener("fetch", function() { throw new Error("boom"); });`, http.StatusInternalServerError},
+		{"rejects", `addEventListener("fetch", function(e) { e.respondWith(Promise.reject("nope")); });`, http.StatusInternalServerError},
+		{"not a response", `addEventListener("fetch", function(e) { e.respondWith("text"); });`, http.StatusInternalServerError},
+		{"bad headers", `addEventListener("fetch", function(e) {
+			var resp = new Response("hi");
+			resp.headers = { "x-test": "yes" };
+			e.respondWith(resp);
+		});`, http.StatusInternalServerError},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp := serve(t, New(c.script), httptest.NewRequest("GET", "/", nil))
+			body := readBody(t, resp)
+			if resp.StatusCode != c.status {
+				t.Errorf("expected status %d, got %d (%s)", c.status, resp.StatusCode, body)
+			}
+			if strings.Contains(body, "boom") {
+				t.Errorf("script errors should stay in the logs, got %q", body)
+			}
+		})
+	}
+}
+
+func TestServeHTTPLeavesOtherFailures(t *testing.T) {
+	r := New(`
+		addEventListener("message", function() { throw new Error("bad message"); });
+		addEventListener("fetch", function(event) { event.respondWith(new Response("fine")); });
+	`)
+	if _, err := r.Tick(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	r.Deliver("x")
+
+	resp := serve(t, r, httptest.NewRequest("GET", "/", nil))
+	if body := readBody(t, resp); resp.StatusCode != 200 || body != "fine" {
+		t.Errorf("a failing message should not fail the request, got %d %q", resp.StatusCode, body)
+	}
+	if _, err := r.Tick(t.Context()); err == nil || !strings.Contains(err.Error(), "bad message") {
+		t.Errorf("the next Tick should report the message's failure, got %v", err)
+	}
+	if _, err := r.Tick(t.Context()); err != nil {
+		t.Errorf("a failure should be reported once, got %v", err)
+	}
+}
+
+func TestWebAPIPolyfills(t *testing.T) {
+	r := New(`
+		var h = new Headers({ "Content-Type": "text/html" });
+		h.append("Set-Cookie", "a=1");
+		h.append("Set-Cookie", "b=2");
+		var entries = Array.from(h).map(function(e) { return e.join("="); }).join(";");
+
+		var decoded = new TextDecoder().decode(new TextEncoder().encode("orvalho ✓"));
+		var params = new URLSearchParams("a=1&b=two+words").get("b");
+	`)
+	if _, err := r.Tick(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.vm.Get("entries").String(); got != "content-type=text/html;set-cookie=a=1;set-cookie=b=2" {
+		t.Errorf("unexpected header entries %q", got)
+	}
+	if got := r.vm.Get("decoded").String(); got != "orvalho ✓" {
+		t.Errorf("TextEncoder/TextDecoder round trip failed: %q", got)
+	}
+	if got := r.vm.Get("params").String(); got != "two words" {
+		t.Errorf("unexpected URLSearchParams value %q", got)
+	}
+}
@@ -0,0 +1,244 @@
+package js
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"orvalho/pkg/actor"
+
+	"github.com/dop251/goja"
+)
+
+// DefaultRequestTimeout is how long stub.request() waits for a reply when
+// no timeout is given.
+const DefaultRequestTimeout = 30 * time.Second
+
+// ErrActorNotAllowed is returned when an actor addresses another it was not granted.
+var ErrActorNotAllowed = errors.New("actor not allowed")
+
+// pendingRequest is a stub.request() waiting for its reply.
+type pendingRequest struct {
+	target  string
+	timer   int64 // in r.requestTimers
+	resolve func(any) error
+	reject  func(any) error
+}
+
+// actorsObject builds env.ACTORS.
+func (r *Runtime) actorsObject() *goja.Object {
+	obj := r.vm.NewObject()
+	obj.Set("get", r.guard("ACTORS.get", r.getActor))
+	return obj
+}
+
+// resolveActor looks up a target the actor wants to reach. Targets are
+// reachable when the granted capabilities list the name used to address
+// them, their ID or any of their names. Unlisted targets are reported as
+// not allowed whether or not they exist.
+func (r *Runtime) resolveActor(target string) (actor.Entry, error) {
+	listed := r.caps == nil || slices.Contains(r.caps.Actors, target)
+	e, ok := r.registry.Lookup(target)
+	if ok && !listed {
+		listed = slices.Contains(r.caps.Actors, e.ID) || slices.ContainsFunc(e.Names, func(name string) bool {
+			return slices.Contains(r.caps.Actors, name)
+		})
+	}
+	switch {
+	case !listed:
+		return actor.Entry{}, fmt.Errorf("%w: %s", ErrActorNotAllowed, target)
+	case !ok:
+		return actor.Entry{}, fmt.Errorf("%w: %s", actor.ErrActorNotFound, target)
+	}
+	return e, nil
+}
+
+// sendActor delivers msg to target after checking it may be reached.
+func (r *Runtime) sendActor(target string, msg actor.Message) error {
+	e, err := r.resolveActor(target)
+	if err != nil {
+		return err
+	}
+	return r.registry.Send(e.ID, msg)
+}
+
+// getActor implements env.ACTORS.get(idOrName), returning a stub with
+// send(message) and request(message, {timeout}). Both copy the message
+// with structured clone semantics. get throws if the target can't be
+// reached; the stub looks it up again on every call.
+func (r *Runtime) getActor(call goja.FunctionCall) goja.Value {
+	target := call.Argument(0).String()
+	e, err := r.resolveActor(target)
+	if err != nil {
+		panic(r.vm.NewGoError(err))
+	}
+
+	stub := r.vm.NewObject()
+	stub.Set("id", e.ID)
+	stub.Set("send", r.guard("ACTORS.send", func(call goja.FunctionCall) goja.Value {
+		msg := actor.Message{From: r.id, Data: r.cloneValue(call.Argument(0))}
+		if err := r.sendActor(target, msg); err != nil {
+			panic(r.vm.NewGoError(err))
+		}
+		return goja.Undefined()
+	}))
+	stub.Set("request", r.guard("ACTORS.request", func(call goja.FunctionCall) goja.Value {
+		return r.request(target, call.Argument(0), call.Argument(1))
+	}))
+	return stub
+}
+
+// request sends data to target and returns a Promise for the reply. It
+// rejects with a TimeoutError if none arrives in time.
+func (r *Runtime) request(target string, data, options goja.Value) goja.Value {
+	promise, resolve, reject := r.vm.NewPromise()
+	if r.id == "" {
+		reject(r.vm.NewGoError(errors.New("requests need the actor's ID, see WithID")))
+		return r.vm.ToValue(promise)
+	}
+
+	timeout := DefaultRequestTimeout
+	if obj, ok := options.(*goja.Object); ok {
+		if ms := obj.Get("timeout"); ms != nil && !goja.IsUndefined(ms) {
+			timeout = time.Duration(ms.ToFloat() * float64(time.Millisecond))
+		}
+	}
+
+	msg := actor.Message{From: r.id}
+	if ex := r.vm.Try(func() { msg.Data = r.cloneValue(data) }); ex != nil {
+		reject(ex.Value())
+		return r.vm.ToValue(promise)
+	}
+	now := r.clock.Now()
+	id, err := actor.NewID(now, nil)
+	if err == nil {
+		msg.ID = id
+		err = r.sendActor(target, msg)
+	}
+	if err != nil {
+		reject(r.vm.NewGoError(err))
+		return r.vm.ToValue(promise)
+	}
+
+	t := r.requestTimers.Add(now.Add(timeout), 0, id)
+	r.requests[id] = pendingRequest{target: target, timer: t.ID, resolve: resolve, reject: reject}
+	return r.vm.ToValue(promise)
+}
+
+// expireRequests rejects the requests whose timeout has passed.
+func (r *Runtime) expireRequests(now time.Time) {
+	for {
+		t, ok := r.requestTimers.Pop(now)
+		if !ok {
+			return
+		}
+		r.requestTimers.Done(t, now)
+		id := t.Data.(string)
+		req := r.requests[id]
+		delete(r.requests, id)
+
+		err := r.vm.NewGoError(fmt.Errorf("request to %s timed out", req.target))
+		err.Set("name", "TimeoutError")
+		req.reject(err)
+	}
+}
+
+// dispatchActorMessage handles a message from another actor. Replies settle
+// the matching request. Other messages go to the message handler with the
+// sender's ID as ctx.from or event.from; for requests, what the default
+// export's handler returns, or what a listener passes to
+// event.respondWith(), is sent back. A request whose handler throws is
+// answered with the error, which is not the receiving actor's failure.
+func (r *Runtime) dispatchActorMessage(msg actor.Message) error {
+	if msg.ReplyTo != "" {
+		r.settleRequest(msg)
+		return nil
+	}
+
+	data := r.importClone(msg.Data)
+	if handler, ok := r.defaultHandler("message"); ok {
+		ctx := r.newExecutionContext()
+		ctx.Set("from", msg.From)
+		result, err := handler(r.defaultExport, data, r.env, ctx)
+		return r.answer(msg, result, err)
+	}
+
+	event := r.newEvent("message")
+	event.Set("data", data)
+	event.Set("from", msg.From)
+	var response goja.Value = goja.Null()
+	event.Set("respondWith", func(call goja.FunctionCall) goja.Value {
+		response = call.Argument(0)
+		return goja.Undefined()
+	})
+	_, err := r.dispatchEvent("message", event)
+	return r.answer(msg, response, err)
+}
+
+// answer replies to msg, if it is a request, with result or err.
+func (r *Runtime) answer(msg actor.Message, result goja.Value, err error) error {
+	switch {
+	case msg.ID == "" || r.callContext().Err() != nil:
+		return err
+	case err != nil:
+		r.replyError(msg, err)
+	default:
+		r.reply(msg, result)
+	}
+	return nil
+}
+
+// reply sends the settled value of result, a value or a Promise, back to
+// the sender of request.
+func (r *Runtime) reply(request actor.Message, result goja.Value) {
+	err := r.settle(result, func(value goja.Value) {
+		response := actor.Message{From: r.id, ReplyTo: request.ID}
+		if ex := r.vm.Try(func() { response.Data = r.cloneValue(value) }); ex != nil {
+			r.replyError(request, ex)
+			return
+		}
+		r.sendReply(request, response)
+	}, func(reason goja.Value) {
+		r.sendReply(request, actor.Message{From: r.id, ReplyTo: request.ID, Error: reason.String()})
+	})
+	if err != nil {
+		r.replyError(request, err)
+	}
+}
+
+// replyError tells the sender of request, if it is waiting, that it failed.
+func (r *Runtime) replyError(request actor.Message, err error) {
+	if request.ID == "" {
+		return
+	}
+	var ex *goja.Exception
+	reason := err.Error()
+	if errors.As(err, &ex) {
+		reason = ex.Value().String()
+	}
+	r.sendReply(request, actor.Message{From: r.id, ReplyTo: request.ID, Error: reason})
+}
+
+func (r *Runtime) sendReply(request actor.Message, response actor.Message) {
+	if err := r.registry.Send(request.From, response); err != nil {
+		r.logger.Warn("reply not delivered", "to", request.From, "error", err)
+	}
+}
+
+// settleRequest resolves or rejects the request a reply answers. Replies
+// arriving after the request timed out are dropped.
+func (r *Runtime) settleRequest(msg actor.Message) {
+	req, ok := r.requests[msg.ReplyTo]
+	if !ok {
+		return
+	}
+	delete(r.requests, msg.ReplyTo)
+	r.requestTimers.Clear(req.timer)
+
+	if msg.Error != "" {
+		req.reject(r.vm.NewGoError(errors.New(msg.Error)))
+		return
+	}
+	req.resolve(r.importClone(msg.Data))
+}
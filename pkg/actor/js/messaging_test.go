@@ -0,0 +1,146 @@
+package js
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/manifest"
+)
+
+// spawn creates a runtime registered in registry under name.
+func spawn(t *testing.T, registry *actor.Registry, name, source string, opts ...Option) *Runtime {
+	t.Helper()
+	id, err := actor.NewID(time.Now(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadFiles(map[string]string{"main.js": source}, "main.js",
+		append([]Option{WithID(id), WithRegistry(registry)}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := registry.RegisterAs(id, r, name); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// tickAll ticks the runtimes in turn until none of them has work left.
+func tickAll(t *testing.T, runtimes ...*Runtime) {
+	t.Helper()
+	for range 100 {
+		busy := false
+		for _, r := range runtimes {
+			more, err := r.Tick(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			busy = busy || more
+		}
+		if !busy {
+			return
+		}
+	}
+	t.Fatal("runtimes did not go idle")
+}
+
+const notesSource = `
+	export default {
+		message(data, env, ctx) {
+			if (data.op === "fail") throw new Error("no such note");
+			if (data.op === "get") return Promise.resolve({ from: ctx.from === globalThis.caller, title: "groceries", tags: ["a", "b"] });
+			globalThis.received = JSON.stringify(data);
+		},
+	};
+`
+
+func TestActorMessaging(t *testing.T) {
+	registry := actor.NewRegistry(actor.RegistryConfig{})
+	notes := spawn(t, registry, "notes", notesSource)
+	photos := spawn(t, registry, "photos", `
+		export default {
+			async message(data, env) {
+				const notes = env.ACTORS.get("notes");
+				const message = { op: "put", when: new Date(0), bytes: new Uint8Array([1, 2]) };
+				notes.send(message);
+				message.op = "mutated";
+
+				globalThis.reply = JSON.stringify(await notes.request({ op: "get" }));
+				try {
+					await notes.request({ op: "fail" });
+				} catch (e) {
+					globalThis.failure = e.message;
+				}
+				for (const [name, fn] of [
+					["missing", () => env.ACTORS.get("nobody")],
+					["denied", () => env.ACTORS.get("secrets")],
+					["clone", () => notes.send({ fn() {} })],
+				]) {
+					try {
+						fn();
+					} catch (e) {
+						globalThis[name] = e.message || e.name;
+					}
+				}
+			},
+		};
+	`, WithCapabilities(manifest.CapabilitySet{Actors: []string{"notes", "nobody"}}))
+	spawn(t, registry, "secrets", notesSource)
+	notes.vm.Set("caller", photos.id)
+
+	photos.Deliver("go")
+	tickAll(t, photos, notes)
+
+	if got := notes.vm.Get("received").String(); got != `{"op":"put","when":"1970-01-01T00:00:00.000Z","bytes":{}}` {
+		t.Errorf("unexpected message %s", got)
+	}
+	if got := photos.vm.Get("reply").String(); got != `{"from":true,"title":"groceries","tags":["a","b"]}` {
+		t.Errorf("unexpected reply %s", got)
+	}
+	for name, want := range map[string]string{
+		"failure": "Error: no such note",
+		"missing": "actor not found: nobody",
+		"denied":  "actor not allowed: secrets",
+		"clone":   "functions could not be cloned",
+	} {
+		if got := photos.vm.Get(name); got == nil || got.String() != want {
+			t.Errorf("%s = %v, want %q", name, got, want)
+		}
+	}
+}
+
+func TestActorRequestTimeout(t *testing.T) {
+	clock := actor.NewVirtualClock(time.Unix(0, 0))
+	registry := actor.NewRegistry(actor.RegistryConfig{})
+	spawn(t, registry, "notes", notesSource)
+	photos := spawn(t, registry, "photos", `
+		export default {
+			message(data, env) {
+				env.ACTORS.get("notes").request({ op: "get" }, { timeout: 50 })
+					.catch(e => { globalThis.timedOut = e.name + ": " + e.message; });
+			},
+		};
+	`, WithClock(clock))
+
+	photos.Deliver("go")
+	ctx := context.Background()
+	if more, err := photos.Tick(ctx); err != nil || !more {
+		t.Fatalf("a pending request is work, got %v %v", more, err)
+	}
+	if more, err := photos.Tick(ctx); err != nil || !more {
+		t.Fatalf("a pending request is work, got %v %v", more, err)
+	}
+	if deadline, ok := photos.NextDeadline(); !ok || !deadline.Equal(time.Unix(0, 0).Add(50*time.Millisecond)) {
+		t.Errorf("unexpected deadline %v", deadline)
+	}
+
+	clock.Advance(50 * time.Millisecond)
+	if more, err := photos.Tick(ctx); err != nil || more {
+		t.Fatalf("the request should have expired, got %v %v", more, err)
+	}
+	if got := photos.vm.Get("timedOut"); got == nil || got.String() != "TimeoutError: request to notes timed out" {
+		t.Errorf("unexpected rejection %v", got)
+	}
+}
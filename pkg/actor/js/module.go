@@ -0,0 +1,178 @@
+package js
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"testing/fstest"
+
+	"github.com/dop251/goja"
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+// handlerNames are the default export methods the runtime knows how to call.
+var handlerNames = []string{"fetch", "message", "shutdown"}
+
+// ErrBareSpecifier is returned when a module imports a package by name instead of by path.
+// Bundles are expected to ship their dependencies already bundled.
+var ErrBareSpecifier = errors.New("bare module specifiers are not supported")
+
+// LoadDir creates a runtime from the ES module graph rooted at entry inside dir.
+func LoadDir(dir, entry string, opts ...Option) (*Runtime, error) {
+	return Load(os.DirFS(dir), entry, opts...)
+}
+
+// LoadFiles creates a runtime from an in-memory module graph, keyed by path.
+func LoadFiles(files map[string]string, entry string, opts ...Option) (*Runtime, error) {
+	fsys := make(fstest.MapFS, len(files))
+	for name, src := range files {
+		fsys[strings.TrimPrefix(path.Clean("/"+name), "/")] = &fstest.MapFile{Data: []byte(src)}
+	}
+	return Load(fsys, entry, opts...)
+}
+
+// Load creates a runtime from the ES module graph rooted at entry inside fsys.
+// Relative and absolute specifiers are resolved inside fsys; the graph is
+// linked eagerly so syntax and resolution errors are reported here, while the
+// module body only runs on the first Tick, like a classic script.
+//
+// The graph is bundled into one script with esbuild: goja has no module
+// records (only its sobek fork does), and swapping engines is not worth it.
+// Bundling hoists every module into one scope, so imports stay live
+// bindings and cycles work, but top-level await is rejected here. esbuild
+// costs about 5 MiB of a stripped arm64 binary.
+func Load(fsys fs.FS, entry string, opts ...Option) (*Runtime, error) {
+	entry = strings.TrimPrefix(path.Clean("/"+entry), "/")
+	result := api.Build(api.BuildOptions{
+		EntryPoints: []string{entry},
+		Bundle:      true,
+		Write:       false,
+		Format:      api.FormatCommonJS,
+		Platform:    api.PlatformNeutral,
+		Target:      api.ES2020,
+		Charset:     api.CharsetUTF8,
+		LogLevel:    api.LogLevelSilent,
+		Plugins:     []api.Plugin{fsPlugin(fsys)},
+	})
+	if len(result.Errors) > 0 {
+		msgs := api.FormatMessages(result.Errors, api.FormatMessagesOptions{Kind: api.ErrorMessage})
+		return nil, fmt.Errorf("failed to load module %s:\n%s", entry, strings.Join(msgs, ""))
+	}
+	if len(result.OutputFiles) != 1 {
+		return nil, fmt.Errorf("failed to load module %s: expected one output, got %d", entry, len(result.OutputFiles))
+	}
+
+	r := New(string(result.OutputFiles[0].Contents), opts...)
+	r.name = entry
+	r.module = true
+	return r, nil
+}
+
+// fsPlugin resolves and loads every module from fsys.
+func fsPlugin(fsys fs.FS) api.Plugin {
+	return api.Plugin{
+		Name: "orvalho-fs",
+		Setup: func(build api.PluginBuild) {
+			build.OnResolve(api.OnResolveOptions{Filter: ".*"}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
+				var p string
+				switch {
+				case args.Kind == api.ResolveEntryPoint:
+					p = args.Path
+				case strings.HasPrefix(args.Path, "./"), strings.HasPrefix(args.Path, "../"):
+					p = path.Join(path.Dir(args.Importer), args.Path)
+				case strings.HasPrefix(args.Path, "/"):
+					p = args.Path
+				default:
+					return api.OnResolveResult{}, fmt.Errorf("%w: %q", ErrBareSpecifier, args.Path)
+				}
+				// Cleaning a rooted path keeps "../" from escaping the bundle.
+				resolved, err := resolveFile(fsys, strings.TrimPrefix(path.Clean("/"+p), "/"))
+				if err != nil {
+					return api.OnResolveResult{}, err
+				}
+				return api.OnResolveResult{Path: "/" + resolved, Namespace: "orvalho"}, nil
+			})
+			build.OnLoad(api.OnLoadOptions{Filter: ".*", Namespace: "orvalho"}, func(args api.OnLoadArgs) (api.OnLoadResult, error) {
+				data, err := fs.ReadFile(fsys, strings.TrimPrefix(args.Path, "/"))
+				if err != nil {
+					return api.OnLoadResult{}, err
+				}
+				contents := string(data)
+				return api.OnLoadResult{Contents: &contents, Loader: loaderFor(args.Path)}, nil
+			})
+		},
+	}
+}
+
+// resolveFile finds the file a specifier refers to, trying the usual extensions and index files.
+func resolveFile(fsys fs.FS, name string) (string, error) {
+	candidates := []string{name, name + ".js", name + ".mjs", name + ".ts", path.Join(name, "index.js"), path.Join(name, "index.mjs")}
+	for _, candidate := range candidates {
+		if info, err := fs.Stat(fsys, candidate); err == nil && !info.IsDir() {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("module %q: %w", name, fs.ErrNotExist)
+}
+
+func loaderFor(name string) api.Loader {
+	switch path.Ext(name) {
+	case ".ts", ".mts":
+		return api.LoaderTS
+	case ".json":
+		return api.LoaderJSON
+	case ".txt":
+		return api.LoaderText
+	}
+	return api.LoaderJS
+}
+
+// runModule evaluates the bundled module graph and records its exports.
+func (r *Runtime) runModule() error {
+	fn, err := r.vm.RunScript(r.name, "(function (module, exports) {"+r.script+"\n})")
+	if err != nil {
+		return err
+	}
+	call, _ := goja.AssertFunction(fn)
+
+	module := r.vm.NewObject()
+	module.Set("exports", r.vm.NewObject())
+	if _, err := call(goja.Undefined(), module, module.Get("exports")); err != nil {
+		return err
+	}
+
+	exports := module.Get("exports").ToObject(r.vm)
+	if def, ok := exports.Get("default").(*goja.Object); ok {
+		r.defaultExport = def
+	}
+	return nil
+}
+
+// defaultHandler returns the named method of the entry module's default export, if any.
+func (r *Runtime) defaultHandler(name string) (goja.Callable, bool) {
+	if r.defaultExport == nil {
+		return nil, false
+	}
+	return goja.AssertFunction(r.defaultExport.Get(name))
+}
+
+// Handlers reports which event handlers (e.g. "fetch") the entry module's
+// default export provides. It is empty for classic scripts and before the
+// first Tick evaluates the module.
+func (r *Runtime) Handlers() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var names []string
+	for _, name := range handlerNames {
+		if _, ok := r.defaultHandler(name); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,136 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadDir(t *testing.T) {
+	r, err := LoadDir("testdata/bundle", "main.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Handlers(); got != nil {
+		t.Errorf("Handlers should be empty before the module runs, got %v", got)
+	}
+
+	resp := serve(t, r, httptest.NewRequest("GET", "/", nil))
+	if got := readBody(t, resp); got != "hello, orvalho" {
+		t.Errorf("unexpected body %q", got)
+	}
+	if got := r.Handlers(); !reflect.DeepEqual(got, []string{"fetch"}) {
+		t.Errorf("expected [fetch] handlers, got %v", got)
+	}
+}
+
+func TestLoadFilesMessageHandler(t *testing.T) {
+	r, err := LoadFiles(map[string]string{
+		"src/index.js": `
+			import { count } from "../shared/state";
+			export default {
+				message(data, env, ctx) {
+					count.push(data);
+					globalThis.seen = count.join(",");
+				},
+			};
+		`,
+		"shared/state/index.js": `export const count = [];`,
+	}, "src/index.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	r.Deliver("a")
+	r.Deliver("b")
+	for i := 0; i < 2; i++ {
+		if _, err := r.Tick(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := r.vm.Get("seen").String(); got != "a,b" {
+		t.Errorf("unexpected messages %q", got)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	_, err := LoadFiles(map[string]string{"main.js": `import lodash from "lodash";`}, "main.js")
+	if err == nil || !strings.Contains(err.Error(), ErrBareSpecifier.Error()) {
+		t.Errorf("expected bare specifier error, got %v", err)
+	}
+
+	_, err = LoadFiles(map[string]string{"main.js": `export default {`}, "main.js")
+	if err == nil || !strings.Contains(err.Error(), "main.js") {
+		t.Errorf("expected syntax error mentioning main.js, got %v", err)
+	}
+
+	// Paths can't escape the bundle root.
+	_, err = LoadFiles(map[string]string{"main.js": `import "../../etc/passwd";`}, "main.js")
+	if err == nil {
+		t.Error("expected resolution error for a path outside the bundle")
+	}
+
+	if _, err := LoadDir("testdata/bundle", "missing.js"); err == nil {
+		t.Error("expected error for a missing entry point")
+	}
+}
+
+func TestModuleRuntimeError(t *testing.T) {
+	r, err := LoadFiles(map[string]string{"main.js": `throw new Error("boom"); export default {};`}, "main.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Tick(context.Background())
+	if err == nil || errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("expected module evaluation error, got %v", err)
+	}
+}
+
+func TestModuleSemantics(t *testing.T) {
+	r, err := LoadFiles(map[string]string{
+		"main.js": `
+			import { count, increment } from "./counter.js";
+			import * as counter from "./counter.js";
+			import { ping } from "./a.js";
+			increment();
+			globalThis.live = [count, counter.count].join(",");
+			globalThis.cycle = ping(3);
+			export default {};
+		`,
+		"counter.js": `
+			export let count = 0;
+			export function increment() { count++; }
+		`,
+		// Cyclic imports see each other's hoisted functions.
+		"a.js": `
+			import { pong } from "./b.js";
+			export function ping(n) { return n === 0 ? "a" : pong(n - 1); }
+		`,
+		"b.js": `
+			import { ping } from "./a.js";
+			export function pong(n) { return n === 0 ? "b" : ping(n - 1); }
+		`,
+	}, "main.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.vm.Get("live").String(); got != "1,1" {
+		t.Errorf("imports should be live bindings, got %s", got)
+	}
+	if got := r.vm.Get("cycle").String(); got != "b" {
+		t.Errorf("unexpected result across a cycle %q", got)
+	}
+
+	// Top-level await is not supported, and says so at load time.
+	_, err = LoadFiles(map[string]string{"main.js": `await Promise.resolve(); export default {};`}, "main.js")
+	if err == nil || !strings.Contains(err.Error(), "Top-level await") {
+		t.Errorf("expected top-level await to be rejected, got %v", err)
+	}
+}
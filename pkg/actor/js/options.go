@@ -0,0 +1,156 @@
+package js
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/binding"
+	"orvalho/pkg/actor/manifest"
+)
+
+// Option configures a Runtime.
+type Option func(*Runtime)
+
+// WithMailboxSize sets how many undelivered messages the actor's mailbox holds.
+func WithMailboxSize(size int) Option {
+	return func(r *Runtime) {
+		r.mailbox = actor.NewMailbox(size)
+	}
+}
+
+// WithHTTPClient sets the client fetch() uses. Defaults to http.DefaultClient.
+func WithHTTPClient(client *http.Client) Option {
+	return func(r *Runtime) {
+		r.httpClient = client
+	}
+}
+
+// WithAllowedHosts grants the actor network access to the given hosts.
+// Patterns are exact host names, "*.example.com" for any subdomain, or "*"
+// for any host. Without it every fetch() is rejected.
+func WithAllowedHosts(hosts ...string) Option {
+	return func(r *Runtime) {
+		r.allowedHosts = append(r.allowedHosts, hosts...)
+	}
+}
+
+// WithID sets the actor ID attached to the runtime's logs.
+func WithID(id string) Option {
+	return func(r *Runtime) {
+		r.id = id
+	}
+}
+
+// WithVersion sets the bundle version attached to the runtime's logs.
+func WithVersion(version string) Option {
+	return func(r *Runtime) {
+		r.version = version
+	}
+}
+
+// WithLogger sets where console output goes. Defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Runtime) {
+		r.logger = logger
+	}
+}
+
+// WithLogBuffer sets the ring buffer keeping the actor's recent logs.
+func WithLogBuffer(logs *actor.LogBuffer) Option {
+	return func(r *Runtime) {
+		r.logs = logs
+	}
+}
+
+// WithClock sets the clock timers run on. Defaults to actor.RealClock;
+// an actor.VirtualClock makes setTimeout/setInterval deterministic.
+func WithClock(clock actor.Clock) Option {
+	return func(r *Runtime) {
+		r.clock = clock
+	}
+}
+
+// WithTickBudget bounds how long a single Tick may run scripts for.
+// A Tick that overruns it is interrupted and returns a *BudgetExceededError.
+func WithTickBudget(limit time.Duration) Option {
+	return func(r *Runtime) {
+		r.tickBudget.limit = limit
+	}
+}
+
+// WithHandlerBudget bounds how long a single event handler (the initial
+// script, a timer callback, a message or fetch handler) may run for.
+func WithHandlerBudget(limit time.Duration) Option {
+	return func(r *Runtime) {
+		r.handlerBudget.limit = limit
+	}
+}
+
+// WithCapabilities restricts the runtime to what caps grants: timers are only
+// installed when granted, fetch() only reaches the granted hosts, and env
+// only carries bindings for granted capabilities. Non-zero limits set the
+// mailbox size and budgets; options after it can still override them.
+func WithCapabilities(caps manifest.CapabilitySet) Option {
+	return func(r *Runtime) {
+		caps = caps.Normalize()
+		r.caps = &caps
+
+		r.allowedHosts = nil
+		if caps.Network != nil {
+			r.allowedHosts = caps.Network.Hosts
+		}
+		if caps.Limits.MailboxSize > 0 {
+			r.mailbox = actor.NewMailbox(caps.Limits.MailboxSize)
+		}
+		if caps.Limits.TickBudget > 0 {
+			r.tickBudget.limit = time.Duration(caps.Limits.TickBudget)
+		}
+		if caps.Limits.HandlerBudget > 0 {
+			r.handlerBudget.limit = time.Duration(caps.Limits.HandlerBudget)
+		}
+	}
+}
+
+// WithSecrets provides secret values. Only the secrets named in the granted
+// capabilities are exposed, as string properties of env.
+func WithSecrets(secrets map[string]string) Option {
+	return func(r *Runtime) {
+		r.secrets = secrets
+	}
+}
+
+// WithDevices provides native device APIs. Only granted devices are exposed,
+// as env.DEVICES.<name>.
+func WithDevices(devices map[string]Device) Option {
+	return func(r *Runtime) {
+		r.devices = devices
+	}
+}
+
+// WithBinding exposes a host binding on env under its upper-cased name, e.g.
+// env.KV for a binding named "kv". The host decides which bindings an actor
+// gets; they are not gated by WithCapabilities.
+func WithBinding(b binding.Binding) Option {
+	return func(r *Runtime) {
+		r.bindings = append(r.bindings, b)
+	}
+}
+
+// WithRegistry lets the actor message the actors in registry through
+// env.ACTORS, limited to those its capabilities list. Replies are addressed
+// to the ID set with WithID, which should be the one the actor is
+// registered under.
+func WithRegistry(registry *actor.Registry) Option {
+	return func(r *Runtime) {
+		r.registry = registry
+	}
+}
+
+// withWake makes the runtime signal wake instead of a channel of its own.
+func withWake(wake chan struct{}) Option {
+	return func(r *Runtime) {
+		r.wake = wake
+	}
+}
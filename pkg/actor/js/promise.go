@@ -0,0 +1,56 @@
+package js
+
+import (
+	"github.com/dop251/goja"
+)
+
+// UnhandledRejectionError is returned by Tick when a promise was rejected
+// and neither a handler nor an "unhandledrejection" listener dealt with it.
+type UnhandledRejectionError struct {
+	// Reason is the rejection reason, rendered as text.
+	Reason string
+}
+
+func (e *UnhandledRejectionError) Error() string {
+	return "unhandled promise rejection: " + e.Reason
+}
+
+// trackRejection records promises rejected without a handler, and forgets
+// them if a handler is attached later.
+func (r *Runtime) trackRejection(p *goja.Promise, op goja.PromiseRejectionOperation) {
+	switch op {
+	case goja.PromiseRejectionReject:
+		r.rejections = append(r.rejections, p)
+	case goja.PromiseRejectionHandle:
+		for i, rejected := range r.rejections {
+			if rejected == p {
+				r.rejections = append(r.rejections[:i], r.rejections[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// checkRejections reports promises that are still unhandled once the
+// current macrotask and its microtasks finished. Rejections are dispatched as
+// "unhandledrejection" events; without listeners the first one becomes an
+// *UnhandledRejectionError.
+func (r *Runtime) checkRejections() error {
+	for len(r.rejections) > 0 {
+		p := r.rejections[0]
+		r.rejections = r.rejections[1:]
+
+		event := r.newEvent("unhandledrejection")
+		event.Set("promise", p)
+		event.Set("reason", p.Result())
+		handled, err := r.dispatchEvent("unhandledrejection", event)
+		if err != nil {
+			return err
+		}
+		if !handled {
+			r.rejections = nil
+			return &UnhandledRejectionError{Reason: r.inspect(p.Result(), true)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestUnhandledRejection(t *testing.T) {
+	r := New(`Promise.reject(new Error("lost"));`)
+	_, err := r.Tick(context.Background())
+
+	var rejection *UnhandledRejectionError
+	if !errors.As(err, &rejection) {
+		t.Fatalf("expected UnhandledRejectionError, got %v", err)
+	}
+	if rejection.Reason == "" {
+		t.Error("expected the rejection reason to be reported")
+	}
+}
+
+func TestUnhandledRejectionInTimer(t *testing.T) {
+	r := New(`
+		setTimeout(async function() {
+			throw new Error("async failure");
+		}, 0);
+	`)
+	ctx := context.Background()
+	if _, err := r.Tick(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var err error
+	for i := 0; i < 100 && err == nil; i++ {
+		var more bool
+		more, err = r.Tick(ctx)
+		if !more && err == nil {
+			break
+		}
+	}
+	var rejection *UnhandledRejectionError
+	if !errors.As(err, &rejection) {
+		t.Fatalf("expected UnhandledRejectionError from the timer tick, got %v", err)
+	}
+}
+
+func TestLateHandledRejection(t *testing.T) {
+	r := New(`
+		var p = Promise.reject("late");
+		var caught;
+		p.catch(function(reason) { caught = reason; });
+	`)
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatalf("a rejection handled in the same tick should not fail: %v", err)
+	}
+	if got := r.vm.Get("caught").String(); got != "late" {
+		t.Errorf("expected catch handler to run, got %q", got)
+	}
+}
+
+func TestUnhandledRejectionEvent(t *testing.T) {
+	r := New(`
+		var reasons = [];
+		addEventListener("unhandledrejection", function(event) {
+			reasons.push(event.reason);
+		});
+		Promise.reject("one");
+		queueMicrotask(function() { throw "two"; });
+	`)
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatalf("listener should handle the rejection: %v", err)
+	}
+	if got := r.vm.Get("reasons").Export(); len(got.([]any)) != 2 {
+		t.Errorf("expected two unhandledrejection events, got %v", got)
+	}
+}
+
+func TestQueueMicrotask(t *testing.T) {
+	r := New(`
+		var order = [];
+		queueMicrotask(function() { order.push("micro"); });
+		order.push("sync");
+	`)
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.vm.Get("order").Export().([]any); len(got) != 2 || got[0] != "sync" || got[1] != "micro" {
+		t.Errorf("unexpected order %v", got)
+	}
+
+	r = New(`queueMicrotask("not a function");`)
+	if _, err := r.Tick(context.Background()); err == nil {
+		t.Error("queueMicrotask should throw for non-functions")
+	}
+}
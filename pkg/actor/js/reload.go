@@ -0,0 +1,359 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"orvalho/pkg/actor"
+)
+
+const (
+	// DefaultReloadInterval is how often a Reloader polls its directory for changes.
+	DefaultReloadInterval = 500 * time.Millisecond
+	// DefaultDrainTimeout bounds how long a replaced runtime may keep finishing its work.
+	DefaultDrainTimeout = 10 * time.Second
+)
+
+// drainPollInterval is how often a replaced runtime is ticked while it drains.
+const drainPollInterval = 10 * time.Millisecond
+
+// ReloaderConfig configures a Reloader.
+type ReloaderConfig struct {
+	// Dir holds the bundle sources; Entry is the entry module inside it.
+	Dir   string
+	Entry string
+	// Options are applied to every Runtime the reloader creates.
+	Options []Option
+	// PollInterval defaults to DefaultReloadInterval.
+	PollInterval time.Duration
+	// DrainTimeout defaults to DefaultDrainTimeout.
+	DrainTimeout time.Duration
+	// Logger receives reload and build error reports. Defaults to slog.Default().
+	Logger *slog.Logger
+}
+
+// Reloader is a development actor that runs the module graph in a directory
+// and swaps in a fresh Runtime whenever the files change. A version that
+// fails to build is reported and the previous one keeps running. The old
+// runtime finishes its in-flight requests and queued messages in the
+// background; timers coming due meanwhile still fire, and those still
+// pending once it drained are dropped.
+//
+// Reloader implements the same interfaces as Runtime, so it can be spawned
+// on a Scheduler and served over HTTP in its place.
+type Reloader struct {
+	config ReloaderConfig
+	wake   chan struct{} // shared by every generation, so waiters follow swaps
+
+	reloading sync.Mutex // serializes Reload
+	mu        sync.Mutex
+	current   *generation
+	snapshot  map[string]fileStamp
+	lastErr   error
+	draining  sync.WaitGroup
+}
+
+// generation is one Runtime built by a Reloader.
+type generation struct {
+	runtime  *Runtime
+	inflight sync.WaitGroup // Ticks and ServeHTTP calls still using the runtime
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+var (
+	_ actor.Actor      = (*Reloader)(nil)
+	_ actor.Waiter     = (*Reloader)(nil)
+	_ actor.Receiver   = (*Reloader)(nil)
+	_ actor.Shutdowner = (*Reloader)(nil)
+	_ http.Handler     = (*Reloader)(nil)
+)
+
+// NewReloader builds the initial version. It fails if that version does not build.
+func NewReloader(config ReloaderConfig) (*Reloader, error) {
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultReloadInterval
+	}
+	if config.DrainTimeout <= 0 {
+		config.DrainTimeout = DefaultDrainTimeout
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	r := &Reloader{config: config, wake: make(chan struct{}, 1)}
+	snapshot, err := r.scan()
+	if err != nil {
+		return nil, err
+	}
+	rt, err := r.build()
+	if err != nil {
+		return nil, err
+	}
+	r.current = &generation{runtime: rt}
+	r.snapshot = snapshot
+	return r, nil
+}
+
+// Runtime returns the runtime currently in use.
+func (r *Reloader) Runtime() *Runtime {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current.runtime
+}
+
+// Err returns why the latest change failed to build, or nil if it built.
+func (r *Reloader) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lastErr
+}
+
+// Watch polls the directory until ctx is done, reloading on every change.
+// It waits for replaced runtimes to finish draining before returning.
+func (r *Reloader) Watch(ctx context.Context) {
+	defer r.draining.Wait()
+
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Reload()
+		}
+	}
+}
+
+// Reload swaps in a fresh runtime if the directory changed since the last
+// build. It reports whether a swap happened; a build failure is logged,
+// kept in Err and returned, and leaves the current runtime in place.
+func (r *Reloader) Reload() (bool, error) {
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+
+	snapshot, err := r.scan()
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	unchanged, lastErr := sameFiles(snapshot, r.snapshot), r.lastErr
+	r.snapshot = snapshot
+	r.mu.Unlock()
+	if unchanged {
+		return false, lastErr
+	}
+
+	// Build outside the lock so requests keep flowing to the current runtime.
+	rt, err := r.build()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastErr = err
+	if err != nil {
+		r.config.Logger.Error("reload failed, keeping the previous version", "dir", r.config.Dir, "error", err)
+		return false, err
+	}
+
+	old := r.current
+	r.current = &generation{runtime: rt}
+	r.draining.Add(1)
+	go func() {
+		defer r.draining.Done()
+		r.drain(old)
+	}()
+	r.config.Logger.Info("reloaded", "dir", r.config.Dir, "entry", r.config.Entry)
+	r.signal()
+	return true, nil
+}
+
+func (r *Reloader) build() (*Runtime, error) {
+	opts := append([]Option{withWake(r.wake)}, r.config.Options...)
+	return LoadDir(r.config.Dir, r.config.Entry, opts...)
+}
+
+// drain lets a replaced runtime finish its in-flight Ticks, requests and
+// queued messages, giving up after DrainTimeout, then releases it. A
+// runtime whose script never ran is released without running it.
+func (r *Reloader) drain(old *generation) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.config.DrainTimeout)
+	defer cancel()
+
+	calls := make(chan struct{})
+	go func() {
+		old.inflight.Wait()
+		close(calls)
+	}()
+
+	var err error
+	if !old.runtime.started() {
+		// A call in flight may still run the script; if none does, there
+		// is nothing to finish and ticking would run it only to drop it.
+		select {
+		case <-calls:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	if err == nil && old.runtime.started() {
+		err = old.runtime.drain(ctx)
+		if err == nil {
+			select {
+			case <-calls:
+				// Drain whatever the last calls queued.
+				err = old.runtime.drain(ctx)
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+		}
+	}
+	if err != nil {
+		r.config.Logger.Warn("replaced runtime did not drain cleanly", "dir", r.config.Dir, "error", err)
+	}
+	if err := old.runtime.Shutdown(ctx, false); err != nil {
+		r.config.Logger.Warn("replaced runtime did not shut down cleanly", "dir", r.config.Dir, "error", err)
+	}
+}
+
+// scan records the size and modification time of every file under Dir.
+func (r *Reloader) scan() (map[string]fileStamp, error) {
+	files := map[string]fileStamp{}
+	err := fs.WalkDir(os.DirFS(r.config.Dir), ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil // removed while walking; the next scan sees it
+		}
+		if err != nil {
+			return err
+		}
+		files[name] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		return nil
+	})
+	return files, err
+}
+
+func sameFiles(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, stamp := range a {
+		other, ok := b[name]
+		if !ok || other.size != stamp.size || !other.modTime.Equal(stamp.modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *Reloader) generation() *generation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.current
+}
+
+// enter returns the current generation, counting a call in flight on it
+// so it isn't shut down before the call returns.
+func (r *Reloader) enter() *generation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.current.inflight.Add(1)
+	return r.current
+}
+
+// Tick ticks the current runtime. A reload while the Tick runs lets it
+// finish on the runtime it started on.
+func (r *Reloader) Tick(ctx context.Context) (bool, error) {
+	g := r.enter()
+	defer g.inflight.Done()
+	return g.runtime.Tick(ctx)
+}
+
+// NextDeadline returns the deadline of the current runtime's earliest timer.
+func (r *Reloader) NextDeadline() (time.Time, bool) {
+	return r.generation().runtime.NextDeadline()
+}
+
+// Wake returns a channel signalled on events for any generation and on every swap.
+func (r *Reloader) Wake() <-chan struct{} {
+	return r.wake
+}
+
+func (r *Reloader) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Deliver queues a message for the current runtime.
+func (r *Reloader) Deliver(msg any) error {
+	return r.generation().runtime.Deliver(msg)
+}
+
+// Shutdown shuts the current runtime down, see Runtime.Shutdown, and waits
+// for replaced ones to finish draining until ctx is done. Watch should be
+// stopped first so that no new version is swapped in meanwhile.
+func (r *Reloader) Shutdown(ctx context.Context, graceful bool) error {
+	err := r.generation().runtime.Shutdown(ctx, graceful)
+
+	drained := make(chan struct{})
+	go func() {
+		r.draining.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = errors.Join(err, ctx.Err())
+	}
+	return err
+}
+
+// ServeHTTP serves req with the current runtime. A reload while the request
+// is in flight lets it finish on the runtime it started on.
+func (r *Reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	g := r.enter()
+	defer g.inflight.Done()
+
+	g.runtime.ServeHTTP(w, req)
+}
+
+// drain ticks the runtime until its queued messages, tasks and host
+// operations are done. Pending timers are not waited for.
+func (r *Runtime) drain(ctx context.Context) error {
+	for {
+		if _, err := r.Tick(ctx); err != nil {
+			return err
+		}
+		if !r.busy() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(drainPollInterval):
+		}
+	}
+}
+
+// busy reports whether messages, tasks or host operations are pending.
+func (r *Runtime) busy() bool {
+	r.mutex.Lock()
+	pendingOps := r.pendingOps
+	r.mutex.Unlock()
+
+	return r.taskCount() > 0 || r.mailbox.Len() > 0 || pendingOps > 0
+}
@@ -0,0 +1,202 @@
+package js
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var edits atomic.Int64
+
+// writeModule writes src to dir/name, bumping its modification time so the
+// change is seen even on file systems with coarse timestamps.
+func writeModule(t *testing.T, dir, name, src string) {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bump := time.Now().Add(time.Duration(edits.Add(1)) * time.Second)
+	if err := os.Chtimes(path, bump, bump); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func versionModule(version string) string {
+	return `
+		import { greeting } from "./greeting.js";
+		export default {
+			fetch() { return new Response(greeting + " ` + version + `"); },
+			message(data) { globalThis.received = data; },
+		};
+	`
+}
+
+func newTestReloader(t *testing.T, dir string) *Reloader {
+	t.Helper()
+	r, err := NewReloader(ReloaderConfig{
+		Dir:    dir,
+		Entry:  "main.js",
+		Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReloaderSwapsOnChange(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "greeting.js", `export const greeting = "hello";`)
+	writeModule(t, dir, "main.js", versionModule("v1"))
+	r := newTestReloader(t, dir)
+
+	if got := readBody(t, serve(t, r, httptest.NewRequest("GET", "/", nil))); got != "hello v1" {
+		t.Errorf("unexpected body %q", got)
+	}
+	if swapped, err := r.Reload(); swapped || err != nil {
+		t.Errorf("nothing changed, but Reload returned %v, %v", swapped, err)
+	}
+
+	// Dependencies are watched too.
+	writeModule(t, dir, "greeting.js", `export const greeting = "hi";`)
+	writeModule(t, dir, "main.js", versionModule("v2"))
+	if swapped, err := r.Reload(); !swapped || err != nil {
+		t.Fatalf("expected a swap, got %v, %v", swapped, err)
+	}
+	if got := readBody(t, serve(t, r, httptest.NewRequest("GET", "/", nil))); got != "hi v2" {
+		t.Errorf("unexpected body after reload %q", got)
+	}
+}
+
+func TestReloaderKeepsWorkingVersionOnSyntaxError(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "greeting.js", `export const greeting = "hello";`)
+	writeModule(t, dir, "main.js", versionModule("v1"))
+	r := newTestReloader(t, dir)
+	working := r.Runtime()
+
+	writeModule(t, dir, "main.js", `export default { fetch() { return new Response("broken" }`)
+	swapped, err := r.Reload()
+	if swapped || err == nil || !strings.Contains(err.Error(), "main.js") {
+		t.Fatalf("expected a build error mentioning main.js, got %v, %v", swapped, err)
+	}
+	if r.Err() == nil {
+		t.Error("Err should report the failed build")
+	}
+	if r.Runtime() != working {
+		t.Error("a failed build should not replace the runtime")
+	}
+	if got := readBody(t, serve(t, r, httptest.NewRequest("GET", "/", nil))); got != "hello v1" {
+		t.Errorf("the previous version should keep serving, got %q", got)
+	}
+
+	writeModule(t, dir, "main.js", versionModule("v3"))
+	if swapped, err := r.Reload(); !swapped || err != nil {
+		t.Fatalf("expected the fixed version to load, got %v, %v", swapped, err)
+	}
+	if r.Err() != nil {
+		t.Errorf("Err should clear after a good build, got %v", r.Err())
+	}
+}
+
+func TestReloaderDrainsOldRuntime(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "greeting.js", `export const greeting = "hello";`)
+	writeModule(t, dir, "main.js", versionModule("v1"))
+	r := newTestReloader(t, dir)
+	old := r.Runtime()
+	if _, err := old.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// A message queued before the swap is still handled by the old version.
+	if err := r.Deliver("queued"); err != nil {
+		t.Fatal(err)
+	}
+	writeModule(t, dir, "main.js", versionModule("v2"))
+	if swapped, err := r.Reload(); !swapped || err != nil {
+		t.Fatalf("expected a swap, got %v, %v", swapped, err)
+	}
+	r.draining.Wait()
+
+	if got := old.vm.Get("received"); got == nil || got.String() != "queued" {
+		t.Errorf("the old runtime should have drained its mailbox, got %v", got)
+	}
+	if r.Runtime().mailbox.Len() != 0 {
+		t.Error("the queued message should not be delivered twice")
+	}
+
+	// The reloader wakes whoever drives it so the new version starts.
+	select {
+	case <-r.Wake():
+	default:
+		t.Error("expected a wake-up after the swap")
+	}
+}
+
+func TestReloaderDoesNotStartOldRuntime(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "greeting.js", `export const greeting = "hello";`)
+	writeModule(t, dir, "main.js", `globalThis.ran = true;`+versionModule("v1"))
+	r := newTestReloader(t, dir)
+	old := r.Runtime()
+
+	writeModule(t, dir, "main.js", versionModule("v2"))
+	if swapped, err := r.Reload(); !swapped || err != nil {
+		t.Fatalf("expected a swap, got %v, %v", swapped, err)
+	}
+	r.draining.Wait()
+
+	if old.initialized || old.vm.Get("ran") != nil {
+		t.Error("a version replaced before it ran should not run while draining")
+	}
+	if !old.closed {
+		t.Error("the old runtime should be shut down")
+	}
+}
+
+func TestReloaderWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeModule(t, dir, "greeting.js", `export const greeting = "hello";`)
+	writeModule(t, dir, "main.js", versionModule("v1"))
+	r, err := NewReloader(ReloaderConfig{
+		Dir:          dir,
+		Entry:        "main.js",
+		PollInterval: 5 * time.Millisecond,
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := r.Runtime()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Watch(ctx)
+		close(done)
+	}()
+
+	writeModule(t, dir, "main.js", versionModule("v2"))
+	deadline := time.Now().Add(2 * time.Second)
+	for r.Runtime() == first && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if r.Runtime() == first {
+		t.Fatal("Watch did not pick up the change")
+	}
+	if got := readBody(t, serve(t, r, httptest.NewRequest("GET", "/", nil))); got != "hello v2" {
+		t.Errorf("unexpected body %q", got)
+	}
+}
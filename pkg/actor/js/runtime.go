@@ -1,52 +1,24 @@
 package js
 
 import (
-	"container/heap"
 	"context"
+	"log/slog"
+	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/binding"
+	"orvalho/pkg/actor/manifest"
 
 	"github.com/dop251/goja"
 )
 
-// timer represents a scheduled task (setTimeout or setInterval).
-type timer struct {
-	id       int64
-	deadline time.Time
-	callback goja.Callable
-	args     []goja.Value
-	interval time.Duration // 0 if one-shot
-	index    int           // heap index
-}
-
-// timerHeap implements heap.Interface for timers.
-type timerHeap []*timer
-
-func (h timerHeap) Len() int           { return len(h) }
-func (h timerHeap) Less(i, j int) bool { return h[i].deadline.Before(h[j].deadline) }
-func (h timerHeap) Swap(i, j int) {
-	h[i], h[j] = h[j], h[i]
-	h[i].index = i
-	h[j].index = j
-}
-
-func (h *timerHeap) Push(x interface{}) {
-	n := len(*h)
-	item := x.(*timer)
-	item.index = n
-	*h = append(*h, item)
-}
-
-func (h *timerHeap) Pop() interface{} {
-	old := *h
-	n := len(old)
-	item := old[n-1]
-	old[n-1] = nil  // avoid memory leak
-	item.index = -1 // for safety
-	*h = old[0 : n-1]
-	return item
+// timerCallback is what a JS timer calls when it fires.
+type timerCallback struct {
+	fn   goja.Callable
+	args []goja.Value
 }
 
 // Runtime implements the Actor interface using goja.
@@ -55,49 +27,155 @@ type Runtime struct {
 	script      string
 	initialized bool
 
+	// Module support: module is set when the script is a bundled ES module graph.
+	name          string // file name used in stack traces and logs
+	module        bool
+	defaultExport *goja.Object
+	env           *goja.Object
+
+	// Identity and logging
+	id      string
+	version string
+	logger  *slog.Logger
+	logs    *actor.LogBuffer
+
 	// Timer management
-	timers      map[int64]*timer
-	timerQueue  timerHeap
-	nextTimerID int64
+	clock  actor.Clock
+	timers *actor.TimerQueue
+
+	// Event handling
+	listeners  map[string][]listener
+	mailbox    *actor.Mailbox
+	rejections []*goja.Promise // rejected without a handler, checked after each Tick
+
+	// Capabilities granted at install time; nil means the runtime is unrestricted
+	// apart from explicit options like WithAllowedHosts.
+	caps    *manifest.CapabilitySet
+	secrets map[string]string
+	devices map[string]Device
+
+	// Host bindings exposed on env, usually generated from contracts.
+	bindings []binding.Binding
+
+	// WebAssembly global
+	wasm *wasmEngine
+
+	// Messaging between actors: the registry resolving targets, and the
+	// requests waiting for a reply, keyed by correlation ID.
+	registry      *actor.Registry
+	requests      map[string]pendingRequest
+	requestTimers *actor.TimerQueue
+
+	// Outbound requests
+	httpClient   *http.Client
+	allowedHosts []string
+	pendingOps   int // async host operations in flight, touched only on the event loop
+
+	// Tasks queued from outside the event loop, run by the next Tick.
+	taskMutex sync.Mutex
+	tasks     []func() error
+
+	// wake is signalled when an external event arrives for the actor.
+	wake chan struct{}
+
+	// Runaway-script protection
+	tickBudget    *watchdog
+	handlerBudget *watchdog
+	ticks         atomic.Uint64
+	ctx           context.Context // of the running Tick, for host calls that block the event loop
+	failure       error           // of a step ServeHTTP took, for the next Tick to report
+
+	// lifetime ends when the runtime shuts down, cancelling the host calls
+	// still running.
+	lifetime context.Context
+	end      context.CancelCauseFunc
+
+	// Shutdown: in-flight fetch handlers are counted until shuttingDown is
+	// set; closed is set, under mutex, once resources were released.
+	lifecycle    sync.Mutex
+	shuttingDown bool
+	inflight     sync.WaitGroup
+	closed       bool
 
 	mutex sync.Mutex
 }
 
-// Ensure Runtime implements Actor interface.
-var _ actor.Actor = (*Runtime)(nil)
+// Ensure Runtime implements Actor, Waiter, Receiver and Shutdowner interfaces.
+var (
+	_ actor.Actor      = (*Runtime)(nil)
+	_ actor.Waiter     = (*Runtime)(nil)
+	_ actor.Receiver   = (*Runtime)(nil)
+	_ actor.Shutdowner = (*Runtime)(nil)
+)
 
 // New creates a new JavaScript actor runtime.
 // It prepares the environment but does not execute the script yet.
-func New(script string) *Runtime {
+func New(script string, opts ...Option) *Runtime {
 	r := &Runtime{
-		vm:          goja.New(),
-		script:      script,
-		timers:      make(map[int64]*timer),
-		timerQueue:  make(timerHeap, 0),
-		nextTimerID: 1,
+		vm:            goja.New(),
+		script:        script,
+		name:          "script.js",
+		timers:        actor.NewTimerQueue(),
+		requests:      make(map[string]pendingRequest),
+		requestTimers: actor.NewTimerQueue(),
+		listeners:     make(map[string][]listener),
+		mailbox:       actor.NewMailbox(actor.DefaultMailboxSize),
+		wake:          make(chan struct{}, 1),
+		httpClient:    http.DefaultClient,
+		logs:          actor.NewLogBuffer(actor.DefaultLogBufferSize),
+		clock:         actor.RealClock,
+		tickBudget:    &watchdog{scope: "tick"},
+		handlerBudget: &watchdog{scope: "handler"},
+	}
+	r.lifetime, r.end = context.WithCancelCause(context.Background())
+	for _, opt := range opts {
+		opt(r)
 	}
 	r.initAPI()
 	return r
 }
 
 func (r *Runtime) initAPI() {
-	r.vm.Set("setTimeout", r.setTimeout)
-	r.vm.Set("clearTimeout", r.clearTimeout)
-	r.vm.Set("setInterval", r.setInterval)
-	r.vm.Set("clearInterval", r.clearInterval)
-
-	// Ensure console is available (basic polyfill if needed, though goja usually doesn't have it by default)
-	// User didn't ask for console, but it's useful for debugging.
-	// The prompt says "Web API polyfills (timers, fetch, console) are injected...".
-	// But requirements say "You must implement setTimeout...".
-	// It doesn't strictly say implement console, but I'll skip it unless needed to avoid clutter.
+	if r.caps == nil || r.caps.Timers != nil {
+		r.vm.Set("setTimeout", r.setTimeout)
+		r.vm.Set("clearTimeout", r.clearTimeout)
+		r.vm.Set("setInterval", r.setInterval)
+		r.vm.Set("clearInterval", r.clearInterval)
+	}
+	r.vm.Set("addEventListener", r.addEventListener)
+	r.vm.Set("removeEventListener", r.removeEventListener)
+	r.vm.Set("fetch", r.fetch)
+	r.vm.SetPromiseRejectionTracker(r.trackRejection)
+	r.installConsole()
+	r.installWebAPI()
+	r.installWebAssembly()
+	r.env = r.injectEnv()
 }
 
-// Tick executes one step of the actor's logic.
+// Logs returns the buffer holding the actor's most recent log entries.
+func (r *Runtime) Logs() *actor.LogBuffer {
+	return r.logs
+}
+
+// Tick executes one step of the actor's logic. A step ServeHTTP took
+// meanwhile that failed is reported here instead.
 func (r *Runtime) Tick(ctx context.Context) (bool, error) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 
+	if err := r.failure; err != nil && !r.closed {
+		r.failure = nil
+		return false, err
+	}
+	return r.step(ctx)
+}
+
+// step runs one Tick. Must be called with r.mutex held.
+func (r *Runtime) step(ctx context.Context) (bool, error) {
+	if r.closed {
+		return false, actor.ErrShutdown
+	}
+
 	// Check context
 	select {
 	case <-ctx.Done():
@@ -118,10 +196,36 @@ func (r *Runtime) Tick(ctx context.Context) (bool, error) {
 		}
 	}()
 
+	// Host calls end with the Tick or with the runtime.
+	callCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stop := context.AfterFunc(r.lifetime, func() { cancel(context.Cause(r.lifetime)) })
+	defer stop()
+	r.ctx = callCtx
+	defer func() { r.ctx = nil }()
+
+	r.ticks.Add(1)
+	var more bool
+	err := r.tickBudget.run(r.vm, func() error {
+		var err error
+		more, err = r.tick(ctx)
+		return err
+	})
+	return more, err
+}
+
+// tick runs the event loop step, with every script entry point bounded by the handler budget.
+func (r *Runtime) tick(ctx context.Context) (bool, error) {
 	// Lazy initialization
 	if !r.initialized {
 		r.initialized = true
-		_, err := r.vm.RunString(r.script)
+		err := r.handlerBudget.run(r.vm, func() error {
+			if r.module {
+				return r.runModule()
+			}
+			_, err := r.vm.RunScript(r.name, r.script)
+			return err
+		})
 		if err != nil {
 			// If interrupted by context, return context error
 			if ctx.Err() != nil {
@@ -130,31 +234,27 @@ func (r *Runtime) Tick(ctx context.Context) (bool, error) {
 			return false, err
 		}
 
-		// If timers were set, return true.
-		if len(r.timers) > 0 {
-			return true, nil
+		if err := r.checkRejections(); err != nil {
+			return false, err
 		}
-		// If script finished and no timers, we are done.
-		return false, nil
+		// If timers were set or messages arrived early, there is more work.
+		return r.hasWork(), nil
 	}
 
 	// Process timers
-	now := time.Now()
-	executed := 0
-
-	// We check the heap.
-	for len(r.timerQueue) > 0 {
-		t := r.timerQueue[0]
-		if !now.After(t.deadline) && !now.Equal(t.deadline) {
+	now := r.clock.Now()
+	for {
+		t, ok := r.timers.Pop(now)
+		if !ok {
 			break
 		}
 
-		// Timer is due
-		heap.Pop(&r.timerQueue)
-		executed++
-
 		// Execute callback
-		_, err := t.callback(goja.Undefined(), t.args...)
+		cb := t.Data.(timerCallback)
+		err := r.handlerBudget.run(r.vm, func() error {
+			_, err := cb.fn(goja.Undefined(), cb.args...)
+			return err
+		})
 		if err != nil {
 			// If interrupted by context, return context error
 			if ctx.Err() != nil {
@@ -163,25 +263,146 @@ func (r *Runtime) Tick(ctx context.Context) (bool, error) {
 			return false, err
 		}
 
-		// If interval, reschedule only if it hasn't been cleared
-		if t.interval > 0 {
-			if _, exists := r.timers[t.id]; exists {
-				t.deadline = now.Add(t.interval)
-				heap.Push(&r.timerQueue, t)
+		// Reschedule intervals, unless cleared by the callback.
+		r.timers.Done(t, now)
+	}
+	err := r.handlerBudget.run(r.vm, func() error {
+		r.expireRequests(now)
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		return false, err
+	}
+
+	// Run tasks queued from other goroutines before this tick started, one
+	// at a time, so those left behind by a failing task wait for the next tick.
+	for pending := r.taskCount(); pending > 0; pending-- {
+		task, ok := r.takeTask()
+		if !ok {
+			break
+		}
+		err := r.handlerBudget.run(r.vm, task)
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// Process messages that were queued before this tick started.
+	// Messages delivered meanwhile wait for the next tick.
+	for pending := r.mailbox.Len(); pending > 0; pending-- {
+		msg, ok := r.mailbox.Take()
+		if !ok {
+			break
+		}
+		err := r.handlerBudget.run(r.vm, func() error { return r.dispatchMessage(msg) })
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, ctx.Err()
 			}
-		} else {
-			delete(r.timers, t.id)
+			return false, err
 		}
 	}
 
-	// Check if we have more work
-	if len(r.timers) > 0 {
-		return true, nil
+	if err := r.checkRejections(); err != nil {
+		return false, err
+	}
+	return r.hasWork(), nil
+}
+
+// callContext returns the context of the running Tick, or the runtime's
+// lifetime between Ticks.
+func (r *Runtime) callContext() context.Context {
+	if r.ctx == nil {
+		return r.lifetime
+	}
+	return r.ctx
+}
+
+// hasWork reports whether there are timers, requests, tasks, messages or host operations pending.
+func (r *Runtime) hasWork() bool {
+	return r.timers.Len() > 0 || r.requestTimers.Len() > 0 || r.taskCount() > 0 || r.mailbox.Len() > 0 || r.pendingOps > 0
+}
+
+// enqueue schedules task to run on the event loop during the next Tick.
+// It is safe to call from any goroutine.
+func (r *Runtime) enqueue(task func() error) {
+	r.taskMutex.Lock()
+	r.tasks = append(r.tasks, task)
+	r.taskMutex.Unlock()
+	r.signal()
+}
+
+func (r *Runtime) taskCount() int {
+	r.taskMutex.Lock()
+	defer r.taskMutex.Unlock()
+	return len(r.tasks)
+}
+
+// takeTask removes the oldest queued task.
+func (r *Runtime) takeTask() (func() error, bool) {
+	r.taskMutex.Lock()
+	defer r.taskMutex.Unlock()
+	if len(r.tasks) == 0 {
+		return nil, false
+	}
+	task := r.tasks[0]
+	r.tasks[0] = nil
+	r.tasks = r.tasks[1:]
+	return task, true
+}
+
+func (r *Runtime) takeTasks() []func() error {
+	r.taskMutex.Lock()
+	defer r.taskMutex.Unlock()
+	tasks := r.tasks
+	r.tasks = nil
+	return tasks
+}
+
+// Deliver queues a message for the actor's "message" listeners.
+// It returns actor.ErrMailboxFull if the mailbox is at capacity, and
+// actor.ErrShutdown once the runtime is shutting down.
+func (r *Runtime) Deliver(msg any) error {
+	if r.closing() {
+		return actor.ErrShutdown
+	}
+	if err := r.mailbox.Put(msg); err != nil {
+		return err
+	}
+	r.signal()
+	return nil
+}
+
+// NextDeadline returns the deadline of the earliest pending timer or request.
+func (r *Runtime) NextDeadline() (time.Time, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	next, ok := r.timers.Next()
+	if request, pending := r.requestTimers.Next(); pending && (!ok || request.Before(next)) {
+		return request, true
 	}
+	return next, ok
+}
+
+// Wake returns a channel that receives a value when an external event arrives.
+func (r *Runtime) Wake() <-chan struct{} {
+	return r.wake
+}
 
-	// If we executed something, maybe there are side effects (jobs) that were run.
-	// But if no timers left, we are done.
-	return false, nil
+// signal notifies whoever is driving the runtime that it has work to do.
+// It never blocks; pending notifications are coalesced.
+func (r *Runtime) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
 }
 
 func (r *Runtime) setTimeout(call goja.FunctionCall) goja.Value {
@@ -196,13 +417,7 @@ func (r *Runtime) clearTimeout(call goja.FunctionCall) goja.Value {
 	if len(call.Arguments) == 0 {
 		return goja.Undefined()
 	}
-	id := call.Argument(0).ToInteger()
-	if t, ok := r.timers[id]; ok {
-		if t.index != -1 {
-			heap.Remove(&r.timerQueue, t.index)
-		}
-		delete(r.timers, id)
-	}
+	r.timers.Clear(call.Argument(0).ToInteger())
 	return goja.Undefined()
 }
 
@@ -227,30 +442,20 @@ func (r *Runtime) scheduleTimer(call goja.FunctionCall, repeating bool) goja.Val
 	}
 	delay := time.Duration(delayMs) * time.Millisecond
 
+	if r.caps != nil && r.caps.Timers != nil && r.caps.Timers.Max > 0 && r.timers.Len() >= r.caps.Timers.Max {
+		panic(r.vm.NewTypeError("too many timers: at most %d may be pending", r.caps.Timers.Max))
+	}
+
 	var args []goja.Value
 	if len(call.Arguments) > 2 {
 		args = call.Arguments[2:]
 	}
 
-	t := &timer{
-		id:       r.nextTimerID,
-		deadline: time.Now().Add(delay),
-		callback: fn,
-		args:     args,
-	}
+	var interval time.Duration
 	if repeating {
-		t.interval = delay
-		// Intervals shouldn't be 0 ideally to avoid tight loops, but JS allows it (clamped to 4ms usually).
-		// We'll trust the delay for now.
-		if t.interval < time.Millisecond {
-             // Maybe clamp to 1ms to avoid infinite tight loop in Tick?
-             // But let's respect user input for now.
-		}
+		interval = delay
 	}
 
-	r.nextTimerID++
-	r.timers[t.id] = t
-	heap.Push(&r.timerQueue, t)
-
-	return r.vm.ToValue(t.id)
+	t := r.timers.Add(r.clock.Now().Add(delay), interval, timerCallback{fn: fn, args: args})
+	return r.vm.ToValue(t.ID)
 }
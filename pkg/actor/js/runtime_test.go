@@ -3,8 +3,11 @@ package js
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 	"time"
+
+	"orvalho/pkg/actor"
 )
 
 func TestNew(t *testing.T) {
@@ -296,3 +299,222 @@ func TestContextTimeout(t *testing.T) {
 		t.Fatal("Test timed out, Tick did not return after context cancellation")
 	}
 }
+
+func TestNextDeadline(t *testing.T) {
+	r := New(`
+		setTimeout(function() {}, 1000);
+		setTimeout(function() {}, 50);
+	`)
+	if _, ok := r.NextDeadline(); ok {
+		t.Fatal("NextDeadline should report nothing before the script runs")
+	}
+
+	before := time.Now()
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	deadline, ok := r.NextDeadline()
+	if !ok {
+		t.Fatal("NextDeadline should report the pending timer")
+	}
+	if d := deadline.Sub(before); d < 50*time.Millisecond || d > 500*time.Millisecond {
+		t.Errorf("expected the earliest timer (~50ms), got %v", d)
+	}
+}
+
+func TestSchedulerSleepsUntilTimer(t *testing.T) {
+	r := New(`
+		var fired = 0;
+		setTimeout(function() { fired++; }, 30);
+	`)
+	s := actor.NewScheduler(actor.SchedulerConfig{})
+	s.Spawn("timer", r)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		st, _ := s.Status("timer")
+		if st.State == actor.StateParked {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("actor never parked after its timer fired")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	if fired := r.vm.Get("fired").ToInteger(); fired != 1 {
+		t.Errorf("expected timer to fire once, got %d", fired)
+	}
+}
+
+func TestDeliverMessage(t *testing.T) {
+	r := New(`
+		var received = [];
+		addEventListener("message", function(event) {
+			received.push(event.type + ":" + event.data.text);
+		});
+	`)
+	ctx := context.Background()
+
+	// Messages delivered before the script runs are kept until it does.
+	if err := r.Deliver(map[string]any{"text": "early"}); err != nil {
+		t.Fatal(err)
+	}
+	more, err := r.Tick(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !more {
+		t.Fatal("Tick should report the queued message as more work")
+	}
+	select {
+	case <-r.Wake():
+	default:
+		t.Error("Deliver should signal the wake channel")
+	}
+
+	r.Deliver(map[string]any{"text": "late"})
+	more, err = r.Tick(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if more {
+		t.Error("Tick should be idle once the mailbox is drained")
+	}
+
+	got := r.vm.Get("received").Export().([]any)
+	if len(got) != 2 || got[0] != "message:early" || got[1] != "message:late" {
+		t.Errorf("unexpected messages received: %v", got)
+	}
+}
+
+func TestMailboxFull(t *testing.T) {
+	r := New(`addEventListener("message", function() {});`, WithMailboxSize(1))
+	if err := r.Deliver(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Deliver(2); !errors.Is(err, actor.ErrMailboxFull) {
+		t.Errorf("expected ErrMailboxFull, got %v", err)
+	}
+}
+
+func TestRemoveEventListener(t *testing.T) {
+	r := New(`
+		var count = 0;
+		function onMessage() {
+			count++;
+			removeEventListener("message", onMessage);
+		}
+		addEventListener("message", onMessage);
+		addEventListener("message", onMessage); // duplicates are ignored
+	`)
+	ctx := context.Background()
+	r.Tick(ctx)
+	r.Deliver("a")
+	r.Deliver("b")
+	if _, err := r.Tick(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if count := r.vm.Get("count").ToInteger(); count != 1 {
+		t.Errorf("expected listener to run once, got %d", count)
+	}
+}
+
+func TestMessageListenerError(t *testing.T) {
+	r := New(`addEventListener("message", function() { throw new Error("bad message"); });`)
+	ctx := context.Background()
+	r.Tick(ctx)
+	r.Deliver("x")
+	if _, err := r.Tick(ctx); err == nil {
+		t.Error("Tick should surface errors thrown by listeners")
+	}
+}
+
+func TestFailingTaskKeepsTheRest(t *testing.T) {
+	r := New(``)
+	ctx := context.Background()
+	r.Tick(ctx)
+
+	ran := false
+	r.enqueue(func() error { return errors.New("first failed") })
+	r.enqueue(func() error { ran = true; return nil })
+	if _, err := r.Tick(ctx); err == nil || err.Error() != "first failed" {
+		t.Fatalf("expected the first task's error, got %v", err)
+	}
+	if more, err := r.Tick(ctx); err != nil || !ran || more {
+		t.Errorf("the next Tick should run the task left behind: ran %v, more %v, err %v", ran, more, err)
+	}
+}
+
+// runToIdle ticks r until it reports no more work, sleeping on its wake channel and deadlines in between.
+func runToIdle(t *testing.T, r *Runtime) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		more, err := r.Tick(ctx)
+		if err != nil {
+			t.Fatalf("Tick failed: %v", err)
+		}
+		if !more {
+			return
+		}
+		var timeout <-chan time.Time
+		if deadline, ok := r.NextDeadline(); ok {
+			timeout = time.After(time.Until(deadline))
+		}
+		select {
+		case <-r.Wake():
+		case <-timeout:
+		case <-ctx.Done():
+			t.Fatal("runtime did not go idle")
+		}
+	}
+}
+
+func TestVirtualClockTimers(t *testing.T) {
+	clock := actor.NewVirtualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	r := New(`
+		var fired = [];
+		setTimeout(function() { fired.push("timeout"); }, 50);
+		var id = setInterval(function() {
+			fired.push("interval");
+			if (fired.length >= 4) clearInterval(id);
+		}, 20);
+	`, WithClock(clock))
+	ctx := context.Background()
+
+	step := func(d time.Duration, want string) {
+		t.Helper()
+		clock.Advance(d)
+		if _, err := r.Tick(ctx); err != nil {
+			t.Fatal(err)
+		}
+		got := r.vm.Get("fired").Export()
+		var parts []string
+		for _, v := range got.([]any) {
+			parts = append(parts, v.(string))
+		}
+		if joined := strings.Join(parts, ","); joined != want {
+			t.Fatalf("after %v: fired %q, want %q", d, joined, want)
+		}
+	}
+
+	step(0, "")
+	step(19*time.Millisecond, "")
+	step(time.Millisecond, "interval")
+	step(20*time.Millisecond, "interval,interval")
+	step(10*time.Millisecond, "interval,interval,timeout")
+	step(10*time.Millisecond, "interval,interval,timeout,interval")
+
+	deadline, ok := r.NextDeadline()
+	if ok {
+		t.Errorf("expected no timers left, next at %v", deadline)
+	}
+}
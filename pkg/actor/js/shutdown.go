@@ -0,0 +1,160 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"orvalho/pkg/actor"
+
+	"github.com/dop251/goja"
+)
+
+// Shutdown stops the runtime for good. From then on messages and requests
+// are refused with actor.ErrShutdown.
+//
+// A graceful shutdown dispatches a "shutdown" event, to the default
+// export's shutdown(event, env, ctx) handler or to listeners, and waits
+// until ctx is done for the promises the handler returns or passes to
+// waitUntil, queued messages, host operations and in-flight fetch
+// handlers. The event's deadline is when ctx expires, in Unix
+// milliseconds, if it does.
+//
+// Either way, host calls and outbound fetches still running are then
+// cancelled, pending timers and requests are dropped and the WebAssembly
+// engine and bindings are released.
+func (r *Runtime) Shutdown(ctx context.Context, graceful bool) error {
+	r.lifecycle.Lock()
+	if r.shuttingDown {
+		r.lifecycle.Unlock()
+		return nil
+	}
+	r.shuttingDown = true
+	r.lifecycle.Unlock()
+
+	var err error
+	if graceful {
+		err = r.finish(ctx)
+	}
+	// Stop whatever is still running before releasing what it uses.
+	r.end(actor.ErrShutdown)
+	r.vm.Interrupt(actor.ErrShutdown)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return errors.Join(err, r.release(context.WithoutCancel(ctx)))
+}
+
+// enter registers an in-flight fetch handler. It reports false once the
+// runtime is shutting down.
+func (r *Runtime) enter() bool {
+	r.lifecycle.Lock()
+	defer r.lifecycle.Unlock()
+	if r.shuttingDown {
+		return false
+	}
+	r.inflight.Add(1)
+	return true
+}
+
+// started reports whether the script has run.
+func (r *Runtime) started() bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.initialized
+}
+
+func (r *Runtime) closing() bool {
+	r.lifecycle.Lock()
+	defer r.lifecycle.Unlock()
+	return r.shuttingDown
+}
+
+// finish dispatches the shutdown event and drains the runtime.
+func (r *Runtime) finish(ctx context.Context) error {
+	requests := make(chan struct{})
+	go func() {
+		r.inflight.Wait()
+		close(requests)
+	}()
+
+	// A script that never ran has nothing to finish.
+	if r.started() {
+		r.enqueue(func() error { return r.dispatchShutdown(ctx) })
+		if err := r.drain(ctx); err != nil {
+			return err
+		}
+	}
+	select {
+	case <-requests:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// dispatchShutdown hands the shutdown event to the default export's
+// shutdown handler, or to the actor's "shutdown" listeners.
+func (r *Runtime) dispatchShutdown(ctx context.Context) error {
+	event := r.newEvent("shutdown")
+	if deadline, ok := ctx.Deadline(); ok {
+		event.Set("deadline", deadline.UnixMilli())
+	}
+	event.Set("waitUntil", r.awaitShutdown)
+
+	if handler, ok := r.defaultHandler("shutdown"); ok {
+		result, err := handler(r.defaultExport, event, r.env, r.newExecutionContext())
+		if err != nil {
+			return err
+		}
+		r.awaitShutdown(goja.FunctionCall{Arguments: []goja.Value{result}})
+		return nil
+	}
+	_, err := r.dispatchEvent("shutdown", event)
+	return err
+}
+
+// awaitShutdown implements event.waitUntil(promise) for the shutdown
+// event, keeping the runtime busy until the promise settles.
+func (r *Runtime) awaitShutdown(call goja.FunctionCall) goja.Value {
+	r.pendingOps++
+	err := r.settle(call.Argument(0), func(goja.Value) {
+		r.pendingOps--
+	}, func(reason goja.Value) {
+		r.pendingOps--
+		r.logger.Warn("shutdown handler failed", "reason", r.inspect(reason, true))
+	})
+	if err != nil {
+		panic(r.vm.NewGoError(err))
+	}
+	return goja.Undefined()
+}
+
+// release drops the remaining work and frees what the runtime holds.
+// Must be called with r.mutex held.
+func (r *Runtime) release(ctx context.Context) error {
+	r.closed = true
+	r.timers = actor.NewTimerQueue()
+	r.requestTimers = actor.NewTimerQueue()
+	clear(r.requests)
+	clear(r.listeners)
+	r.takeTasks()
+	for {
+		if _, ok := r.mailbox.Take(); !ok {
+			break
+		}
+	}
+
+	var errs []error
+	if r.wasm != nil && r.wasm.runtime != nil {
+		errs = append(errs, r.wasm.runtime.Close(ctx))
+	}
+	for _, b := range r.bindings {
+		if b.Close != nil {
+			if err := b.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("closing %s: %w", b.EnvName(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,100 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/binding"
+)
+
+func TestShutdown(t *testing.T) {
+	for _, graceful := range []bool{true, false} {
+		closed := 0
+		r, err := LoadFiles(map[string]string{"main.js": `
+			export default {
+				async fetch(request) {
+					await new Promise(resolve => setTimeout(resolve, 30));
+					return new Response("slow");
+				},
+				shutdown(event, env, ctx) {
+					globalThis.deadline = event.deadline;
+					setInterval(() => {}, 1000);
+					return new Promise(resolve => setTimeout(() => {
+						globalThis.finished = true;
+						resolve();
+					}, 10));
+				},
+			};
+		`}, "main.js", WithBinding(binding.Binding{Name: "kv", Close: func() error {
+			closed++
+			return nil
+		}}))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		responses := make(chan *http.Response, 1)
+		go func() {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest("GET", "http://phone.local/", nil))
+			responses <- rec.Result()
+		}()
+		// Wait for the fetch handler to be in flight.
+		for deadline := time.Now().Add(2 * time.Second); ; time.Sleep(time.Millisecond) {
+			if _, ok := r.NextDeadline(); ok {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("fetch handler did not start")
+			}
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		if err := r.Shutdown(ctx, graceful); err != nil {
+			t.Fatal(err)
+		}
+
+		resp := <-responses
+		if graceful {
+			if body := readBody(t, resp); resp.StatusCode != 200 || body != "slow" {
+				t.Errorf("in-flight request should finish, got %d %q", resp.StatusCode, body)
+			}
+			deadline, _ := ctx.Deadline()
+			if got := r.vm.Get("deadline"); got == nil || got.ToInteger() != deadline.UnixMilli() {
+				t.Errorf("unexpected event deadline %v", got)
+			}
+			if got := r.vm.Get("finished"); got == nil || !got.ToBoolean() {
+				t.Error("shutdown should wait for the handler's promise")
+			}
+		} else {
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("abandoned request should fail with 503, got %d", resp.StatusCode)
+			}
+			if got := r.vm.Get("finished"); got != nil {
+				t.Error("the shutdown handler should only run on a graceful shutdown")
+			}
+		}
+
+		if closed != 1 {
+			t.Errorf("binding closed %d times", closed)
+		}
+		if _, ok := r.NextDeadline(); ok {
+			t.Error("timers should be dropped")
+		}
+		if _, err := r.Tick(ctx); !errors.Is(err, actor.ErrShutdown) {
+			t.Errorf("expected Tick to fail with ErrShutdown, got %v", err)
+		}
+		if err := r.Deliver("late"); !errors.Is(err, actor.ErrShutdown) {
+			t.Errorf("expected Deliver to fail with ErrShutdown, got %v", err)
+		}
+		if resp := serve(t, r, httptest.NewRequest("GET", "http://phone.local/", nil)); resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("expected 503 after shutdown, got %d", resp.StatusCode)
+		}
+	}
+}
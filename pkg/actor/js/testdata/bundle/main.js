@@ -0,0 +1,8 @@
+import { greet } from "./lib/greet.js";
+import config from "./config.json";
+
+export default {
+	async fetch(request, env, ctx) {
+		return new Response(greet(config.name));
+	},
+};
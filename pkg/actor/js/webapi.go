@@ -0,0 +1,56 @@
+package js
+
+import (
+	_ "embed"
+	"fmt"
+
+	"github.com/dop251/goja"
+)
+
+//go:embed webapi.js
+var webAPISource string
+
+var webAPIProgram = goja.MustCompile("webapi.js", webAPISource, true)
+
+// installWebAPI evaluates the Web API polyfills into the runtime's global scope.
+func (r *Runtime) installWebAPI() {
+	fn, err := r.vm.RunProgram(webAPIProgram)
+	if err != nil {
+		panic(fmt.Errorf("webapi.js: %w", err))
+	}
+	install, _ := goja.AssertFunction(fn)
+
+	host := r.vm.NewObject()
+	host.Set("encodeUTF8", func(s string) goja.ArrayBuffer {
+		return r.vm.NewArrayBuffer([]byte(s))
+	})
+	host.Set("decodeUTF8", func(buf goja.ArrayBuffer) string {
+		return string(buf.Bytes())
+	})
+	if _, err := install(goja.Undefined(), host); err != nil {
+		panic(fmt.Errorf("webapi.js: %w", err))
+	}
+}
+
+// settle calls onFulfilled or onRejected once v (a value or a thenable) settles.
+// Both callbacks run on the event loop, inside Tick.
+func (r *Runtime) settle(v goja.Value, onFulfilled, onRejected func(goja.Value)) error {
+	promiseCtor := r.vm.Get("Promise").ToObject(r.vm)
+	resolve, _ := goja.AssertFunction(promiseCtor.Get("resolve"))
+	p, err := resolve(promiseCtor, v)
+	if err != nil {
+		return err
+	}
+	then, _ := goja.AssertFunction(p.ToObject(r.vm).Get("then"))
+	_, err = then(p,
+		r.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			onFulfilled(call.Argument(0))
+			return goja.Undefined()
+		}),
+		r.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+			onRejected(call.Argument(0))
+			return goja.Undefined()
+		}),
+	)
+	return err
+}
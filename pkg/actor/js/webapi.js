@@ -0,0 +1,446 @@
+// Minimal WinterTC-style Web APIs (Headers, Request, Response, TextEncoder, TextDecoder, AbortController, queueMicrotask).
+// Evaluated once per Runtime; `host` carries the Go helpers the polyfills need.
+(function (host) {
+	"use strict";
+
+	function normalizeName(name) {
+		name = String(name);
+		if (!/^[!#$%&'*+\-.^_`|~0-9A-Za-z]+$/.test(name)) {
+			throw new TypeError("Invalid header name: " + name);
+		}
+		return name.toLowerCase();
+	}
+
+	function normalizeValue(value) {
+		return String(value).replace(/^[\t\n\r ]+|[\t\n\r ]+$/g, "");
+	}
+
+	class Headers {
+		constructor(init) {
+			Object.defineProperty(this, "_list", { value: [] });
+			if (init instanceof Headers) {
+				init.forEach((value, name) => this.append(name, value));
+			} else if (Array.isArray(init)) {
+				for (const pair of init) {
+					if (pair.length !== 2) {
+						throw new TypeError("Header pairs must have exactly two items");
+					}
+					this.append(pair[0], pair[1]);
+				}
+			} else if (init != null) {
+				for (const name of Object.keys(init)) {
+					this.append(name, init[name]);
+				}
+			}
+		}
+
+		append(name, value) {
+			this._list.push([normalizeName(name), normalizeValue(value)]);
+		}
+
+		delete(name) {
+			name = normalizeName(name);
+			for (let i = this._list.length - 1; i >= 0; i--) {
+				if (this._list[i][0] === name) {
+					this._list.splice(i, 1);
+				}
+			}
+		}
+
+		get(name) {
+			name = normalizeName(name);
+			const values = this._list.filter((h) => h[0] === name).map((h) => h[1]);
+			return values.length ? values.join(", ") : null;
+		}
+
+		getSetCookie() {
+			return this._list.filter((h) => h[0] === "set-cookie").map((h) => h[1]);
+		}
+
+		has(name) {
+			name = normalizeName(name);
+			return this._list.some((h) => h[0] === name);
+		}
+
+		set(name, value) {
+			this.delete(name);
+			this.append(name, value);
+		}
+
+		forEach(callback, thisArg) {
+			for (const [name, value] of this.entries()) {
+				callback.call(thisArg, value, name, this);
+			}
+		}
+
+		*entries() {
+			const names = [];
+			for (const [name] of this._list) {
+				if (names.indexOf(name) < 0) {
+					names.push(name);
+				}
+			}
+			names.sort();
+			for (const name of names) {
+				if (name === "set-cookie") {
+					for (const value of this.getSetCookie()) {
+						yield [name, value];
+					}
+				} else {
+					yield [name, this.get(name)];
+				}
+			}
+		}
+
+		*keys() {
+			for (const [name] of this.entries()) {
+				yield name;
+			}
+		}
+
+		*values() {
+			for (const [, value] of this.entries()) {
+				yield value;
+			}
+		}
+
+		[Symbol.iterator]() {
+			return this.entries();
+		}
+	}
+
+	// toBuffer converts a body init value into an ArrayBuffer (or null) and a default content type.
+	function toBuffer(body) {
+		if (body == null) {
+			return [null, null];
+		}
+		if (typeof body === "string") {
+			return [host.encodeUTF8(body), "text/plain;charset=UTF-8"];
+		}
+		if (body instanceof ArrayBuffer) {
+			return [body.slice(0), null];
+		}
+		if (ArrayBuffer.isView(body)) {
+			return [body.buffer.slice(body.byteOffset, body.byteOffset + body.byteLength), null];
+		}
+		if (body instanceof URLSearchParams) {
+			return [host.encodeUTF8(body.toString()), "application/x-www-form-urlencoded;charset=UTF-8"];
+		}
+		return [host.encodeUTF8(String(body)), "text/plain;charset=UTF-8"];
+	}
+
+	// Body implements the reading half shared by Request and Response.
+	class Body {
+		_initBody(body, headers) {
+			const [buffer, contentType] = toBuffer(body);
+			Object.defineProperty(this, "_body", { value: buffer, writable: true });
+			Object.defineProperty(this, "_bodyUsed", { value: false, writable: true });
+			if (contentType && !headers.has("content-type")) {
+				headers.set("content-type", contentType);
+			}
+		}
+
+		get body() {
+			return this._body === null ? null : new Uint8Array(this._body);
+		}
+
+		get bodyUsed() {
+			return this._bodyUsed;
+		}
+
+		_consume() {
+			if (this._bodyUsed) {
+				return Promise.reject(new TypeError("Body has already been consumed"));
+			}
+			this._bodyUsed = true;
+			return Promise.resolve(this._body === null ? new ArrayBuffer(0) : this._body);
+		}
+
+		arrayBuffer() {
+			return this._consume();
+		}
+
+		bytes() {
+			return this._consume().then((buffer) => new Uint8Array(buffer));
+		}
+
+		text() {
+			return this._consume().then((buffer) => host.decodeUTF8(buffer));
+		}
+
+		json() {
+			return this.text().then(JSON.parse);
+		}
+	}
+
+	const methods = ["DELETE", "GET", "HEAD", "OPTIONS", "PATCH", "POST", "PUT"];
+
+	class Request extends Body {
+		constructor(input, init) {
+			super();
+			init = init || {};
+			let body = init.body;
+			if (input instanceof Request) {
+				if (body === undefined && input._body !== null) {
+					if (input._bodyUsed) {
+						throw new TypeError("Body has already been consumed");
+					}
+					body = input._body;
+				}
+				this.url = input.url;
+				this.method = input.method;
+				this.headers = new Headers(init.headers || input.headers);
+				this.redirect = input.redirect;
+				this.signal = input.signal;
+			} else {
+				this.url = String(input);
+				this.method = "GET";
+				this.headers = new Headers(init.headers);
+				this.redirect = "follow";
+				this.signal = null;
+			}
+			if (init.method !== undefined) {
+				const method = String(init.method).toUpperCase();
+				this.method = methods.indexOf(method) >= 0 ? method : String(init.method);
+			}
+			if (init.redirect !== undefined) {
+				this.redirect = init.redirect;
+			}
+			if (init.signal !== undefined) {
+				this.signal = init.signal;
+			}
+			if ((this.method === "GET" || this.method === "HEAD") && body != null) {
+				throw new TypeError("Request with GET/HEAD method cannot have body");
+			}
+			this._initBody(body, this.headers);
+		}
+
+		clone() {
+			if (this._bodyUsed) {
+				throw new TypeError("Body has already been consumed");
+			}
+			return new Request(this);
+		}
+	}
+
+	class Response extends Body {
+		constructor(body, init) {
+			super();
+			init = init || {};
+			this.status = init.status === undefined ? 200 : Number(init.status);
+			if (this.status < 200 || this.status > 599) {
+				throw new RangeError("Invalid response status: " + init.status);
+			}
+			this.statusText = init.statusText === undefined ? "" : String(init.statusText);
+			this.headers = new Headers(init.headers);
+			this.type = "default";
+			this.url = "";
+			this.redirected = false;
+			this._initBody(body, this.headers);
+		}
+
+		get ok() {
+			return this.status >= 200 && this.status < 300;
+		}
+
+		clone() {
+			if (this._bodyUsed) {
+				throw new TypeError("Body has already been consumed");
+			}
+			return new Response(this._body, this);
+		}
+
+		static json(data, init) {
+			const response = new Response(JSON.stringify(data), init);
+			response.headers.set("content-type", "application/json");
+			return response;
+		}
+
+		static redirect(url, status) {
+			status = status === undefined ? 302 : status;
+			if ([301, 302, 303, 307, 308].indexOf(status) < 0) {
+				throw new RangeError("Invalid redirect status: " + status);
+			}
+			return new Response(null, { status: status, headers: { location: String(url) } });
+		}
+
+		static error() {
+			const response = new Response(null, { status: 200 });
+			response.status = 0;
+			response.type = "error";
+			return response;
+		}
+	}
+
+	class TextEncoder {
+		get encoding() {
+			return "utf-8";
+		}
+
+		encode(input) {
+			return new Uint8Array(host.encodeUTF8(input === undefined ? "" : String(input)));
+		}
+	}
+
+	class TextDecoder {
+		constructor(label) {
+			label = label === undefined ? "utf-8" : String(label).toLowerCase();
+			if (label !== "utf-8" && label !== "utf8") {
+				throw new RangeError("Unsupported encoding: " + label);
+			}
+		}
+
+		get encoding() {
+			return "utf-8";
+		}
+
+		decode(input) {
+			if (input === undefined) {
+				return "";
+			}
+			if (ArrayBuffer.isView(input)) {
+				input = input.buffer.slice(input.byteOffset, input.byteOffset + input.byteLength);
+			}
+			return host.decodeUTF8(input);
+		}
+	}
+
+	class URLSearchParams {
+		constructor(init) {
+			Object.defineProperty(this, "_list", { value: [] });
+			if (typeof init === "string") {
+				for (const part of init.replace(/^\?/, "").split("&")) {
+					if (part === "") {
+						continue;
+					}
+					const eq = part.indexOf("=");
+					const name = eq < 0 ? part : part.slice(0, eq);
+					const value = eq < 0 ? "" : part.slice(eq + 1);
+					this._list.push([decode(name), decode(value)]);
+				}
+			} else if (init != null) {
+				for (const name of Object.keys(init)) {
+					this._list.push([name, String(init[name])]);
+				}
+			}
+		}
+
+		append(name, value) {
+			this._list.push([String(name), String(value)]);
+		}
+
+		get(name) {
+			const found = this._list.find((p) => p[0] === name);
+			return found ? found[1] : null;
+		}
+
+		getAll(name) {
+			return this._list.filter((p) => p[0] === name).map((p) => p[1]);
+		}
+
+		has(name) {
+			return this._list.some((p) => p[0] === name);
+		}
+
+		toString() {
+			return this._list.map((p) => encodeURIComponent(p[0]) + "=" + encodeURIComponent(p[1])).join("&");
+		}
+	}
+
+	class DOMException extends Error {
+		constructor(message, name) {
+			super(message === undefined ? "" : String(message));
+			this.name = name === undefined ? "Error" : String(name);
+		}
+	}
+
+	class AbortSignal {
+		constructor() {
+			Object.defineProperty(this, "_listeners", { value: [] });
+			this.aborted = false;
+			this.reason = undefined;
+			this.onabort = null;
+		}
+
+		addEventListener(type, listener) {
+			if (type === "abort" && typeof listener === "function" && this._listeners.indexOf(listener) < 0) {
+				this._listeners.push(listener);
+			}
+		}
+
+		removeEventListener(type, listener) {
+			const i = this._listeners.indexOf(listener);
+			if (type === "abort" && i >= 0) {
+				this._listeners.splice(i, 1);
+			}
+		}
+
+		throwIfAborted() {
+			if (this.aborted) {
+				throw this.reason;
+			}
+		}
+
+		_abort(reason) {
+			if (this.aborted) {
+				return;
+			}
+			this.aborted = true;
+			this.reason = reason === undefined ? new DOMException("This operation was aborted", "AbortError") : reason;
+			const event = { type: "abort", target: this };
+			if (typeof this.onabort === "function") {
+				this.onabort(event);
+			}
+			for (const listener of this._listeners.slice()) {
+				listener.call(this, event);
+			}
+		}
+
+		static abort(reason) {
+			const signal = new AbortSignal();
+			signal._abort(reason);
+			return signal;
+		}
+
+		static timeout(ms) {
+			const signal = new AbortSignal();
+			setTimeout(() => signal._abort(new DOMException("The operation timed out", "TimeoutError")), ms);
+			return signal;
+		}
+	}
+
+	class AbortController {
+		constructor() {
+			this.signal = new AbortSignal();
+		}
+
+		abort(reason) {
+			this.signal._abort(reason);
+		}
+	}
+
+	function queueMicrotask(callback) {
+		if (typeof callback !== "function") {
+			throw new TypeError("queueMicrotask requires a function");
+		}
+		// Errors thrown by the callback surface as unhandled rejections.
+		Promise.resolve().then(() => callback());
+	}
+
+	function decode(s) {
+		return decodeURIComponent(s.replace(/\+/g, " "));
+	}
+
+	Object.assign(globalThis, {
+		Headers: Headers,
+		Request: Request,
+		Response: Response,
+		TextEncoder: TextEncoder,
+		TextDecoder: TextDecoder,
+		URLSearchParams: URLSearchParams,
+		DOMException: DOMException,
+		AbortSignal: AbortSignal,
+		AbortController: AbortController,
+		queueMicrotask: queueMicrotask,
+	});
+})
@@ -0,0 +1,735 @@
+package js
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/dop251/goja"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// wasmErrors defines the WebAssembly error classes, which scripts match with
+// instanceof like any other Error subclass.
+const wasmErrors = `(() => {
+	class CompileError extends Error {}
+	class LinkError extends Error {}
+	class RuntimeError extends Error {}
+	for (const E of [CompileError, LinkError, RuntimeError]) {
+		Object.defineProperty(E.prototype, "name", { value: E.name, writable: true, configurable: true });
+	}
+	return { CompileError, LinkError, RuntimeError };
+})()`
+
+// wasmEngine backs the WebAssembly global with the same wazero engine WASM
+// actors run on. Apart from compiling, it is only used on the event loop.
+type wasmEngine struct {
+	runtime wazero.Runtime // created on first use
+	ns      *goja.Object   // the WebAssembly namespace
+	slot    *goja.Symbol   // keys the Go value behind Module, Instance and Memory objects
+
+	// thrown is an exception raised by a JS import, rethrown once the wasm
+	// call it interrupted has unwound.
+	thrown error
+
+	// reserved is how many pages of the memory limit are set aside for the
+	// memories created so far.
+	reserved uint32
+}
+
+// wasmModule is the value behind a WebAssembly.Module.
+type wasmModule struct {
+	compiled wazero.CompiledModule
+}
+
+// wasmMemory is the value behind a WebAssembly.Memory. buffer aliases the
+// memory and is detached when grow is called; after the module itself grows
+// the memory, it is replaced the next time the script asks for it.
+type wasmMemory struct {
+	memory api.Memory
+	module api.Module // exporting memory, for instances importing it
+	name   string     // memory's export name in module
+	buffer *goja.ArrayBuffer
+	size   uint32
+}
+
+// installWebAssembly defines the WebAssembly global: Module, Instance and
+// Memory, compile, instantiate and validate. Compiling runs off the event
+// loop; instantiating runs on it, and the Promises settle there too.
+//
+// Exports are functions and memories. i64 values cross as BigInts. Tables
+// and globals cannot be imported, and an import module providing a memory
+// cannot also provide functions.
+func (r *Runtime) installWebAssembly() {
+	w := &wasmEngine{ns: r.vm.NewObject(), slot: goja.NewSymbol("WebAssembly")}
+	r.wasm = w
+
+	errs, err := r.vm.RunString(wasmErrors)
+	if err != nil {
+		panic(err)
+	}
+	for _, name := range []string{"CompileError", "LinkError", "RuntimeError"} {
+		w.ns.Set(name, errs.ToObject(r.vm).Get(name))
+	}
+
+	module := r.wasmClass("Module", r.newWasmModule)
+	module.Set("exports", r.moduleExports)
+	module.Set("imports", r.moduleImports)
+	r.wasmClass("Instance", r.newWasmInstance)
+	memory := r.wasmClass("Memory", r.newWasmMemory)
+	proto := memory.Get("prototype").ToObject(r.vm)
+	proto.DefineAccessorProperty("buffer", r.vm.ToValue(r.memoryBuffer), nil, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	proto.Set("grow", r.memoryGrow)
+
+	w.ns.Set("validate", r.wasmValidate)
+	w.ns.Set("compile", r.wasmCompile)
+	w.ns.Set("instantiate", r.wasmInstantiate)
+	w.ns.DefineDataPropertySymbol(goja.SymToStringTag, r.vm.ToValue("WebAssembly"), goja.FLAG_FALSE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	r.vm.Set("WebAssembly", w.ns)
+}
+
+// wasmClass defines a constructor on the WebAssembly namespace.
+func (r *Runtime) wasmClass(name string, construct func(goja.ConstructorCall) *goja.Object) *goja.Object {
+	ctor := r.vm.ToValue(construct).ToObject(r.vm)
+	ctor.DefineDataProperty("name", r.vm.ToValue(name), goja.FLAG_FALSE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	proto := ctor.Get("prototype").ToObject(r.vm)
+	proto.DefineDataPropertySymbol(goja.SymToStringTag, r.vm.ToValue("WebAssembly."+name), goja.FLAG_FALSE, goja.FLAG_TRUE, goja.FLAG_FALSE)
+	r.wasm.ns.Set(name, ctor)
+	return ctor
+}
+
+// wasmObject creates an object of one of the WebAssembly classes, as if
+// constructed, holding v unless it is nil.
+func (r *Runtime) wasmObject(class string, v any) *goja.Object {
+	obj := r.vm.NewObject()
+	obj.SetPrototype(r.wasm.ns.Get(class).ToObject(r.vm).Get("prototype").ToObject(r.vm))
+	if v != nil {
+		r.setWasmSlot(obj, v)
+	}
+	return obj
+}
+
+func (r *Runtime) setWasmSlot(obj *goja.Object, v any) {
+	obj.DefineDataPropertySymbol(r.wasm.slot, r.vm.ToValue(v), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_FALSE)
+}
+
+// wasmSlot returns the Go value held by a WebAssembly object.
+func wasmSlot[T any](r *Runtime, v goja.Value) (T, bool) {
+	var zero T
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return zero, false
+	}
+	slot := obj.GetSymbol(r.wasm.slot)
+	if slot == nil {
+		return zero, false
+	}
+	x, ok := slot.Export().(T)
+	return x, ok
+}
+
+// wasmError creates one of the WebAssembly error classes.
+func (r *Runtime) wasmError(class string, format string, args ...any) goja.Value {
+	obj, err := r.vm.New(r.wasm.ns.Get(class), r.vm.ToValue(fmt.Sprintf(format, args...)))
+	if err != nil {
+		panic(err)
+	}
+	return obj
+}
+
+func (r *Runtime) rangeError(format string, args ...any) goja.Value {
+	obj, err := r.vm.New(r.vm.Get("RangeError"), r.vm.ToValue(fmt.Sprintf(format, args...)))
+	if err != nil {
+		panic(err)
+	}
+	return obj
+}
+
+// wasmRuntime returns the engine, creating it on first use. Each memory is
+// bounded by the memory limit the actor was granted, and reserveMemory keeps
+// them within it together.
+func (r *Runtime) wasmRuntime() wazero.Runtime {
+	if r.wasm.runtime == nil {
+		config := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+		if r.caps != nil && r.caps.Limits.Memory > 0 {
+			config = config.WithMemoryLimitPages(memoryPages(r.caps.Limits.Memory))
+		}
+		r.wasm.runtime = wazero.NewRuntimeWithConfig(context.Background(), config)
+	}
+	return r.wasm.runtime
+}
+
+// reserveMemory sets aside the pages a new memory may grow to, maximum if
+// bounded, so that all the actor's memories together stay within its memory
+// limit. Memories live as long as the runtime, so the pages are never given
+// back. It reports false if not enough is left.
+func (r *Runtime) reserveMemory(maximum uint32, bounded bool) bool {
+	if r.caps == nil || r.caps.Limits.Memory <= 0 {
+		return true
+	}
+	limit := memoryPages(r.caps.Limits.Memory)
+	if !bounded || maximum > limit {
+		maximum = limit
+	}
+	if maximum > limit-r.wasm.reserved {
+		return false
+	}
+	r.wasm.reserved += maximum
+	return true
+}
+
+// memoryPages converts a limit in bytes to 64KiB pages, rounding down but
+// keeping at least one page.
+func memoryPages(limit int64) uint32 {
+	pages := limit / 65536
+	switch {
+	case pages < 1:
+		return 1
+	case pages > 65536:
+		return 65536
+	}
+	return uint32(pages)
+}
+
+// bufferSource copies the bytes of an ArrayBuffer, typed array or DataView.
+func (r *Runtime) bufferSource(v goja.Value) ([]byte, bool) {
+	switch x := v.Export().(type) {
+	case goja.ArrayBuffer:
+		return bytes.Clone(x.Bytes()), true
+	case []byte:
+		return bytes.Clone(x), true
+	}
+	isView, _ := goja.AssertFunction(r.vm.Get("ArrayBuffer").ToObject(r.vm).Get("isView"))
+	if result, err := isView(goja.Undefined(), v); err != nil || !result.ToBoolean() {
+		return nil, false
+	}
+	obj := v.ToObject(r.vm)
+	buffer, ok := obj.Get("buffer").Export().(goja.ArrayBuffer)
+	if !ok {
+		return nil, false
+	}
+	data := buffer.Bytes()
+	offset, length := obj.Get("byteOffset").ToInteger(), obj.Get("byteLength").ToInteger()
+	if offset < 0 || length < 0 || offset+length > int64(len(data)) {
+		return nil, false
+	}
+	return bytes.Clone(data[offset : offset+length]), true
+}
+
+func (r *Runtime) codeArg(name string, v goja.Value) []byte {
+	code, ok := r.bufferSource(v)
+	if !ok {
+		panic(r.vm.NewTypeError("WebAssembly.%s: argument must be a buffer source", name))
+	}
+	return code
+}
+
+func (r *Runtime) moduleArg(name string, v goja.Value) *wasmModule {
+	m, ok := wasmSlot[*wasmModule](r, v)
+	if !ok {
+		panic(r.vm.NewTypeError("WebAssembly.%s: argument must be a WebAssembly.Module", name))
+	}
+	return m
+}
+
+// compileWasm compiles code, returning a CompileError on failure.
+func (r *Runtime) compileWasm(code []byte) (*wasmModule, goja.Value) {
+	compiled, err := r.wasmRuntime().CompileModule(context.Background(), code)
+	if err != nil {
+		return nil, r.wasmError("CompileError", "%s", err)
+	}
+	return &wasmModule{compiled: compiled}, nil
+}
+
+// compileAsync compiles code on its own goroutine and calls done with the
+// result on the event loop.
+func (r *Runtime) compileAsync(code []byte, done func(m *wasmModule, err goja.Value)) {
+	engine := r.wasmRuntime()
+	r.pendingOps++
+	go func() {
+		compiled, err := engine.CompileModule(context.Background(), code)
+		r.enqueue(func() error {
+			r.pendingOps--
+			if err != nil {
+				done(nil, r.wasmError("CompileError", "%s", err))
+				return nil
+			}
+			done(&wasmModule{compiled: compiled}, nil)
+			return nil
+		})
+	}()
+}
+
+func (r *Runtime) newWasmModule(call goja.ConstructorCall) *goja.Object {
+	m, err := r.compileWasm(r.codeArg("Module", call.Argument(0)))
+	if err != nil {
+		panic(err)
+	}
+	r.setWasmSlot(call.This, m)
+	return call.This
+}
+
+// moduleExports implements WebAssembly.Module.exports.
+func (r *Runtime) moduleExports(call goja.FunctionCall) goja.Value {
+	m := r.moduleArg("Module.exports", call.Argument(0))
+	var exports []any
+	for _, name := range sortedKeys(m.compiled.ExportedFunctions()) {
+		exports = append(exports, map[string]any{"name": name, "kind": "function"})
+	}
+	for _, name := range sortedKeys(m.compiled.ExportedMemories()) {
+		exports = append(exports, map[string]any{"name": name, "kind": "memory"})
+	}
+	return r.vm.ToValue(r.vm.NewArray(exports...))
+}
+
+// moduleImports implements WebAssembly.Module.imports.
+func (r *Runtime) moduleImports(call goja.FunctionCall) goja.Value {
+	m := r.moduleArg("Module.imports", call.Argument(0))
+	var imports []any
+	for _, def := range m.compiled.ImportedFunctions() {
+		module, name, _ := def.Import()
+		imports = append(imports, map[string]any{"module": module, "name": name, "kind": "function"})
+	}
+	for _, def := range m.compiled.ImportedMemories() {
+		module, name, _ := def.Import()
+		imports = append(imports, map[string]any{"module": module, "name": name, "kind": "memory"})
+	}
+	return r.vm.ToValue(r.vm.NewArray(imports...))
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func (r *Runtime) newWasmInstance(call goja.ConstructorCall) *goja.Object {
+	m := r.moduleArg("Instance", call.Argument(0))
+	r.instantiateWasm(call.This, m, call.Argument(1))
+	return call.This
+}
+
+// instantiateWasm instantiates m into instance, linking its imports to the
+// import object, and sets instance.exports.
+func (r *Runtime) instantiateWasm(instance *goja.Object, m *wasmModule, importObject goja.Value) {
+	funcs := map[string][]api.FunctionDefinition{}
+	for _, def := range m.compiled.ImportedFunctions() {
+		module, _, _ := def.Import()
+		funcs[module] = append(funcs[module], def)
+	}
+	memories := map[string]api.MemoryDefinition{}
+	for _, def := range m.compiled.ImportedMemories() {
+		module, _, _ := def.Import()
+		memories[module] = def
+	}
+	if len(funcs)+len(memories) > 0 && !isObject(importObject) {
+		panic(r.vm.NewTypeError("WebAssembly.Instance: imports argument must be present and must be an object"))
+	}
+
+	ctx, cancel := r.boundContext(r.callContext())
+	defer cancel()
+
+	resolved := map[string]api.Module{}
+	for module, def := range memories {
+		_, name, _ := def.Import()
+		if len(funcs[module]) > 0 {
+			panic(r.wasmError("LinkError", "import module %q provides both a memory and functions, which is not supported", module))
+		}
+		ns := r.importNamespace(importObject, module)
+		mem, ok := wasmSlot[*wasmMemory](r, ns.Get(name))
+		switch {
+		case !ok:
+			panic(r.wasmError("LinkError", "import %s.%s must be a WebAssembly.Memory", module, name))
+		case mem.name != name:
+			panic(r.wasmError("LinkError", "import %s.%s: memories can only be imported under the name they are exported as, %q", module, name, mem.name))
+		}
+		resolved[module] = mem.module
+	}
+	for module, defs := range funcs {
+		resolved[module] = r.importFuncs(ctx, module, r.importNamespace(importObject, module), defs)
+	}
+
+	ctx = experimental.WithImportResolver(ctx, func(name string) api.Module { return resolved[name] })
+	var mod api.Module
+	r.callWasm(ctx, func(ctx context.Context) error {
+		var err error
+		mod, err = r.wasmRuntime().InstantiateModule(ctx, m.compiled, wazero.NewModuleConfig().WithName("").WithStartFunctions())
+		if err != nil && !strings.HasPrefix(err.Error(), "start ") {
+			panic(r.wasmError("LinkError", "%s", err))
+		}
+		return err
+	})
+	if mod == nil {
+		return // interrupted
+	}
+	// wazero returns a typed nil for modules without a memory.
+	if mem := mod.Memory(); mem != nil && !reflect.ValueOf(mem).IsNil() {
+		def := mem.Definition()
+		if _, _, imported := def.Import(); !imported && !r.reserveMemory(def.Max()) {
+			mod.Close(context.Background())
+			panic(r.rangeError("WebAssembly.Instance: the actor's memory limit is used up"))
+		}
+	}
+
+	exports := r.vm.NewObject()
+	exports.SetPrototype(nil)
+	for _, name := range sortedKeys(m.compiled.ExportedFunctions()) {
+		exports.Set(name, r.exportFunc(mod.ExportedFunction(name)))
+	}
+	for _, name := range sortedKeys(m.compiled.ExportedMemories()) {
+		exports.Set(name, r.wasmObject("Memory", &wasmMemory{memory: mod.ExportedMemory(name), module: mod, name: name}))
+	}
+	freeze, _ := goja.AssertFunction(r.vm.Get("Object").ToObject(r.vm).Get("freeze"))
+	if _, err := freeze(goja.Undefined(), exports); err != nil {
+		panic(err)
+	}
+	instance.DefineDataProperty("exports", exports, goja.FLAG_FALSE, goja.FLAG_TRUE, goja.FLAG_TRUE)
+}
+
+func isObject(v goja.Value) bool {
+	_, ok := v.(*goja.Object)
+	return ok
+}
+
+func (r *Runtime) importNamespace(importObject goja.Value, module string) *goja.Object {
+	ns, ok := importObject.ToObject(r.vm).Get(module).(*goja.Object)
+	if !ok {
+		panic(r.vm.NewTypeError("WebAssembly.Instance: import module %q must be an object", module))
+	}
+	return ns
+}
+
+// importFuncs instantiates a host module calling the JS functions an
+// instance imports from module.
+func (r *Runtime) importFuncs(ctx context.Context, module string, ns *goja.Object, defs []api.FunctionDefinition) api.Module {
+	engine := r.wasmRuntime()
+	builder := engine.NewHostModuleBuilder(module)
+	for _, def := range defs {
+		_, name, _ := def.Import()
+		fn, ok := goja.AssertFunction(ns.Get(name))
+		if !ok {
+			panic(r.wasmError("LinkError", "import %s.%s must be a function", module, name))
+		}
+		builder.NewFunctionBuilder().
+			WithGoModuleFunction(r.importFunc(fn, def.ParamTypes(), def.ResultTypes()), def.ParamTypes(), def.ResultTypes()).
+			Export(name)
+	}
+	// Compiled and instantiated anonymously, since instances may import
+	// different functions under the same module name.
+	compiled, err := builder.Compile(ctx)
+	if err != nil {
+		panic(r.wasmError("LinkError", "%s", err))
+	}
+	mod, err := engine.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		panic(r.wasmError("LinkError", "%s", err))
+	}
+	return mod
+}
+
+// importFunc calls fn from wasm. An exception fn throws aborts the wasm
+// call, and callWasm rethrows it to the script.
+func (r *Runtime) importFunc(fn goja.Callable, params, results []api.ValueType) api.GoModuleFunc {
+	return func(ctx context.Context, _ api.Module, stack []uint64) {
+		args := make([]goja.Value, len(params))
+		for i, t := range params {
+			args[i] = r.fromWasm(stack[i], t)
+		}
+		result, err := fn(goja.Undefined(), args...)
+		if err == nil {
+			if ex := r.vm.Try(func() { r.storeResults(stack, result, results) }); ex != nil {
+				err = ex
+			}
+		}
+		if err != nil {
+			r.wasm.thrown = err
+			panic(err)
+		}
+	}
+}
+
+func (r *Runtime) storeResults(stack []uint64, result goja.Value, types []api.ValueType) {
+	switch len(types) {
+	case 0:
+	case 1:
+		stack[0] = r.toWasm(result, types[0])
+	default:
+		values := result.ToObject(r.vm)
+		for i, t := range types {
+			stack[i] = r.toWasm(values.Get(fmt.Sprint(i)), t)
+		}
+	}
+}
+
+// exportFunc wraps an exported wasm function for scripts.
+func (r *Runtime) exportFunc(fn api.Function) goja.Value {
+	def := fn.Definition()
+	params, results := def.ParamTypes(), def.ResultTypes()
+	return r.vm.ToValue(func(call goja.FunctionCall) goja.Value {
+		stack := make([]uint64, max(len(params), len(results)))
+		for i, t := range params {
+			stack[i] = r.toWasm(call.Argument(i), t)
+		}
+
+		ctx, cancel := r.boundContext(r.callContext())
+		defer cancel()
+		if !r.callWasm(ctx, func(ctx context.Context) error { return fn.CallWithStack(ctx, stack) }) {
+			return goja.Undefined()
+		}
+
+		switch len(results) {
+		case 0:
+			return goja.Undefined()
+		case 1:
+			return r.fromWasm(stack[0], results[0])
+		}
+		values := make([]any, len(results))
+		for i, t := range results {
+			values[i] = r.fromWasm(stack[i], t)
+		}
+		return r.vm.NewArray(values...)
+	})
+}
+
+// callWasm runs call, which enters wasm, as part of the running script. It
+// rethrows exceptions from JS imports and turns traps into RuntimeErrors.
+// If ctx ended, because the actor is stopping or out of budget, it
+// interrupts the script and returns false.
+func (r *Runtime) callWasm(ctx context.Context, call func(context.Context) error) bool {
+	err := call(ctx)
+	if thrown := r.wasm.thrown; thrown != nil {
+		r.wasm.thrown = nil
+		panic(thrown)
+	}
+	if err != nil && ctx.Err() != nil {
+		// The interrupt takes effect as soon as control is back in the script.
+		r.interruptFor(ctx)
+		return false
+	}
+	if err != nil {
+		panic(r.wasmError("RuntimeError", "%s", err))
+	}
+	return true
+}
+
+// toWasm converts an argument or result to a wasm value of type t.
+func (r *Runtime) toWasm(v goja.Value, t api.ValueType) uint64 {
+	switch t {
+	case api.ValueTypeI32:
+		return api.EncodeI32(int32(v.ToInteger()))
+	case api.ValueTypeI64:
+		if b, ok := v.Export().(*big.Int); ok {
+			return uint64(b.Int64())
+		}
+		return uint64(v.ToInteger())
+	case api.ValueTypeF32:
+		return api.EncodeF32(float32(v.ToFloat()))
+	case api.ValueTypeF64:
+		return api.EncodeF64(v.ToFloat())
+	}
+	panic(r.vm.NewTypeError("WebAssembly: unsupported value type %s", api.ValueTypeName(t)))
+}
+
+// fromWasm converts a wasm value of type t for scripts.
+func (r *Runtime) fromWasm(x uint64, t api.ValueType) goja.Value {
+	switch t {
+	case api.ValueTypeI32:
+		return r.vm.ToValue(api.DecodeI32(x))
+	case api.ValueTypeI64:
+		return r.vm.ToValue(big.NewInt(int64(x)))
+	case api.ValueTypeF32:
+		return r.vm.ToValue(float64(api.DecodeF32(x)))
+	case api.ValueTypeF64:
+		return r.vm.ToValue(api.DecodeF64(x))
+	}
+	panic(r.vm.NewTypeError("WebAssembly: unsupported value type %s", api.ValueTypeName(t)))
+}
+
+// newWasmMemory implements new WebAssembly.Memory({initial, maximum}), in
+// pages of 64KiB, by instantiating a module that only exports a memory.
+func (r *Runtime) newWasmMemory(call goja.ConstructorCall) *goja.Object {
+	desc, ok := call.Argument(0).(*goja.Object)
+	if !ok {
+		panic(r.vm.NewTypeError("WebAssembly.Memory: argument must be a memory descriptor"))
+	}
+	pages := func(name string) (uint32, bool) {
+		v := desc.Get(name)
+		if v == nil || goja.IsUndefined(v) {
+			return 0, false
+		}
+		n := v.ToInteger()
+		if n < 0 || n > 65536 {
+			panic(r.rangeError("WebAssembly.Memory: %s must be between 0 and 65536 pages", name))
+		}
+		return uint32(n), true
+	}
+	initial, ok := pages("initial")
+	if !ok {
+		panic(r.vm.NewTypeError("WebAssembly.Memory: initial is required"))
+	}
+	maximum, bounded := pages("maximum")
+	if bounded && maximum < initial {
+		panic(r.rangeError("WebAssembly.Memory: maximum is below initial"))
+	}
+
+	if !r.reserveMemory(maximum, bounded) {
+		panic(r.rangeError("WebAssembly.Memory: the actor's memory limit is used up"))
+	}
+
+	ctx := context.Background()
+	engine := r.wasmRuntime()
+	compiled, err := engine.CompileModule(ctx, memoryModule(initial, maximum, bounded))
+	if err != nil {
+		panic(r.rangeError("WebAssembly.Memory: %s", err))
+	}
+	mod, err := engine.InstantiateModule(ctx, compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		panic(r.rangeError("WebAssembly.Memory: %s", err))
+	}
+	r.setWasmSlot(call.This, &wasmMemory{memory: mod.ExportedMemory("memory"), module: mod, name: "memory"})
+	return call.This
+}
+
+// memoryModule encodes a module exporting a memory as "memory".
+func memoryModule(initial, maximum uint32, bounded bool) []byte {
+	limits := []byte{0}
+	if bounded {
+		limits[0] = 1
+	}
+	limits = binary.AppendUvarint(limits, uint64(initial))
+	if bounded {
+		limits = binary.AppendUvarint(limits, uint64(maximum))
+	}
+	memories := append([]byte{1}, limits...)
+	exports := append([]byte{1, 6}, "memory"...)
+	exports = append(exports, 2, 0) // memory 0
+
+	code := []byte("\x00asm\x01\x00\x00\x00")
+	for _, section := range []struct {
+		id   byte
+		data []byte
+	}{{5, memories}, {7, exports}} {
+		code = append(code, section.id)
+		code = binary.AppendUvarint(code, uint64(len(section.data)))
+		code = append(code, section.data...)
+	}
+	return code
+}
+
+// memoryBuffer implements the WebAssembly.Memory buffer accessor.
+func (r *Runtime) memoryBuffer(call goja.FunctionCall) goja.Value {
+	m, ok := wasmSlot[*wasmMemory](r, call.This)
+	if !ok {
+		panic(r.vm.NewTypeError("WebAssembly.Memory.buffer: receiver is not a WebAssembly.Memory"))
+	}
+	size := m.memory.Size()
+	if m.buffer == nil || m.size != size {
+		if m.buffer != nil {
+			m.buffer.Detach()
+		}
+		data, _ := m.memory.Read(0, size)
+		buffer := r.vm.NewArrayBuffer(data)
+		m.buffer, m.size = &buffer, size
+	}
+	return r.vm.ToValue(*m.buffer)
+}
+
+// memoryGrow implements WebAssembly.Memory.prototype.grow.
+func (r *Runtime) memoryGrow(call goja.FunctionCall) goja.Value {
+	m, ok := wasmSlot[*wasmMemory](r, call.This)
+	if !ok {
+		panic(r.vm.NewTypeError("WebAssembly.Memory.grow: receiver is not a WebAssembly.Memory"))
+	}
+	delta := call.Argument(0).ToInteger()
+	if delta < 0 || delta > 65536 {
+		panic(r.rangeError("WebAssembly.Memory.grow: delta must be between 0 and 65536 pages"))
+	}
+	previous, ok := m.memory.Grow(uint32(delta))
+	if !ok {
+		panic(r.rangeError("WebAssembly.Memory.grow: maximum memory size exceeded"))
+	}
+	if m.buffer != nil {
+		m.buffer.Detach()
+		m.buffer = nil
+	}
+	return r.vm.ToValue(previous)
+}
+
+// wasmValidate implements WebAssembly.validate.
+func (r *Runtime) wasmValidate(call goja.FunctionCall) goja.Value {
+	m, err := r.compileWasm(r.codeArg("validate", call.Argument(0)))
+	if err != nil {
+		return r.vm.ToValue(false)
+	}
+	m.compiled.Close(context.Background())
+	return r.vm.ToValue(true)
+}
+
+// wasmCompile implements WebAssembly.compile.
+func (r *Runtime) wasmCompile(call goja.FunctionCall) goja.Value {
+	promise, resolve, reject := r.vm.NewPromise()
+	code, ok := r.bufferSource(call.Argument(0))
+	if !ok {
+		reject(r.vm.NewTypeError("WebAssembly.compile: argument must be a buffer source"))
+		return r.vm.ToValue(promise)
+	}
+	r.compileAsync(code, func(m *wasmModule, err goja.Value) {
+		if err != nil {
+			reject(err)
+			return
+		}
+		resolve(r.wasmObject("Module", m))
+	})
+	return r.vm.ToValue(promise)
+}
+
+// wasmInstantiate implements both forms of WebAssembly.instantiate: given a
+// Module it resolves to an Instance, given bytes to {module, instance}.
+func (r *Runtime) wasmInstantiate(call goja.FunctionCall) goja.Value {
+	promise, resolve, reject := r.vm.NewPromise()
+	imports := call.Argument(1)
+	instantiate := func(m *wasmModule) (*goja.Object, bool) {
+		instance := r.wasmObject("Instance", nil)
+		if ex := r.vm.Try(func() { r.instantiateWasm(instance, m, imports) }); ex != nil {
+			reject(ex.Value())
+			return nil, false
+		}
+		return instance, true
+	}
+
+	if m, ok := wasmSlot[*wasmModule](r, call.Argument(0)); ok {
+		r.enqueue(func() error {
+			if instance, ok := instantiate(m); ok {
+				resolve(instance)
+			}
+			return nil
+		})
+		return r.vm.ToValue(promise)
+	}
+
+	code, ok := r.bufferSource(call.Argument(0))
+	if !ok {
+		reject(r.vm.NewTypeError("WebAssembly.instantiate: argument must be a buffer source or a WebAssembly.Module"))
+		return r.vm.ToValue(promise)
+	}
+	r.compileAsync(code, func(m *wasmModule, err goja.Value) {
+		if err != nil {
+			reject(err)
+			return
+		}
+		if instance, ok := instantiate(m); ok {
+			resolve(map[string]any{"module": r.wasmObject("Module", m), "instance": instance})
+		}
+	})
+	return r.vm.ToValue(promise)
+}
@@ -0,0 +1,206 @@
+package js
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"orvalho/pkg/actor/internal/wasmtest"
+	"orvalho/pkg/actor/manifest"
+)
+
+var (
+	i32 = []byte{wasmtest.I32}
+	i64 = []byte{wasmtest.I64}
+)
+
+// runWasm runs script with the module's bytes in the global wasm and fails
+// the test if it sets the global failure.
+func runWasm(t *testing.T, m *wasmtest.Module, script string, opts ...Option) *Runtime {
+	t.Helper()
+	r := New(script, opts...)
+	r.vm.Set("wasm", r.vm.NewArrayBuffer(m.Bytes()))
+	runToIdle(t, r)
+	if failure := r.vm.Get("failure"); failure != nil {
+		t.Fatal(failure)
+	}
+	return r
+}
+
+func TestWebAssembly(t *testing.T) {
+	m := &wasmtest.Module{}
+	log := m.Import("env", "log", i32, nil)
+	m.Memory(1)
+	m.Data(0, []byte("hi"))
+	m.Export("add", m.Func([]byte{wasmtest.I32, wasmtest.I32}, i32, nil,
+		wasmtest.LocalGet(0), wasmtest.LocalGet(1), wasmtest.I32Add))
+	m.Export("double", m.Func(i64, i64, nil,
+		wasmtest.LocalGet(0), wasmtest.LocalGet(0), wasmtest.I64Add))
+	m.Export("call_log", m.Func(nil, nil, nil, wasmtest.I32Const(42), wasmtest.Call(log)))
+
+	r := runWasm(t, m, `
+		const calls = [];
+		const imports = { env: { log: x => calls.push(x) } };
+		(async () => {
+			const { module, instance } = await WebAssembly.instantiate(new Uint8Array(wasm), imports);
+			const { add, double, call_log, memory } = instance.exports;
+			globalThis.classes = module instanceof WebAssembly.Module && instance instanceof WebAssembly.Instance;
+			globalThis.sum = add(2, 3);
+			globalThis.doubled = double(2n ** 40n) === 2n ** 41n;
+			call_log();
+			globalThis.logged = calls.join();
+			globalThis.exported = WebAssembly.Module.exports(module).map(e => e.kind + " " + e.name).join();
+
+			const buffer = memory.buffer;
+			globalThis.text = new TextDecoder().decode(new Uint8Array(buffer, 0, 2));
+			new Uint8Array(buffer)[2] = 7;
+			globalThis.previous = memory.grow(1);
+			globalThis.detached = buffer.byteLength;
+			globalThis.grown = memory.buffer.byteLength;
+			globalThis.kept = new Uint8Array(memory.buffer)[2];
+
+			const again = await WebAssembly.instantiate(module, imports);
+			const sync = new WebAssembly.Instance(new WebAssembly.Module(wasm), imports);
+			globalThis.instances = again instanceof WebAssembly.Instance && sync.exports.add(1, 1) === 2;
+		})().catch(e => { globalThis.failure = String(e); });
+	`)
+
+	for name, want := range map[string]any{
+		"classes":   true,
+		"sum":       int64(5),
+		"doubled":   true,
+		"logged":    "42",
+		"exported":  "function add,function call_log,function double,memory memory",
+		"text":      "hi",
+		"previous":  int64(1),
+		"detached":  int64(0),
+		"grown":     int64(2 * 65536),
+		"kept":      int64(7),
+		"instances": true,
+	} {
+		if got := r.vm.Get(name).Export(); got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestWebAssemblyErrors(t *testing.T) {
+	m := &wasmtest.Module{}
+	fail := m.Import("env", "fail", nil, nil)
+	m.Export("trap", m.Func(nil, nil, nil, wasmtest.Unreachable))
+	m.Export("fail", m.Func(nil, nil, nil, wasmtest.Call(fail)))
+
+	r := runWasm(t, m, `
+		const errors = {};
+		const check = (name, fn) => {
+			try {
+				fn();
+				errors[name] = "none";
+			} catch (e) {
+				errors[name] = e.name + ": " + e.message;
+			}
+		};
+		const garbage = new Uint8Array([1, 2, 3]);
+		(async () => {
+			globalThis.valid = WebAssembly.validate(wasm) && !WebAssembly.validate(garbage);
+			check("compile", () => new WebAssembly.Module(garbage));
+			await WebAssembly.instantiate(garbage).catch(e => { globalThis.rejected = e instanceof WebAssembly.CompileError; });
+
+			const module = new WebAssembly.Module(wasm);
+			check("link", () => new WebAssembly.Instance(module, { env: {} }));
+			const { exports } = new WebAssembly.Instance(module, { env: { fail() { throw new Error("boom"); } } });
+			check("trap", () => exports.trap());
+			check("import", () => exports.fail());
+		})().catch(e => { globalThis.failure = String(e); });
+	`)
+
+	if !r.vm.Get("valid").ToBoolean() || !r.vm.Get("rejected").ToBoolean() {
+		t.Error("expected garbage to be rejected as a CompileError")
+	}
+	errs := r.vm.Get("errors").Export().(map[string]any)
+	for name, want := range map[string]string{
+		"compile": "CompileError: ",
+		"link":    "LinkError: import env.fail must be a function",
+		"trap":    "RuntimeError: wasm error: unreachable",
+		"import":  "Error: boom",
+	} {
+		if got, _ := errs[name].(string); len(got) < len(want) || got[:len(want)] != want {
+			t.Errorf("%s threw %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestWebAssemblyMemory(t *testing.T) {
+	m := &wasmtest.Module{}
+	m.ImportMemory("env", "memory", 1)
+	m.Export("store", m.Func(nil, nil, nil, wasmtest.I32Const(8), wasmtest.I32Const(99), wasmtest.I32Store()))
+
+	r := runWasm(t, m, `
+		const memory = new WebAssembly.Memory({ initial: 1, maximum: 2 });
+		new WebAssembly.Instance(new WebAssembly.Module(wasm), { env: { memory } }).exports.store();
+		globalThis.stored = new Int32Array(memory.buffer)[2];
+		memory.grow(1);
+		try {
+			memory.grow(1);
+		} catch (e) {
+			globalThis.growError = e instanceof RangeError;
+		}
+	`)
+	if got := r.vm.Get("stored").Export(); got != int64(99) {
+		t.Errorf("memory should be shared with the instance, got %v", got)
+	}
+	if !r.vm.Get("growError").ToBoolean() {
+		t.Error("growing past the maximum should throw a RangeError")
+	}
+}
+
+func TestWebAssemblyMemoryLimit(t *testing.T) {
+	m := &wasmtest.Module{}
+	m.Memory(1)
+
+	// Two pages are shared by every memory the actor creates: each sets
+	// aside what it may grow to.
+	r := runWasm(t, m, `
+		const outcomes = [];
+		function attempt(create) {
+			try {
+				create();
+				outcomes.push("ok");
+			} catch (e) {
+				outcomes.push(e.name);
+			}
+		}
+		attempt(() => new WebAssembly.Memory({ initial: 1, maximum: 1 }));
+		attempt(() => new WebAssembly.Memory({ initial: 1 }));
+		attempt(() => new WebAssembly.Instance(new WebAssembly.Module(wasm)));
+		attempt(() => new WebAssembly.Memory({ initial: 1, maximum: 1 }));
+		attempt(() => new WebAssembly.Memory({ initial: 0, maximum: 1 }));
+		globalThis.outcomes = outcomes.join(",");
+	`, WithCapabilities(manifest.CapabilitySet{Limits: manifest.ResourceLimits{Memory: 2 << 16}}))
+	if got := r.vm.Get("outcomes").String(); got != "ok,RangeError,RangeError,ok,RangeError" {
+		t.Errorf("unexpected outcomes %s", got)
+	}
+}
+
+func TestWebAssemblyBudget(t *testing.T) {
+	m := &wasmtest.Module{}
+	m.Export("spin", m.Func(nil, nil, nil, wasmtest.Loop(), wasmtest.Br(0), wasmtest.End))
+
+	r := New(`new WebAssembly.Instance(new WebAssembly.Module(wasm)).exports.spin();`,
+		WithHandlerBudget(20*time.Millisecond))
+	r.vm.Set("wasm", r.vm.NewArrayBuffer(m.Bytes()))
+
+	start := time.Now()
+	_, err := r.Tick(context.Background())
+	if time.Since(start) > time.Second {
+		t.Fatal("runaway wasm was not interrupted in time")
+	}
+	var budget *BudgetExceededError
+	if !errors.As(err, &budget) || budget.Scope != "handler" {
+		t.Fatalf("expected the handler budget to be exceeded, got %v", err)
+	}
+	if stats := r.Stats(); stats.HandlerOverruns != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
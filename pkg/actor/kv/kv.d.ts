@@ -0,0 +1,42 @@
+// Code generated by orvalho-bindgen from kv.xml. DO NOT EDIT.
+
+/** KV provides durable key-value storage private to the actor. */
+export interface KV {
+  /** get returns the value stored under key, or null. */
+  get(key: string): Promise<ArrayBuffer | null>;
+  /** put stores value under key, for ttl_ms milliseconds if given. */
+  put(key: string, value: ArrayBuffer | Uint8Array, ttlMs?: number | null): Promise<void>;
+  /** delete removes key, if it is stored. */
+  delete(key: string): Promise<void>;
+  /** list returns the keys selected by options. */
+  list(options?: ListOptions | null): Promise<ListResult>;
+}
+
+/** Entry is a stored key and when it expires. */
+export interface Entry {
+  key: string;
+  /** Unix milliseconds, absent if the key never expires. */
+  expires_at?: number;
+}
+
+/** ListOptions selects the keys returned by list. */
+export interface ListOptions {
+  /** Only keys starting with this. */
+  prefix?: string;
+  /** The cursor of the previous page, to continue after it. */
+  cursor?: string;
+  /** The most keys to return, 1000 by default and at most. */
+  limit?: number;
+}
+
+/** ListResult is a page of keys, in byte order. */
+export interface ListResult {
+  keys: Entry[];
+  /** Where the next page starts, absent on the last page. */
+  cursor?: string;
+}
+
+/** The bindings the kv contract adds to env. */
+export interface Env {
+  KV: KV;
+}
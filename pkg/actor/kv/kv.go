@@ -0,0 +1,384 @@
+// Package kv gives actors durable key-value storage, exposed to JS actors as
+// env.KV and to WASM actors as the "orvalho:kv" module (see kv.xml).
+//
+// Every actor gets its own namespace in a Store, an embedded bbolt database
+// file. Each write is a transaction synced to disk before it returns, so a
+// crash loses nothing that was acknowledged and never leaves a write half
+// done. Namespaces are limited by the storage quota in the actor's manifest,
+// counting the bytes of keys and values.
+package kv
+
+//go:generate go run orvalho/cmd/orvalho-bindgen -go kv_gen.go -ts kv.d.ts kv.xml
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+	"unicode/utf8"
+
+	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/manifest"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Limits on what actors may store.
+const (
+	MaxKeySize   = 512
+	MaxValueSize = 16 << 20
+	// MaxListLimit is how many keys List returns at most, and by default.
+	MaxListLimit = 1000
+	// MaxTTL is the longest an entry may be kept before it expires.
+	MaxTTL = 100 * 365 * 24 * time.Hour
+)
+
+// Errors returned by namespaces.
+var (
+	ErrNotGranted    = errors.New("storage not granted")
+	ErrQuotaExceeded = errors.New("storage quota exceeded")
+	ErrInvalidKey    = errors.New("invalid key")
+	ErrInvalidValue  = errors.New("invalid value")
+)
+
+var (
+	dataBucket = []byte("data")
+	usageKey   = []byte("usage")
+)
+
+// headerSize is the expiry, in Unix milliseconds or 0, stored before each value.
+const headerSize = 8
+
+// Config configures a Store.
+type Config struct {
+	// Clock decides when entries expire. Defaults to actor.RealClock.
+	Clock actor.Clock
+}
+
+// Store is a database holding the namespaces of many actors. It is safe for
+// concurrent use, and only one process may open it at a time.
+type Store struct {
+	db     *bolt.DB
+	config Config
+}
+
+// Namespace is the storage of one actor. It implements KV.
+type Namespace struct {
+	store *Store
+	id    []byte
+	quota int64
+}
+
+var _ KV = (*Namespace)(nil)
+
+// Open opens the store at path, creating it if needed. It fails if
+// another process has it open.
+func Open(path string, config Config) (*Store, error) {
+	if config.Clock == nil {
+		config.Clock = actor.RealClock
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("creating storage directory: %w", err)
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", path, err)
+	}
+	return &Store{db: db, config: config}, nil
+}
+
+// Close closes the store, waiting for running transactions.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Namespace returns the storage of the actor with the given ID. Under caps,
+// storage must be granted and its quota applies; nil caps mean no limit.
+func (s *Store) Namespace(actorID string, caps *manifest.CapabilitySet) (*Namespace, error) {
+	if actorID == "" {
+		return nil, errors.New("kv: missing actor id")
+	}
+	n := &Namespace{store: s, id: []byte(actorID)}
+	if caps != nil {
+		if caps.Storage == nil {
+			return nil, fmt.Errorf("%w: %s", ErrNotGranted, actorID)
+		}
+		n.quota = caps.Storage.Quota
+	}
+	return n, nil
+}
+
+// Remove deletes everything the actor with the given ID stored.
+func (s *Store) Remove(actorID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(actorID))
+		if errors.Is(err, bolt.ErrBucketNotFound) {
+			return nil
+		}
+		return err
+	})
+}
+
+// Sweep deletes the expired entries of every namespace and returns how
+// many there were. Expired entries are never returned, but count towards
+// the quota until swept; namespaces running out of room sweep themselves.
+func (s *Store) Sweep() (int, error) {
+	now := s.config.Clock.Now()
+	swept := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(_ []byte, b *bolt.Bucket) error {
+			n, err := sweep(b, now)
+			swept += n
+			return err
+		})
+	})
+	return swept, err
+}
+
+// Get returns the value stored under key, or nil.
+func (n *Namespace) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	now := n.store.config.Clock.Now()
+	var value []byte
+	err := n.store.db.View(func(tx *bolt.Tx) error {
+		data := n.data(tx)
+		if data == nil {
+			return nil
+		}
+		stored := data.Get([]byte(key))
+		if stored == nil || expired(stored, now) {
+			return nil
+		}
+		// Stored bytes are only valid during the transaction.
+		value = bytes.Clone(stored[headerSize:])
+		return nil
+	})
+	return value, err
+}
+
+// Put stores value under key, expiring after ttlMs milliseconds if given.
+// It fails with ErrQuotaExceeded if the namespace would outgrow its quota.
+func (n *Namespace) Put(ctx context.Context, key string, value []byte, ttlMs *int64) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	if len(value) > MaxValueSize {
+		return fmt.Errorf("%w: larger than %d bytes", ErrInvalidValue, MaxValueSize)
+	}
+	now := n.store.config.Clock.Now()
+	stored := make([]byte, headerSize+len(value))
+	if ttlMs != nil {
+		if *ttlMs <= 0 || *ttlMs > MaxTTL.Milliseconds() {
+			return fmt.Errorf("%w: ttl must be positive and at most %d ms, got %d", ErrInvalidValue, MaxTTL.Milliseconds(), *ttlMs)
+		}
+		expiry := now.Add(time.Duration(*ttlMs) * time.Millisecond)
+		binary.BigEndian.PutUint64(stored, uint64(expiry.UnixMilli()))
+	}
+	copy(stored[headerSize:], value)
+
+	return n.store.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(n.id)
+		if err != nil {
+			return err
+		}
+		data, err := b.CreateBucketIfNotExists(dataBucket)
+		if err != nil {
+			return err
+		}
+
+		// usage is what the namespace takes once value replaced the old one.
+		usage := func() int64 {
+			u := usageOf(b) + entrySize(key, stored)
+			if old := data.Get([]byte(key)); old != nil {
+				u -= entrySize(key, old)
+			}
+			return u
+		}
+		if n.quota > 0 && usage() > n.quota {
+			// Make room by dropping expired entries, then check again.
+			if _, err := sweep(b, now); err != nil {
+				return err
+			}
+			if u := usage(); u > n.quota {
+				return fmt.Errorf("%w: %d of %d bytes", ErrQuotaExceeded, u, n.quota)
+			}
+		}
+		u := usage()
+		if err := data.Put([]byte(key), stored); err != nil {
+			return err
+		}
+		return setUsage(b, u)
+	})
+}
+
+// Delete removes key, if it is stored.
+func (n *Namespace) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	return n.store.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(n.id)
+		data := n.data(tx)
+		if data == nil {
+			return nil
+		}
+		old := data.Get([]byte(key))
+		if old == nil {
+			return nil
+		}
+		usage := usageOf(b) - entrySize(key, old)
+		if err := data.Delete([]byte(key)); err != nil {
+			return err
+		}
+		return setUsage(b, usage)
+	})
+}
+
+// List returns the unexpired keys selected by options, in byte order, a
+// page at a time.
+func (n *Namespace) List(ctx context.Context, options *ListOptions) (ListResult, error) {
+	var prefix, after []byte
+	limit := MaxListLimit
+	if options != nil {
+		if options.Prefix != nil {
+			prefix = []byte(*options.Prefix)
+		}
+		if options.Cursor != nil {
+			var err error
+			if after, err = base64.RawURLEncoding.DecodeString(*options.Cursor); err != nil || len(after) == 0 {
+				return ListResult{}, fmt.Errorf("kv: invalid cursor %q", *options.Cursor)
+			}
+		}
+		if options.Limit != nil && *options.Limit > 0 {
+			limit = min(int(*options.Limit), MaxListLimit)
+		}
+	}
+	start := max(string(after), string(prefix))
+
+	now := n.store.config.Clock.Now()
+	result := ListResult{Keys: []Entry{}}
+	err := n.store.db.View(func(tx *bolt.Tx) error {
+		data := n.data(tx)
+		if data == nil {
+			return nil
+		}
+		c := data.Cursor()
+		k, v := c.Seek([]byte(start))
+		// The cursor names the last key of the previous page.
+		if after != nil && bytes.Equal(k, after) {
+			k, v = c.Next()
+		}
+		for ; k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			if expired(v, now) {
+				continue
+			}
+			if len(result.Keys) == limit {
+				cursor := base64.RawURLEncoding.EncodeToString([]byte(result.Keys[limit-1].Key))
+				result.Cursor = &cursor
+				return nil
+			}
+			entry := Entry{Key: string(k)}
+			if ms := expiry(v); ms != 0 {
+				entry.ExpiresAt = &ms
+			}
+			result.Keys = append(result.Keys, entry)
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Usage returns how many bytes the namespace's keys and values take,
+// including expired entries not swept yet.
+func (n *Namespace) Usage() (int64, error) {
+	var usage int64
+	err := n.store.db.View(func(tx *bolt.Tx) error {
+		if b := tx.Bucket(n.id); b != nil {
+			usage = usageOf(b)
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// data returns the bucket holding the namespace's entries, or nil if it
+// stored nothing yet.
+func (n *Namespace) data(tx *bolt.Tx) *bolt.Bucket {
+	b := tx.Bucket(n.id)
+	if b == nil {
+		return nil
+	}
+	return b.Bucket(dataBucket)
+}
+
+// sweep deletes the expired entries of the namespace bucket b.
+func sweep(b *bolt.Bucket, now time.Time) (int, error) {
+	data := b.Bucket(dataBucket)
+	if data == nil {
+		return 0, nil
+	}
+	var keys [][]byte
+	freed := int64(0)
+	err := data.ForEach(func(k, v []byte) error {
+		if expired(v, now) {
+			keys = append(keys, bytes.Clone(k))
+			freed += entrySize(string(k), v)
+		}
+		return nil
+	})
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+	for _, k := range keys {
+		if err := data.Delete(k); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), setUsage(b, usageOf(b)-freed)
+}
+
+func checkKey(key string) error {
+	switch {
+	case key == "":
+		return fmt.Errorf("%w: empty", ErrInvalidKey)
+	case len(key) > MaxKeySize:
+		return fmt.Errorf("%w: longer than %d bytes", ErrInvalidKey, MaxKeySize)
+	case !utf8.ValidString(key):
+		return fmt.Errorf("%w: not UTF-8", ErrInvalidKey)
+	}
+	return nil
+}
+
+// expiry returns when a stored entry expires, in Unix milliseconds, or 0.
+func expiry(stored []byte) int64 {
+	return int64(binary.BigEndian.Uint64(stored[:headerSize]))
+}
+
+func expired(stored []byte, now time.Time) bool {
+	ms := expiry(stored)
+	return ms != 0 && ms <= now.UnixMilli()
+}
+
+// entrySize is what an entry counts towards the quota.
+func entrySize(key string, stored []byte) int64 {
+	return int64(len(key) + len(stored) - headerSize)
+}
+
+func usageOf(b *bolt.Bucket) int64 {
+	v := b.Get(usageKey)
+	if v == nil {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(v))
+}
+
+func setUsage(b *bolt.Bucket, usage int64) error {
+	return b.Put(usageKey, binary.BigEndian.AppendUint64(nil, uint64(usage)))
+}
@@ -0,0 +1,121 @@
+// Code generated by orvalho-bindgen from kv.xml. DO NOT EDIT.
+
+package kv
+
+import (
+	"context"
+
+	"orvalho/pkg/actor/binding"
+	"orvalho/pkg/actor/js"
+	"orvalho/pkg/actor/wasm"
+)
+
+// Entry is a stored key and when it expires.
+type Entry struct {
+	Key string `json:"key"`
+	// Unix milliseconds, absent if the key never expires.
+	ExpiresAt *int64 `json:"expires_at,omitempty"`
+}
+
+// ListOptions selects the keys returned by list.
+type ListOptions struct {
+	// Only keys starting with this.
+	Prefix *string `json:"prefix,omitempty"`
+	// The cursor of the previous page, to continue after it.
+	Cursor *string `json:"cursor,omitempty"`
+	// The most keys to return, 1000 by default and at most.
+	Limit *uint32 `json:"limit,omitempty"`
+}
+
+// ListResult is a page of keys, in byte order.
+type ListResult struct {
+	Keys []Entry `json:"keys"`
+	// Where the next page starts, absent on the last page.
+	Cursor *string `json:"cursor,omitempty"`
+}
+
+// KV provides durable key-value storage private to the actor.
+type KV interface {
+	// Get returns the value stored under key, or null.
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// Put stores value under key, for ttl_ms milliseconds if given.
+	Put(ctx context.Context, key string, value []byte, ttlMs *int64) error
+
+	// Delete removes key, if it is stored.
+	Delete(ctx context.Context, key string) error
+
+	// List returns the keys selected by options.
+	List(ctx context.Context, options *ListOptions) (ListResult, error)
+}
+
+// KVBinding exposes impl to actors as the kv contract.
+func KVBinding(impl KV) binding.Binding {
+	return binding.Binding{
+		Name: "kv",
+		Methods: []binding.Method{
+			{
+				Name:  "get",
+				Async: true,
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					var key string
+					if err := args.Decode(0, &key); err != nil {
+						return nil, err
+					}
+					return impl.Get(ctx, key)
+				},
+			},
+			{
+				Name:  "put",
+				Async: true,
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					var key string
+					if err := args.Decode(0, &key); err != nil {
+						return nil, err
+					}
+					var value []byte
+					if err := args.Decode(1, &value); err != nil {
+						return nil, err
+					}
+					var ttlMs *int64
+					if err := args.Decode(2, &ttlMs); err != nil {
+						return nil, err
+					}
+					return nil, impl.Put(ctx, key, value, ttlMs)
+				},
+			},
+			{
+				Name:  "delete",
+				Async: true,
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					var key string
+					if err := args.Decode(0, &key); err != nil {
+						return nil, err
+					}
+					return nil, impl.Delete(ctx, key)
+				},
+			},
+			{
+				Name:  "list",
+				Async: true,
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					var options *ListOptions
+					if err := args.Decode(0, &options); err != nil {
+						return nil, err
+					}
+					return impl.List(ctx, options)
+				},
+			},
+		},
+	}
+}
+
+// WithKVJS exposes impl to a JS actor as env.KV.
+func WithKVJS(impl KV) js.Option {
+	return js.WithBinding(KVBinding(impl))
+}
+
+// WithKVWASM lets a WASM actor import impl's methods from "orvalho:kv".
+func WithKVWASM(impl KV) wasm.Option {
+	return wasm.WithBinding(KVBinding(impl))
+}
@@ -0,0 +1,203 @@
+package kv
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/js"
+	"orvalho/pkg/actor/manifest"
+)
+
+func open(t *testing.T, path string, clock actor.Clock) *Store {
+	t.Helper()
+	s, err := Open(path, Config{Clock: clock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func keys(t *testing.T, n *Namespace, options *ListOptions) ([]string, *string) {
+	t.Helper()
+	result, err := n.List(context.Background(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range result.Keys {
+		names = append(names, e.Key)
+	}
+	return names, result.Cursor
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestNamespace(t *testing.T) {
+	ctx := context.Background()
+	clock := actor.NewVirtualClock(time.Unix(1000, 0))
+	path := filepath.Join(t.TempDir(), "data", "kv.db")
+	s := open(t, path, clock)
+
+	photos, _ := s.Namespace("photos", nil)
+	notes, _ := s.Namespace("notes", nil)
+	for _, key := range []string{"a/1", "a/2", "a/3", "b/1"} {
+		if err := photos.Put(ctx, key, []byte("v"+key), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := photos.Put(ctx, "a/0", []byte("soon gone"), ptr[int64](500)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := photos.Get(ctx, "a/2"); string(got) != "va/2" {
+		t.Errorf("Get = %q", got)
+	}
+	if got, _ := notes.Get(ctx, "a/2"); got != nil {
+		t.Errorf("namespaces should be isolated, got %q", got)
+	}
+	if err := photos.Put(ctx, "", nil, nil); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("expected ErrInvalidKey, got %v", err)
+	}
+	if err := photos.Put(ctx, "a/9", nil, ptr[int64](math.MaxInt64)); !errors.Is(err, ErrInvalidValue) {
+		t.Errorf("expected a ttl too long to be rejected, got %v", err)
+	}
+
+	result, _ := photos.List(ctx, &ListOptions{Prefix: ptr("a/0")})
+	if len(result.Keys) != 1 || result.Keys[0].ExpiresAt == nil || *result.Keys[0].ExpiresAt != 1000500 {
+		t.Errorf("unexpected entries %+v", result.Keys)
+	}
+	clock.Advance(500 * time.Millisecond)
+	if got, _ := photos.Get(ctx, "a/0"); got != nil {
+		t.Errorf("expired keys should be gone, got %q", got)
+	}
+
+	page, cursor := keys(t, photos, &ListOptions{Prefix: ptr("a/"), Limit: ptr[uint32](2)})
+	if !slices.Equal(page, []string{"a/1", "a/2"}) || cursor == nil {
+		t.Fatalf("first page %v, cursor %v", page, cursor)
+	}
+	page, cursor = keys(t, photos, &ListOptions{Prefix: ptr("a/"), Cursor: cursor, Limit: ptr[uint32](2)})
+	if !slices.Equal(page, []string{"a/3"}) || cursor != nil {
+		t.Errorf("last page %v, cursor %v", page, cursor)
+	}
+
+	if err := photos.Delete(ctx, "a/1"); err != nil {
+		t.Fatal(err)
+	}
+	if swept, err := s.Sweep(); err != nil || swept != 1 {
+		t.Errorf("Sweep = %d, %v", swept, err)
+	}
+	if usage, _ := photos.Usage(); usage != 3*(3+4) {
+		t.Errorf("usage should count the remaining keys and values, got %d", usage)
+	}
+
+	// Everything acknowledged survives reopening the store.
+	s.Close()
+	s = open(t, path, clock)
+	photos, _ = s.Namespace("photos", nil)
+	if page, _ := keys(t, photos, nil); !slices.Equal(page, []string{"a/2", "a/3", "b/1"}) {
+		t.Errorf("after reopening, keys are %v", page)
+	}
+	if err := s.Remove("photos"); err != nil {
+		t.Fatal(err)
+	}
+	if page, _ := keys(t, photos, nil); len(page) != 0 {
+		t.Errorf("removed namespace still has %v", page)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	ctx := context.Background()
+	clock := actor.NewVirtualClock(time.Unix(0, 0))
+	s := open(t, filepath.Join(t.TempDir(), "kv.db"), clock)
+
+	if _, err := s.Namespace("photos", &manifest.CapabilitySet{}); !errors.Is(err, ErrNotGranted) {
+		t.Errorf("expected ErrNotGranted, got %v", err)
+	}
+	n, err := s.Namespace("photos", &manifest.CapabilitySet{Storage: &manifest.StorageCapability{Quota: 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Put(ctx, "a", make([]byte, 9), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Put(ctx, "b", make([]byte, 9), ptr[int64](1000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Put(ctx, "c", make([]byte, 1), nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("expected ErrQuotaExceeded, got %v", err)
+	}
+	// Replacing a value only counts the difference.
+	if err := n.Put(ctx, "a", make([]byte, 8), nil); err != nil {
+		t.Errorf("replacing a value with a smaller one should fit, got %v", err)
+	}
+	// Expired entries make room.
+	clock.Advance(time.Second)
+	if err := n.Put(ctx, "c", make([]byte, 10), nil); err != nil {
+		t.Errorf("expired entries should be swept to make room, got %v", err)
+	}
+	if usage, _ := n.Usage(); usage != 20 {
+		t.Errorf("expected 20 bytes used, got %d", usage)
+	}
+}
+
+func TestJSBinding(t *testing.T) {
+	s := open(t, filepath.Join(t.TempDir(), "kv.db"), actor.RealClock)
+	n, _ := s.Namespace("notes", nil)
+	r, err := js.LoadFiles(map[string]string{"main.js": `
+		export default {
+			async message(data, env) {
+				await env.KV.put("note/1", new TextEncoder().encode("groceries"));
+				await env.KV.put("note/2", new Uint8Array([1]), 60000);
+				await env.KV.delete("note/2");
+				await env.KV.put("note/3", new DataView(new Uint8Array([0, 7, 0]).buffer, 1, 1));
+				const view = new Uint8Array(await env.KV.get("note/3"));
+				await env.KV.delete("note/3");
+				let rejected;
+				try {
+					await env.KV.put("note/4", "abcd");
+				} catch (e) {
+					rejected = e instanceof TypeError;
+				}
+				const value = new TextDecoder().decode(await env.KV.get("note/1"));
+				const { keys, cursor } = await env.KV.list({ prefix: "note/" });
+				console.log(value, JSON.stringify(keys), cursor, view.join(), rejected);
+			},
+		};
+	`}, "main.js", WithKVJS(n))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r.Deliver("go")
+	ctx := context.Background()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		more, err := r.Tick(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !more {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("actor did not go idle")
+		}
+		select {
+		case <-r.Wake():
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	logs := r.Logs().Tail(1)
+	if len(logs) != 1 || logs[0].Message != `groceries [{"key":"note/1"}] undefined 7 true` {
+		t.Errorf("unexpected logs %+v", logs)
+	}
+}
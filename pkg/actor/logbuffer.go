@@ -0,0 +1,139 @@
+package actor
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultLogBufferSize is how many log entries an actor keeps in memory by default.
+const DefaultLogBufferSize = 256
+
+// LogEntry is a log record kept in a LogBuffer.
+type LogEntry struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Attrs   []slog.Attr
+}
+
+// LogBuffer is a bounded in-memory ring of log entries, so an actor's recent
+// logs can be tailed without writing them to disk. It is safe for concurrent use.
+type LogBuffer struct {
+	mu      sync.Mutex
+	entries []LogEntry
+	next    int
+	full    bool
+}
+
+// NewLogBuffer creates a buffer holding the last size entries.
+// A size <= 0 means DefaultLogBufferSize.
+func NewLogBuffer(size int) *LogBuffer {
+	if size <= 0 {
+		size = DefaultLogBufferSize
+	}
+	return &LogBuffer{entries: make([]LogEntry, size)}
+}
+
+// Append adds an entry, overwriting the oldest one when the buffer is full.
+func (b *LogBuffer) Append(entry LogEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Tail returns up to the last n entries, oldest first. n <= 0 returns all of them.
+func (b *LogBuffer) Tail(n int) []LogEntry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var all []LogEntry
+	if b.full {
+		all = append(all, b.entries[b.next:]...)
+	}
+	all = append(all, b.entries[:b.next]...)
+	if n > 0 && n < len(all) {
+		all = all[len(all)-n:]
+	}
+	return all
+}
+
+// Handler returns a slog.Handler that records into the buffer and forwards to next.
+// next may be nil to only keep records in memory.
+func (b *LogBuffer) Handler(next slog.Handler) slog.Handler {
+	return &logBufferHandler{buffer: b, next: next}
+}
+
+type logBufferHandler struct {
+	buffer *LogBuffer
+	next   slog.Handler
+	attrs  []slog.Attr
+	group  string
+}
+
+// Enabled always accepts records: the buffer keeps every level, even those next drops.
+func (h *logBufferHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *logBufferHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := append([]slog.Attr(nil), h.attrs...)
+	record.Attrs(func(attr slog.Attr) bool {
+		attrs = append(attrs, h.qualify(attr))
+		return true
+	})
+	h.buffer.Append(LogEntry{
+		Time:    record.Time,
+		Level:   record.Level,
+		Message: record.Message,
+		Attrs:   attrs,
+	})
+
+	if h.next != nil && h.next.Enabled(ctx, record.Level) {
+		return h.next.Handle(ctx, record)
+	}
+	return nil
+}
+
+func (h *logBufferHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	clone.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, attr := range attrs {
+		clone.attrs = append(clone.attrs, h.qualify(attr))
+	}
+	if h.next != nil {
+		clone.next = h.next.WithAttrs(attrs)
+	}
+	return &clone
+}
+
+func (h *logBufferHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	clone := *h
+	clone.group = h.qualifyKey(name)
+	if h.next != nil {
+		clone.next = h.next.WithGroup(name)
+	}
+	return &clone
+}
+
+// qualify prefixes attr's key with the current group, as "group.key".
+func (h *logBufferHandler) qualify(attr slog.Attr) slog.Attr {
+	attr.Key = h.qualifyKey(attr.Key)
+	return attr
+}
+
+func (h *logBufferHandler) qualifyKey(key string) string {
+	if h.group == "" {
+		return key
+	}
+	return h.group + "." + key
+}
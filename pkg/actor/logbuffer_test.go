@@ -0,0 +1,55 @@
+package actor
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestLogBufferTail(t *testing.T) {
+	b := NewLogBuffer(3)
+	if got := b.Tail(0); len(got) != 0 {
+		t.Fatalf("expected empty buffer, got %v", got)
+	}
+	for _, msg := range []string{"a", "b", "c", "d", "e"} {
+		b.Append(LogEntry{Message: msg})
+	}
+
+	var got []string
+	for _, e := range b.Tail(0) {
+		got = append(got, e.Message)
+	}
+	if strings.Join(got, "") != "cde" {
+		t.Errorf("expected the last 3 entries oldest first, got %v", got)
+	}
+	if tail := b.Tail(2); len(tail) != 2 || tail[0].Message != "d" {
+		t.Errorf("unexpected Tail(2): %v", tail)
+	}
+}
+
+func TestLogBufferHandler(t *testing.T) {
+	var out bytes.Buffer
+	next := slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo})
+
+	b := NewLogBuffer(10)
+	logger := slog.New(b.Handler(next)).With("actor", "photos").WithGroup("req")
+	logger.Debug("only in memory")
+	logger.Info("hello", "path", "/")
+
+	entries := b.Tail(0)
+	if len(entries) != 2 {
+		t.Fatalf("expected both records in the buffer, got %d", len(entries))
+	}
+	attrs := entries[1].Attrs
+	if len(attrs) != 2 || attrs[0].Key != "actor" || attrs[1].Key != "req.path" {
+		t.Errorf("unexpected attrs %v", attrs)
+	}
+
+	if strings.Contains(out.String(), "only in memory") {
+		t.Error("debug record should not be forwarded to an info handler")
+	}
+	if !strings.Contains(out.String(), "req.path=/") {
+		t.Errorf("expected forwarded record, got %q", out.String())
+	}
+}
@@ -0,0 +1,77 @@
+package actor
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// DefaultMailboxSize is the capacity used when a mailbox is created without one.
+const DefaultMailboxSize = 256
+
+// ErrMailboxFull is returned when a message is delivered to a mailbox at capacity.
+var ErrMailboxFull = errors.New("mailbox is full")
+
+// Receiver is implemented by actors that accept messages from the outside.
+type Receiver interface {
+	Actor
+	// Deliver queues msg for the actor. It never blocks; it returns
+	// ErrMailboxFull if the actor is not keeping up.
+	Deliver(msg any) error
+}
+
+// Mailbox is a bounded FIFO queue of messages waiting for an actor.
+// It is safe for concurrent use.
+type Mailbox struct {
+	mu       sync.Mutex
+	queue    []any
+	capacity int
+}
+
+// NewMailbox creates a mailbox holding up to capacity messages.
+// A capacity <= 0 means DefaultMailboxSize.
+func NewMailbox(capacity int) *Mailbox {
+	if capacity <= 0 {
+		capacity = DefaultMailboxSize
+	}
+	return &Mailbox{capacity: capacity}
+}
+
+// Put appends msg to the mailbox.
+func (m *Mailbox) Put(msg any) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.queue) >= m.capacity {
+		return fmt.Errorf("%w (capacity %d)", ErrMailboxFull, m.capacity)
+	}
+	m.queue = append(m.queue, msg)
+	return nil
+}
+
+// Take removes and returns the oldest message.
+// It returns false if the mailbox is empty.
+func (m *Mailbox) Take() (any, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.queue) == 0 {
+		return nil, false
+	}
+	msg := m.queue[0]
+	m.queue[0] = nil // avoid memory leak
+	m.queue = m.queue[1:]
+	return msg, true
+}
+
+// Len returns how many messages are waiting.
+func (m *Mailbox) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.queue)
+}
+
+// Cap returns the mailbox capacity.
+func (m *Mailbox) Cap() int {
+	return m.capacity
+}
@@ -0,0 +1,43 @@
+package actor
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMailboxFIFO(t *testing.T) {
+	m := NewMailbox(2)
+	if err := m.Put("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Put("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Put("c"); !errors.Is(err, ErrMailboxFull) {
+		t.Fatalf("expected ErrMailboxFull, got %v", err)
+	}
+	if m.Len() != 2 {
+		t.Errorf("expected 2 queued messages, got %d", m.Len())
+	}
+
+	for _, want := range []string{"a", "b"} {
+		msg, ok := m.Take()
+		if !ok || msg != want {
+			t.Errorf("expected %q, got %v (ok=%v)", want, msg, ok)
+		}
+	}
+	if _, ok := m.Take(); ok {
+		t.Error("Take on an empty mailbox should report false")
+	}
+
+	// Space frees up once messages are taken.
+	if err := m.Put("d"); err != nil {
+		t.Errorf("Put after draining failed: %v", err)
+	}
+}
+
+func TestMailboxDefaultCapacity(t *testing.T) {
+	if got := NewMailbox(0).Cap(); got != DefaultMailboxSize {
+		t.Errorf("expected default capacity %d, got %d", DefaultMailboxSize, got)
+	}
+}
@@ -0,0 +1,217 @@
+package manifest
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Permissions flattens c into one string per grant, e.g. "network:api.example.com"
+// or "storage:quota=1048576". Limits are included so raising one shows up in a Diff.
+// The result is sorted.
+func (c CapabilitySet) Permissions() []string {
+	c = c.Normalize()
+
+	var perms []string
+	if c.Network != nil {
+		for _, host := range c.Network.Hosts {
+			perms = append(perms, "network:"+host)
+		}
+	}
+	if c.Storage != nil {
+		perms = append(perms, "storage")
+		if c.Storage.Quota > 0 {
+			perms = append(perms, fmt.Sprintf("storage:quota=%d", c.Storage.Quota))
+		}
+	}
+	if c.Timers != nil {
+		perms = append(perms, "timers")
+		if c.Timers.Max > 0 {
+			perms = append(perms, fmt.Sprintf("timers:max=%d", c.Timers.Max))
+		}
+	}
+	for _, target := range c.Actors {
+		perms = append(perms, "actors:"+target)
+	}
+	for _, device := range c.Devices {
+		perms = append(perms, "devices:"+device)
+	}
+	for _, secret := range c.Secrets {
+		perms = append(perms, "secrets:"+secret)
+	}
+	if c.Limits.Memory > 0 {
+		perms = append(perms, fmt.Sprintf("limits:memory=%d", c.Limits.Memory))
+	}
+	if c.Limits.TickBudget > 0 {
+		perms = append(perms, "limits:tick_budget="+time.Duration(c.Limits.TickBudget).String())
+	}
+	if c.Limits.HandlerBudget > 0 {
+		perms = append(perms, "limits:handler_budget="+time.Duration(c.Limits.HandlerBudget).String())
+	}
+	if c.Limits.MailboxSize > 0 {
+		perms = append(perms, fmt.Sprintf("limits:mailbox_size=%d", c.Limits.MailboxSize))
+	}
+	slices.Sort(perms)
+	return perms
+}
+
+// ChangeKind says whether a permission was granted, dropped or narrowed.
+type ChangeKind int
+
+const (
+	Added ChangeKind = iota
+	Removed
+	// Reduced is a limit set lower than before, which grants nothing.
+	Reduced
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Removed:
+		return "removed"
+	case Reduced:
+		return "reduced"
+	}
+	return "added"
+}
+
+// Change is one permission that differs between two manifests.
+type Change struct {
+	Actor      string
+	Kind       ChangeKind
+	Permission string
+}
+
+func (c Change) String() string {
+	sign := "+"
+	switch c.Kind {
+	case Removed:
+		sign = "-"
+	case Reduced:
+		sign = "~"
+	}
+	return fmt.Sprintf("%s %s %s", sign, c.Actor, c.Permission)
+}
+
+// Diff lists the permissions granted or dropped going from old to new, per
+// actor. Actors that only exist on one side contribute all their
+// permissions. A limit that is lifted grants more, so it is added as
+// "=unlimited", e.g. "storage:quota=unlimited", or "=default" for the
+// mailbox size; one set lower is Reduced rather than Added. old may be nil
+// for a fresh install. Changes are sorted by actor, then permission.
+func Diff(old, new *Manifest) []Change {
+	before := map[string][]string{}
+	after := map[string][]string{}
+	if old != nil {
+		for _, a := range old.Actors {
+			before[a.Name] = a.Capabilities.Permissions()
+		}
+	}
+	if new != nil {
+		for _, a := range new.Actors {
+			after[a.Name] = a.Capabilities.Permissions()
+		}
+	}
+
+	var changes []Change
+	for name, perms := range after {
+		for _, p := range perms {
+			if slices.Contains(before[name], p) {
+				continue
+			}
+			kind := Added
+			if lowered(p, before[name]) {
+				kind = Reduced
+			}
+			changes = append(changes, Change{Actor: name, Kind: kind, Permission: p})
+		}
+	}
+	for name, perms := range before {
+		for _, p := range perms {
+			if !slices.Contains(after[name], p) {
+				changes = append(changes, Change{Actor: name, Kind: Removed, Permission: p})
+			}
+		}
+		if granted, ok := after[name]; ok {
+			for _, p := range lifted(perms, granted) {
+				changes = append(changes, Change{Actor: name, Kind: Added, Permission: p})
+			}
+		}
+	}
+
+	slices.SortFunc(changes, func(a, b Change) int {
+		if c := strings.Compare(a.Actor, b.Actor); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a.Permission, b.Permission); c != 0 {
+			return c
+		}
+		return int(a.Kind) - int(b.Kind)
+	})
+	return changes
+}
+
+// lifted returns the limits set in before that after drops while still
+// granting what they limit, as "=unlimited" permissions. An unset mailbox
+// size isn't unlimited but the runtime's default, so it becomes "=default".
+func lifted(before, after []string) []string {
+	var out []string
+	for _, p := range before {
+		key, _, ok := strings.Cut(p, "=")
+		if !ok || slices.ContainsFunc(after, func(q string) bool { return strings.HasPrefix(q, key+"=") }) {
+			continue
+		}
+		// Limits on the actor itself always apply; the others go away with
+		// their capability.
+		if capability, _, _ := strings.Cut(key, ":"); capability != "limits" && !slices.Contains(after, capability) {
+			continue
+		}
+		if key == "limits:mailbox_size" {
+			out = append(out, key+"=default")
+		} else {
+			out = append(out, key+"=unlimited")
+		}
+	}
+	return out
+}
+
+// lowered reports whether p sets a limit lower than perms does.
+func lowered(p string, perms []string) bool {
+	key, value, ok := strings.Cut(p, "=")
+	if !ok {
+		return false
+	}
+	for _, q := range perms {
+		if k, previous, _ := strings.Cut(q, "="); k == key {
+			n, ok := limitValue(value)
+			m, okPrevious := limitValue(previous)
+			return ok && okPrevious && n < m
+		}
+	}
+	return false
+}
+
+// limitValue parses the value of a limit permission, a count or a duration.
+func limitValue(s string) (int64, bool) {
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, true
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return int64(d), true
+	}
+	return 0, false
+}
+
+// Granted filters changes down to the permissions being added, which is what
+// a user must approve before an upgrade.
+func Granted(changes []Change) []Change {
+	var out []Change
+	for _, c := range changes {
+		if c.Kind == Added {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,322 @@
+// Package manifest describes actor packages: which actors they ship and
+// what each actor is allowed to do once installed.
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FileName is where the manifest lives inside a bundle.
+const FileName = "manifest.json"
+
+// Runtimes an actor can be written for.
+const (
+	RuntimeJS   = "js"
+	RuntimeWASM = "wasm"
+)
+
+// Devices is the set of native devices an actor may request.
+var Devices = []string{"camera", "gpu", "location", "microphone", "usb"}
+
+// Manifest describes a package of actors installed together.
+type Manifest struct {
+	// Name is the package name in reverse-domain form, e.g. "dev.example.photos".
+	Name        string  `json:"name"`
+	Version     string  `json:"version"`
+	Description string  `json:"description,omitempty"`
+	Actors      []Actor `json:"actors"`
+}
+
+// Actor describes one actor shipped in the package.
+type Actor struct {
+	// Name identifies the actor inside its package, e.g. "sync".
+	Name string `json:"name"`
+	// Runtime is RuntimeJS or RuntimeWASM.
+	Runtime string `json:"runtime"`
+	// Entry is the path of the entry module inside the bundle.
+	Entry string `json:"entry"`
+	// Args and Env are the command-line arguments and environment a WASM
+	// actor sees through WASI. Args does not include the program name.
+	Args         []string          `json:"args,omitempty"`
+	Env          map[string]string `json:"env,omitempty"`
+	Capabilities CapabilitySet     `json:"capabilities,omitzero"`
+}
+
+// CapabilitySet is everything an actor was granted at install time.
+// The zero value grants nothing.
+type CapabilitySet struct {
+	Network *NetworkCapability `json:"network,omitempty"`
+	Storage *StorageCapability `json:"storage,omitempty"`
+	Timers  *TimerCapability   `json:"timers,omitempty"`
+	// Actors lists the actors this one may send messages to.
+	Actors []string `json:"actors,omitempty"`
+	// Devices lists the native devices the actor may use, from Devices.
+	Devices []string `json:"devices,omitempty"`
+	// Secrets lists the names of secrets exposed to the actor.
+	Secrets []string       `json:"secrets,omitempty"`
+	Limits  ResourceLimits `json:"limits,omitzero"`
+}
+
+// NetworkCapability grants outbound network access.
+type NetworkCapability struct {
+	// Hosts are exact host names, "*.example.com" for any subdomain, or "*" for any host.
+	Hosts []string `json:"hosts"`
+}
+
+// StorageCapability grants persistent storage.
+type StorageCapability struct {
+	// Quota is the most bytes the actor may store. Zero means no limit.
+	Quota int64 `json:"quota,omitempty"`
+}
+
+// TimerCapability grants setTimeout/setInterval.
+type TimerCapability struct {
+	// Max is how many timers may be pending at once. Zero means no limit.
+	Max int `json:"max,omitempty"`
+}
+
+// ResourceLimits bounds how much of the device an actor may use.
+// Zero values mean the runtime defaults.
+type ResourceLimits struct {
+	Memory        int64    `json:"memory,omitempty"`
+	TickBudget    Duration `json:"tick_budget,omitempty"`
+	HandlerBudget Duration `json:"handler_budget,omitempty"`
+	MailboxSize   int      `json:"mailbox_size,omitempty"`
+}
+
+// Duration is a time.Duration written as a string like "50ms" in JSON.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"50ms\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Parse decodes and validates a manifest. Unknown fields are rejected, so a
+// typo can't silently drop a permission.
+func Parse(data []byte) (*Manifest, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var m Manifest
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Marshal encodes m in canonical form: normalized lists, actors sorted by
+// name and stable indentation, so equal manifests serialize to equal bytes.
+func Marshal(m *Manifest) ([]byte, error) {
+	canonical := m.Normalize()
+	data, err := json.MarshalIndent(canonical, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Normalize returns a copy of m with lists lowercased where case does not
+// matter, sorted and deduplicated.
+func (m *Manifest) Normalize() *Manifest {
+	out := *m
+	out.Actors = make([]Actor, len(m.Actors))
+	for i, a := range m.Actors {
+		a.Capabilities = a.Capabilities.Normalize()
+		out.Actors[i] = a
+	}
+	slices.SortFunc(out.Actors, func(a, b Actor) int { return strings.Compare(a.Name, b.Name) })
+	return &out
+}
+
+// Normalize returns a copy of c with sorted, deduplicated lists.
+func (c CapabilitySet) Normalize() CapabilitySet {
+	if c.Network != nil {
+		c.Network = &NetworkCapability{Hosts: normalizeList(c.Network.Hosts, true)}
+	}
+	if c.Storage != nil {
+		storage := *c.Storage
+		c.Storage = &storage
+	}
+	if c.Timers != nil {
+		timers := *c.Timers
+		c.Timers = &timers
+	}
+	c.Actors = normalizeList(c.Actors, false)
+	c.Devices = normalizeList(c.Devices, true)
+	c.Secrets = normalizeList(c.Secrets, false)
+	return c
+}
+
+func normalizeList(list []string, lower bool) []string {
+	if list == nil {
+		return nil
+	}
+	out := make([]string, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if lower {
+			s = strings.ToLower(s)
+		}
+		out = append(out, s)
+	}
+	slices.Sort(out)
+	return slices.Compact(out)
+}
+
+var (
+	packageNamePattern = regexp.MustCompile(`^[a-z0-9]+(\.[a-z0-9][a-z0-9-]*)+$`)
+	actorNamePattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+	versionPattern     = regexp.MustCompile(`^\d+\.\d+\.\d+([-+][0-9A-Za-z.+-]+)?$`)
+	hostPattern        = regexp.MustCompile(`^(\*|(\*\.)?[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*)$`)
+	secretNamePattern  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// ValidationError describes one invalid field of a manifest.
+type ValidationError struct {
+	Field   string
+	Problem string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Problem
+}
+
+// Validate checks the manifest for mistakes. All problems are reported
+// together, each as a *ValidationError.
+func (m *Manifest) Validate() error {
+	var errs []error
+	fail := func(field, format string, args ...any) {
+		errs = append(errs, &ValidationError{Field: field, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	if !packageNamePattern.MatchString(m.Name) {
+		fail("name", "%q is not a reverse-domain name like dev.example.app", m.Name)
+	}
+	if !versionPattern.MatchString(m.Version) {
+		fail("version", "%q is not a version like 1.2.3", m.Version)
+	}
+	if len(m.Actors) == 0 {
+		fail("actors", "a package must contain at least one actor")
+	}
+
+	names := map[string]bool{}
+	for i, a := range m.Actors {
+		field := fmt.Sprintf("actors[%d]", i)
+		if !actorNamePattern.MatchString(a.Name) {
+			fail(field+".name", "%q is not a lowercase slug", a.Name)
+		} else if names[a.Name] {
+			fail(field+".name", "duplicate actor %q", a.Name)
+		}
+		names[a.Name] = true
+
+		if a.Runtime != RuntimeJS && a.Runtime != RuntimeWASM {
+			fail(field+".runtime", "must be %q or %q, got %q", RuntimeJS, RuntimeWASM, a.Runtime)
+		}
+		if !validEntry(a.Entry) {
+			fail(field+".entry", "%q is not a relative path inside the bundle", a.Entry)
+		}
+		if a.Runtime != RuntimeWASM && (len(a.Args) > 0 || len(a.Env) > 0) {
+			fail(field, "args and env are only supported by %q actors", RuntimeWASM)
+		}
+		for j, arg := range a.Args {
+			if strings.ContainsRune(arg, 0) {
+				fail(fmt.Sprintf("%s.args[%d]", field, j), "must not contain NUL")
+			}
+		}
+		for _, key := range slices.Sorted(maps.Keys(a.Env)) {
+			if key == "" || strings.ContainsAny(key, "=\x00") || strings.ContainsRune(a.Env[key], 0) {
+				fail(field+".env", "%q is not a valid environment variable", key)
+			}
+		}
+		a.Capabilities.validate(field+".capabilities", fail)
+	}
+	return errors.Join(errs...)
+}
+
+func (c CapabilitySet) validate(field string, fail func(field, format string, args ...any)) {
+	if c.Network != nil {
+		for i, host := range c.Network.Hosts {
+			if !hostPattern.MatchString(strings.ToLower(host)) {
+				fail(fmt.Sprintf("%s.network.hosts[%d]", field, i), "%q is not a host pattern", host)
+			}
+		}
+	}
+	if c.Storage != nil && c.Storage.Quota < 0 {
+		fail(field+".storage.quota", "must not be negative")
+	}
+	if c.Timers != nil && c.Timers.Max < 0 {
+		fail(field+".timers.max", "must not be negative")
+	}
+	for i, target := range c.Actors {
+		if strings.TrimSpace(target) == "" {
+			fail(fmt.Sprintf("%s.actors[%d]", field, i), "must not be empty")
+		}
+	}
+	for i, device := range c.Devices {
+		if !slices.Contains(Devices, strings.ToLower(device)) {
+			fail(fmt.Sprintf("%s.devices[%d]", field, i), "unknown device %q, expected one of %s", device, strings.Join(Devices, ", "))
+		}
+	}
+	for i, secret := range c.Secrets {
+		if !secretNamePattern.MatchString(secret) {
+			fail(fmt.Sprintf("%s.secrets[%d]", field, i), "%q is not a valid secret name", secret)
+		}
+	}
+
+	limits := c.Limits
+	if limits.Memory < 0 {
+		fail(field+".limits.memory", "must not be negative")
+	}
+	if limits.TickBudget < 0 {
+		fail(field+".limits.tick_budget", "must not be negative")
+	}
+	if limits.HandlerBudget < 0 {
+		fail(field+".limits.handler_budget", "must not be negative")
+	}
+	if limits.MailboxSize < 0 {
+		fail(field+".limits.mailbox_size", "must not be negative")
+	}
+}
+
+func validEntry(entry string) bool {
+	if entry == "" || strings.HasPrefix(entry, "/") || strings.Contains(entry, "\\") {
+		return false
+	}
+	clean := path.Clean(entry)
+	return clean == entry && clean != "." && !strings.HasPrefix(clean, "../")
+}
+
+// Actor returns the actor with the given name.
+func (m *Manifest) Actor(name string) (Actor, bool) {
+	for _, a := range m.Actors {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return Actor{}, false
+}
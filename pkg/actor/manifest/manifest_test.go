@@ -0,0 +1,253 @@
+package manifest
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const example = `{
+	"name": "dev.example.photos",
+	"version": "1.2.0",
+	"actors": [
+		{
+			"name": "sync",
+			"runtime": "js",
+			"entry": "sync/main.js",
+			"capabilities": {
+				"network": {"hosts": ["API.example.com", "*.cdn.example.com", "api.example.com"]},
+				"storage": {"quota": 1048576},
+				"timers": {},
+				"actors": ["thumbnailer"],
+				"limits": {"tick_budget": "50ms", "mailbox_size": 64}
+			}
+		},
+		{
+			"name": "thumbnailer",
+			"runtime": "wasm",
+			"entry": "thumbs.wasm",
+			"capabilities": {"devices": ["gpu"]}
+		}
+	]
+}`
+
+func TestParse(t *testing.T) {
+	m, err := Parse([]byte(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Name != "dev.example.photos" || len(m.Actors) != 2 {
+		t.Fatalf("unexpected manifest: %+v", m)
+	}
+
+	sync, ok := m.Actor("sync")
+	if !ok {
+		t.Fatal("actor sync not found")
+	}
+	caps := sync.Capabilities
+	if caps.Storage == nil || caps.Storage.Quota != 1<<20 {
+		t.Errorf("expected a 1MiB storage quota, got %+v", caps.Storage)
+	}
+	if caps.Timers == nil {
+		t.Error("expected timers to be granted")
+	}
+	if time.Duration(caps.Limits.TickBudget) != 50*time.Millisecond {
+		t.Errorf("expected a 50ms tick budget, got %v", time.Duration(caps.Limits.TickBudget))
+	}
+
+	thumbs, _ := m.Actor("thumbnailer")
+	if thumbs.Capabilities.Network != nil || thumbs.Capabilities.Timers != nil {
+		t.Errorf("ungranted capabilities should be nil, got %+v", thumbs.Capabilities)
+	}
+}
+
+func TestParseRejectsUnknownFields(t *testing.T) {
+	data := strings.Replace(example, `"timers": {}`, `"timer": {}`, 1)
+	if _, err := Parse([]byte(data)); err == nil || !strings.Contains(err.Error(), "timer") {
+		t.Errorf("expected an unknown field error, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	m := &Manifest{
+		Name:    "photos",
+		Version: "1",
+		Actors: []Actor{
+			{Name: "a", Runtime: "lua", Entry: "../main.js"},
+			{Name: "a", Runtime: "js", Entry: "main.js", Args: []string{"-v"}, Capabilities: CapabilitySet{
+				Network: &NetworkCapability{Hosts: []string{"http://example.com"}},
+				Devices: []string{"teleporter"},
+				Secrets: []string{"API KEY"},
+				Limits:  ResourceLimits{Memory: -1},
+			}},
+			{Name: "b", Runtime: "wasm", Entry: "b.wasm", Env: map[string]string{"HOME": "/", "A=B": "x"}},
+		},
+	}
+
+	err := m.Validate()
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	var fields []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var verr *ValidationError
+		if !errors.As(e, &verr) {
+			t.Fatalf("expected *ValidationError, got %T", e)
+		}
+		fields = append(fields, verr.Field)
+	}
+	want := []string{
+		"name",
+		"version",
+		"actors[0].runtime",
+		"actors[0].entry",
+		"actors[1].name",
+		"actors[1]",
+		"actors[1].capabilities.network.hosts[0]",
+		"actors[1].capabilities.devices[0]",
+		"actors[1].capabilities.secrets[0]",
+		"actors[1].capabilities.limits.memory",
+		"actors[2].env",
+	}
+	if !slices.Equal(fields, want) {
+		t.Errorf("expected errors for\n%v\ngot\n%v", want, fields)
+	}
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	m, err := Parse([]byte(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Reordering actors and list entries must not change the encoding.
+	slices.Reverse(m.Actors)
+	slices.Reverse(m.Actors[1].Capabilities.Network.Hosts)
+	second, err := Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != string(second) {
+		t.Errorf("canonical encodings differ:\n%s\n%s", first, second)
+	}
+
+	if !strings.Contains(string(first), `"hosts": [
+            "*.cdn.example.com",
+            "api.example.com"
+          ]`) {
+		t.Errorf("expected sorted, deduplicated, lowercased hosts:\n%s", first)
+	}
+	if !strings.Contains(string(first), `"tick_budget": "50ms"`) {
+		t.Errorf("expected durations as strings:\n%s", first)
+	}
+
+	// The canonical form parses back to the same manifest.
+	again, err := Parse(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, _ := Marshal(again)
+	if string(third) != string(first) {
+		t.Errorf("round trip changed the encoding:\n%s\n%s", first, third)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old, err := Parse([]byte(example))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer, _ := Parse([]byte(example))
+	newer.Version = "1.3.0"
+	sync := &newer.Actors[0].Capabilities
+	sync.Network.Hosts = []string{"api.example.com", "upload.example.com"}
+	sync.Storage.Quota = 2 << 20
+	sync.Secrets = []string{"API_TOKEN"}
+	newer.Actors = newer.Actors[:1]
+	newer.Actors = append(newer.Actors, Actor{Name: "indexer", Runtime: "js", Entry: "index.js",
+		Capabilities: CapabilitySet{Timers: &TimerCapability{Max: 4}}})
+
+	var got []string
+	for _, c := range Diff(old, newer) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"+ indexer timers",
+		"+ indexer timers:max=4",
+		"- sync network:*.cdn.example.com",
+		"+ sync network:upload.example.com",
+		"+ sync secrets:API_TOKEN",
+		"- sync storage:quota=1048576",
+		"+ sync storage:quota=2097152",
+		"- thumbnailer devices:gpu",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	granted := Granted(Diff(old, newer))
+	if len(granted) != 5 {
+		t.Errorf("expected 5 granted permissions, got %v", granted)
+	}
+
+	if changes := Diff(old, old); len(changes) != 0 {
+		t.Errorf("a manifest should not differ from itself, got %v", changes)
+	}
+	if changes := Diff(nil, old); len(Granted(changes)) != len(changes) {
+		t.Errorf("a fresh install should only add permissions, got %v", changes)
+	}
+
+	// Lifting a limit needs approval like raising it does.
+	lifted, _ := Parse([]byte(example))
+	sync = &lifted.Actors[0].Capabilities
+	sync.Storage.Quota = 0
+	sync.Limits = ResourceLimits{}
+	got = nil
+	for _, c := range Granted(Diff(old, lifted)) {
+		got = append(got, c.String())
+	}
+	want = []string{
+		"+ sync limits:mailbox_size=default",
+		"+ sync limits:tick_budget=unlimited",
+		"+ sync storage:quota=unlimited",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("lifted limits should be granted, got %v, want %v", got, want)
+	}
+
+	// Lowering a limit narrows the grant, there is nothing to approve.
+	lowered, _ := Parse([]byte(example))
+	lowered.Actors[0].Capabilities.Storage.Quota = 1 << 10
+	lowered.Actors[0].Capabilities.Limits.TickBudget /= 2
+	got = nil
+	for _, c := range Diff(old, lowered) {
+		got = append(got, c.String())
+	}
+	want = []string{
+		"~ sync limits:tick_budget=25ms",
+		"- sync limits:tick_budget=50ms",
+		"~ sync storage:quota=1024",
+		"- sync storage:quota=1048576",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("unexpected diff:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	if granted := Granted(Diff(old, lowered)); len(granted) != 0 {
+		t.Errorf("lowered limits should not need approval, got %v", granted)
+	}
+
+	// Dropping the capability drops its limit with it.
+	lifted.Actors[0].Capabilities.Storage = nil
+	for _, c := range Granted(Diff(old, lifted)) {
+		if strings.HasPrefix(c.Permission, "storage") {
+			t.Errorf("unexpected grant %v", c)
+		}
+	}
+}
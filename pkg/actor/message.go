@@ -0,0 +1,41 @@
+package actor
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotReceiver is returned when a message is sent to an actor that does
+// not implement Receiver.
+var ErrNotReceiver = errors.New("actor does not accept messages")
+
+// Message is a message from one actor to another, delivered to the
+// target's Receiver. A request carries an ID, which the reply echoes in
+// ReplyTo, and is answered with a message sent back to From.
+type Message struct {
+	// From is the sender's actor ID.
+	From string
+	// ID correlates a request with its reply. It is empty for one-way messages.
+	ID string
+	// ReplyTo is the ID of the request this message answers.
+	ReplyTo string
+	// Data is a copy owned by the target; runtimes never share it with the
+	// sender. Its representation is up to the runtimes, but it marshals to
+	// JSON for runtimes that don't know it.
+	Data any
+	// Error is set on replies to requests that failed.
+	Error string `json:",omitempty"`
+}
+
+// Send delivers msg to the actor with the given ID or name.
+func (r *Registry) Send(to string, msg Message) error {
+	e, ok := r.Lookup(to)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrActorNotFound, to)
+	}
+	receiver, ok := e.Actor.(Receiver)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNotReceiver, to)
+	}
+	return receiver.Deliver(msg)
+}
@@ -0,0 +1,277 @@
+package actor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Errors returned by the Registry, besides ErrActorNotFound and ErrActorExists.
+var (
+	ErrNameTaken   = errors.New("name already taken")
+	ErrInvalidName = errors.New("invalid actor name")
+	ErrInvalidID   = errors.New("invalid actor id")
+)
+
+// namePattern accepts slugs ("photos"), reverse-domain names
+// ("dev.example.photos") and either followed by an actor name
+// ("dev.example.photos/sync").
+var namePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*(\.[a-z0-9][a-z0-9-]*)*(/[a-z0-9][a-z0-9-]*)?$`)
+
+// Entry is a snapshot of a registered actor.
+type Entry struct {
+	// ID is the UUIDv7 assigned at registration. It never changes.
+	ID string
+	// Names are the aliases the actor can also be looked up by.
+	Names   []string
+	Actor   Actor
+	Spawned time.Time
+}
+
+// RegistryConfig configures a Registry.
+type RegistryConfig struct {
+	// Clock stamps registrations and their IDs. Defaults to RealClock.
+	Clock Clock
+	// Random is the source of the random bits of IDs. Defaults to crypto/rand.
+	Random io.Reader
+}
+
+// Registry maps actor IDs and names to the running actors, so that
+// messaging, routing and admin tooling agree on what is running. Hooks
+// observe actors being spawned and shut down. It is safe for concurrent use.
+type Registry struct {
+	config RegistryConfig
+
+	mu      sync.RWMutex
+	entries map[string]*Entry // by ID
+	names   map[string]string // name to ID
+
+	hooksMu  sync.Mutex
+	hooks    []registryHook
+	nextHook int
+}
+
+// registryHook is a lifecycle hook; exactly one of its funcs is set.
+type registryHook struct {
+	id       int
+	spawn    func(Entry)
+	shutdown func(Entry)
+}
+
+// NewRegistry creates an empty Registry.
+func NewRegistry(config RegistryConfig) *Registry {
+	if config.Clock == nil {
+		config.Clock = RealClock
+	}
+	return &Registry{
+		config:  config,
+		entries: make(map[string]*Entry),
+		names:   make(map[string]string),
+	}
+}
+
+// Register adds a, assigning it a new ID, under the given names.
+// Spawn hooks run before it returns.
+func (r *Registry) Register(a Actor, names ...string) (Entry, error) {
+	id, err := NewID(r.config.Clock.Now(), r.config.Random)
+	if err != nil {
+		return Entry{}, err
+	}
+	return r.RegisterAs(id, a, names...)
+}
+
+// RegisterAs is like Register with an ID chosen by the caller, for actors
+// that need to know their ID before they run, or that keep it across
+// restarts. id must be a UUIDv7 as returned by NewID.
+func (r *Registry) RegisterAs(id string, a Actor, names ...string) (Entry, error) {
+	if !ValidID(id) {
+		return Entry{}, fmt.Errorf("%w: %q", ErrInvalidID, id)
+	}
+	if err := checkNames(names); err != nil {
+		return Entry{}, err
+	}
+	now := r.config.Clock.Now()
+
+	r.mu.Lock()
+	if _, exists := r.entries[id]; exists {
+		r.mu.Unlock()
+		return Entry{}, fmt.Errorf("%w: %s", ErrActorExists, id)
+	}
+	for _, name := range names {
+		if _, taken := r.names[name]; taken {
+			r.mu.Unlock()
+			return Entry{}, fmt.Errorf("%w: %s", ErrNameTaken, name)
+		}
+	}
+	e := &Entry{ID: id, Names: slices.Clone(names), Actor: a, Spawned: now}
+	r.entries[id] = e
+	for _, name := range names {
+		r.names[name] = id
+	}
+	snapshot := e.snapshot()
+	r.mu.Unlock()
+
+	r.fire(func(h registryHook) {
+		if h.spawn != nil {
+			h.spawn(snapshot)
+		}
+	})
+	return snapshot, nil
+}
+
+// Unregister removes the actor with the given ID or name, along with all
+// its names. Shutdown hooks run before it returns.
+func (r *Registry) Unregister(idOrName string) error {
+	r.mu.Lock()
+	e, ok := r.find(idOrName)
+	if !ok {
+		r.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrActorNotFound, idOrName)
+	}
+	delete(r.entries, e.ID)
+	for _, name := range e.Names {
+		delete(r.names, name)
+	}
+	snapshot := e.snapshot()
+	r.mu.Unlock()
+
+	r.fire(func(h registryHook) {
+		if h.shutdown != nil {
+			h.shutdown(snapshot)
+		}
+	})
+	return nil
+}
+
+// Lookup returns the actor with the given ID or name.
+func (r *Registry) Lookup(idOrName string) (Entry, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.find(idOrName)
+	if !ok {
+		return Entry{}, false
+	}
+	return e.snapshot(), true
+}
+
+// find resolves an ID or name. Must be called with r.mu held.
+func (r *Registry) find(idOrName string) (*Entry, bool) {
+	if id, ok := r.names[idOrName]; ok {
+		idOrName = id
+	}
+	e, ok := r.entries[idOrName]
+	return e, ok
+}
+
+// Alias adds a name to the actor with the given ID or name.
+func (r *Registry) Alias(idOrName, name string) error {
+	if err := checkNames([]string{name}); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.find(idOrName)
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrActorNotFound, idOrName)
+	}
+	if _, taken := r.names[name]; taken {
+		return fmt.Errorf("%w: %s", ErrNameTaken, name)
+	}
+	e.Names = append(e.Names, name)
+	r.names[name] = e.ID
+	return nil
+}
+
+// Unalias removes a name, leaving the actor registered under its ID and
+// other names.
+func (r *Registry) Unalias(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id, ok := r.names[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrActorNotFound, name)
+	}
+	delete(r.names, name)
+	e := r.entries[id]
+	e.Names = slices.DeleteFunc(e.Names, func(n string) bool { return n == name })
+	return nil
+}
+
+// Entries returns every registered actor, in the order they were spawned.
+func (r *Registry) Entries() []Entry {
+	r.mu.RLock()
+	entries := make([]Entry, 0, len(r.entries))
+	for _, e := range r.entries {
+		entries = append(entries, e.snapshot())
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		if c := a.Spawned.Compare(b.Spawned); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return entries
+}
+
+// OnSpawn calls fn with every actor registered from now on, until the
+// returned function is called.
+func (r *Registry) OnSpawn(fn func(Entry)) (remove func()) {
+	return r.addHook(registryHook{spawn: fn})
+}
+
+// OnShutdown calls fn with every actor unregistered from now on, until the
+// returned function is called.
+func (r *Registry) OnShutdown(fn func(Entry)) (remove func()) {
+	return r.addHook(registryHook{shutdown: fn})
+}
+
+func (r *Registry) addHook(h registryHook) func() {
+	r.hooksMu.Lock()
+	defer r.hooksMu.Unlock()
+	r.nextHook++
+	h.id = r.nextHook
+	r.hooks = append(r.hooks, h)
+	return func() {
+		r.hooksMu.Lock()
+		defer r.hooksMu.Unlock()
+		r.hooks = slices.DeleteFunc(r.hooks, func(other registryHook) bool { return other.id == h.id })
+	}
+}
+
+// fire runs call for each hook, in the order they were added, without
+// holding any lock so hooks may use the registry.
+func (r *Registry) fire(call func(registryHook)) {
+	r.hooksMu.Lock()
+	hooks := slices.Clone(r.hooks)
+	r.hooksMu.Unlock()
+	for _, h := range hooks {
+		call(h)
+	}
+}
+
+func (e *Entry) snapshot() Entry {
+	s := *e
+	s.Names = slices.Clone(e.Names)
+	return s
+}
+
+// checkNames rejects malformed names, names that could be mistaken for an
+// ID, and duplicates.
+func checkNames(names []string) error {
+	for i, name := range names {
+		switch {
+		case !namePattern.MatchString(name) || ValidID(name):
+			return fmt.Errorf("%w: %q", ErrInvalidName, name)
+		case slices.Contains(names[:i], name):
+			return fmt.Errorf("%w: %s", ErrNameTaken, name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package actor
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewID(t *testing.T) {
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	first, err := NewID(start, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := NewID(start.Add(time.Millisecond), nil)
+	if !ValidID(first) || !ValidID(second) {
+		t.Fatalf("expected UUIDv7s, got %s and %s", first, second)
+	}
+	if first[:13] != "019b7ca9-8c88" {
+		t.Errorf("id should start with the timestamp, got %s", first)
+	}
+	if first >= second {
+		t.Errorf("ids should sort by time, got %s >= %s", first, second)
+	}
+	if ValidID("photos") || ValidID("019b7c77-8ce8-4000-8000-000000000000") {
+		t.Error("only UUIDv7s are valid ids")
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	clock := NewVirtualClock(time.Unix(1000, 0))
+	r := NewRegistry(RegistryConfig{Clock: clock})
+
+	var mu sync.Mutex
+	var events []string
+	record := func(kind string) func(Entry) {
+		return func(e Entry) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, kind+" "+e.Names[0])
+		}
+	}
+	r.OnSpawn(record("spawn"))
+	removeShutdown := r.OnShutdown(record("shutdown"))
+
+	photos, err := r.Register(&countingActor{}, "dev.example.photos/sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Millisecond)
+	notes, err := r.Register(&countingActor{}, "notes", "dev.example.notes")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.Register(&countingActor{}, "notes"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("expected ErrNameTaken, got %v", err)
+	}
+	for _, name := range []string{"Notes", "a/b/c", "", notes.ID} {
+		if _, err := r.Register(&countingActor{}, name); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("expected %q to be invalid, got %v", name, err)
+		}
+	}
+
+	for _, key := range []string{notes.ID, "notes", "dev.example.notes"} {
+		if e, ok := r.Lookup(key); !ok || e.ID != notes.ID {
+			t.Errorf("Lookup(%q) = %v, %v", key, e.ID, ok)
+		}
+	}
+
+	if err := r.Alias(photos.ID, "photos"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Alias("photos", "notes"); !errors.Is(err, ErrNameTaken) {
+		t.Errorf("expected ErrNameTaken, got %v", err)
+	}
+	if err := r.Unalias("notes"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Lookup("notes"); ok {
+		t.Error("an unaliased name should not resolve")
+	}
+
+	entries := r.Entries()
+	if len(entries) != 2 || entries[0].ID != photos.ID || !slices.Equal(entries[0].Names, []string{"dev.example.photos/sync", "photos"}) {
+		t.Errorf("unexpected entries %+v", entries)
+	}
+
+	if err := r.Unregister("photos"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := r.Lookup("dev.example.photos/sync"); ok {
+		t.Error("every name should go with the actor")
+	}
+	if err := r.Unregister("photos"); !errors.Is(err, ErrActorNotFound) {
+		t.Errorf("expected ErrActorNotFound, got %v", err)
+	}
+	removeShutdown()
+	if err := r.Unregister(notes.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"spawn dev.example.photos/sync", "spawn notes", "shutdown dev.example.photos/sync"}
+	if !slices.Equal(events, want) {
+		t.Errorf("hooks saw %v, want %v", events, want)
+	}
+}
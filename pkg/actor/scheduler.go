@@ -0,0 +1,387 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Errors returned by the Scheduler.
+var (
+	ErrActorExists   = errors.New("actor already exists")
+	ErrActorNotFound = errors.New("actor not found")
+)
+
+// State describes what a scheduled actor is currently doing.
+type State int
+
+const (
+	// StateRunning means the actor reported more work and keeps being ticked.
+	StateRunning State = iota
+	// StateParked means the actor reported it is idle and waits to be woken up.
+	StateParked
+	// StateStopped means the actor was stopped or the scheduler shut down.
+	StateStopped
+	// StateFailed means the actor's Tick returned an error.
+	StateFailed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateRunning:
+		return "running"
+	case StateParked:
+		return "parked"
+	case StateStopped:
+		return "stopped"
+	case StateFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+// Status is a snapshot of a scheduled actor.
+type Status struct {
+	State State
+	// Err is the error that made the actor fail, if any.
+	Err error
+}
+
+// SchedulerConfig configures a Scheduler.
+type SchedulerConfig struct {
+	// Workers bounds how many actors may be inside Tick at the same time.
+	// Zero means no bound, every actor ticks on its own goroutine freely.
+	Workers int
+
+	// PollInterval is how long the scheduler waits before ticking an actor
+	// that reported more work again. Defaults to DefaultPollInterval.
+	// Actors implementing Waiter are never polled.
+	PollInterval time.Duration
+
+	// Clock is used to sleep until deadlines. Defaults to RealClock.
+	Clock Clock
+
+	// OnError is called, from the actor's goroutine, when its Tick fails.
+	// The actor is not ticked again afterwards.
+	OnError func(id string, err error)
+}
+
+// DefaultPollInterval is the PollInterval used when none is configured.
+const DefaultPollInterval = time.Millisecond
+
+// Scheduler owns a set of actors and drives their Tick loops.
+// Each actor runs on its own goroutine; actors returning false from Tick
+// are parked until woken up with Wake. Actors implementing Waiter sleep
+// until their next deadline or external event instead.
+type Scheduler struct {
+	config SchedulerConfig
+	sem    chan struct{}
+
+	mu       sync.Mutex
+	ctx      context.Context // set while Run is active
+	actors   map[string]*task
+	running  sync.WaitGroup
+	shutdown bool
+}
+
+// task is the scheduler's bookkeeping for a single actor.
+type task struct {
+	id     string
+	actor  Actor
+	wake   chan struct{}
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewScheduler creates a Scheduler. Actors may be spawned before Run is called,
+// they start ticking once it is.
+func NewScheduler(config SchedulerConfig) *Scheduler {
+	if config.PollInterval <= 0 {
+		config.PollInterval = DefaultPollInterval
+	}
+	if config.Clock == nil {
+		config.Clock = RealClock
+	}
+	s := &Scheduler{
+		config: config,
+		actors: make(map[string]*task),
+	}
+	if config.Workers > 0 {
+		s.sem = make(chan struct{}, config.Workers)
+	}
+	return s
+}
+
+// Spawn adds an actor under the given id. If the scheduler is running the
+// actor starts ticking immediately. It fails with ErrShutdown once the
+// scheduler was shut down.
+func (s *Scheduler) Spawn(id string, a Actor) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shutdown {
+		return ErrShutdown
+	}
+	if _, exists := s.actors[id]; exists {
+		return fmt.Errorf("%w: %s", ErrActorExists, id)
+	}
+	t := &task{
+		id:    id,
+		actor: a,
+		wake:  make(chan struct{}, 1),
+	}
+	s.actors[id] = t
+	if s.ctx != nil {
+		s.start(s.ctx, t)
+	}
+	return nil
+}
+
+// Stop stops ticking the actor and removes it from the scheduler.
+// It waits for an in-flight Tick to return. The actor is not shut down;
+// see Shutdown.
+func (s *Scheduler) Stop(id string) error {
+	s.mu.Lock()
+	t, ok := s.actors[id]
+	if ok {
+		delete(s.actors, id)
+	}
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrActorNotFound, id)
+	}
+	if t.cancel != nil {
+		t.cancel()
+		<-t.done
+	}
+	return nil
+}
+
+// Wake makes a parked actor tick again.
+func (s *Scheduler) Wake(id string) error {
+	s.mu.Lock()
+	t, ok := s.actors[id]
+	s.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrActorNotFound, id)
+	}
+	t.signal()
+	return nil
+}
+
+// Status returns a snapshot of the actor's state.
+func (s *Scheduler) Status(id string) (Status, error) {
+	s.mu.Lock()
+	t, ok := s.actors[id]
+	s.mu.Unlock()
+
+	if !ok {
+		return Status{}, fmt.Errorf("%w: %s", ErrActorNotFound, id)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status, nil
+}
+
+// Run starts every spawned actor and blocks until ctx is done.
+// It then waits for all actors to leave Tick before returning ctx.Err().
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.mu.Lock()
+	if s.ctx != nil {
+		s.mu.Unlock()
+		return errors.New("scheduler is already running")
+	}
+	s.ctx = ctx
+	for _, t := range s.actors {
+		s.start(ctx, t)
+	}
+	s.mu.Unlock()
+
+	<-ctx.Done()
+
+	s.running.Wait()
+	s.mu.Lock()
+	s.ctx = nil
+	s.mu.Unlock()
+	return ctx.Err()
+}
+
+// Serve runs the actors like Run until ctx is done, then shuts them down
+// gracefully, giving them up to grace to finish their work. It returns nil
+// once every actor shut down cleanly, so a host can exit on a signal:
+//
+//	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+//	defer stop()
+//	if err := scheduler.Serve(ctx, 10*time.Second); err != nil {
+//		log.Fatal(err)
+//	}
+func (s *Scheduler) Serve(ctx context.Context, grace time.Duration) error {
+	if err := s.Run(ctx); !errors.Is(err, ctx.Err()) {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), grace)
+	defer cancel()
+	return s.Shutdown(ctx, true)
+}
+
+// Shutdown stops ticking every actor, then shuts down those implementing
+// Shutdowner, concurrently, and waits for them until ctx is done. Actors
+// are removed and no more can be spawned. It returns the actors' errors.
+func (s *Scheduler) Shutdown(ctx context.Context, graceful bool) error {
+	s.mu.Lock()
+	s.shutdown = true
+	tasks := s.actors
+	s.actors = make(map[string]*task)
+	s.mu.Unlock()
+
+	for _, t := range tasks {
+		if t.cancel != nil {
+			t.cancel()
+		}
+	}
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, t := range tasks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if t.done != nil {
+				<-t.done
+			}
+			t.setStatus(StateStopped, nil)
+			sd, ok := t.actor.(Shutdowner)
+			if !ok {
+				return
+			}
+			if err := sd.Shutdown(ctx, graceful); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", t.id, err))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		mu.Lock()
+		errs = append(errs, ctx.Err())
+		mu.Unlock()
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	return errors.Join(errs...)
+}
+
+// start launches the goroutine driving t. Must be called with s.mu held.
+func (s *Scheduler) start(ctx context.Context, t *task) {
+	ctx, t.cancel = context.WithCancel(ctx)
+	t.done = make(chan struct{})
+	s.running.Add(1)
+	go func() {
+		defer s.running.Done()
+		defer close(t.done)
+		defer t.cancel()
+		s.loop(ctx, t)
+	}()
+}
+
+func (s *Scheduler) loop(ctx context.Context, t *task) {
+	for {
+		if !s.acquire(ctx) {
+			t.setStatus(StateStopped, nil)
+			return
+		}
+		more, err := t.actor.Tick(ctx)
+		s.release()
+
+		if ctx.Err() != nil {
+			t.setStatus(StateStopped, nil)
+			return
+		}
+		if err != nil {
+			t.setStatus(StateFailed, err)
+			if s.config.OnError != nil {
+				s.config.OnError(t.id, err)
+			}
+			return
+		}
+
+		if more {
+			t.setStatus(StateRunning, nil)
+		} else {
+			t.setStatus(StateParked, nil)
+		}
+		s.wait(ctx, t, more)
+	}
+}
+
+// wait blocks until t should be ticked again.
+func (s *Scheduler) wait(ctx context.Context, t *task, more bool) {
+	var (
+		timeout <-chan time.Time
+		events  <-chan struct{}
+	)
+	clock := s.config.Clock
+	if w, ok := t.actor.(Waiter); ok {
+		events = w.Wake()
+		if deadline, ok := w.NextDeadline(); ok {
+			timeout = clock.After(deadline.Sub(clock.Now()))
+		}
+	} else if more {
+		timeout = clock.After(s.config.PollInterval)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-t.wake:
+	case <-events:
+	case <-timeout:
+	}
+}
+
+func (s *Scheduler) acquire(ctx context.Context) bool {
+	if s.sem == nil {
+		return ctx.Err() == nil
+	}
+	select {
+	case s.sem <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (s *Scheduler) release() {
+	if s.sem != nil {
+		<-s.sem
+	}
+}
+
+func (t *task) signal() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (t *task) setStatus(state State, err error) {
+	t.mu.Lock()
+	t.status = Status{State: state, Err: err}
+	t.mu.Unlock()
+}
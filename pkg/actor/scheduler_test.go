@@ -0,0 +1,235 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingActor ticks until it reaches limit, then parks.
+type countingActor struct {
+	ticks atomic.Int64
+	limit int64
+	err   error
+}
+
+func (a *countingActor) Tick(ctx context.Context) (bool, error) {
+	n := a.ticks.Add(1)
+	if a.err != nil && n >= a.limit {
+		return false, a.err
+	}
+	return n < a.limit, nil
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func runScheduler(t *testing.T, s *Scheduler) (cancel func()) {
+	t.Helper()
+	ctx, cancelCtx := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+	return func() {
+		cancelCtx()
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Run did not return after cancel")
+		}
+	}
+}
+
+func TestSchedulerParksIdleActors(t *testing.T) {
+	s := NewScheduler(SchedulerConfig{})
+	a := &countingActor{limit: 3}
+	if err := s.Spawn("counter", a); err != nil {
+		t.Fatal(err)
+	}
+	stop := runScheduler(t, s)
+	defer stop()
+
+	waitFor(t, "actor to park", func() bool {
+		st, _ := s.Status("counter")
+		return st.State == StateParked
+	})
+	if got := a.ticks.Load(); got != 3 {
+		t.Errorf("expected 3 ticks before parking, got %d", got)
+	}
+
+	// Waking a parked actor ticks it once more.
+	if err := s.Wake("counter"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "woken tick", func() bool { return a.ticks.Load() == 4 })
+}
+
+func TestSchedulerReportsErrors(t *testing.T) {
+	boom := errors.New("boom")
+	var mu sync.Mutex
+	var failed []string
+
+	s := NewScheduler(SchedulerConfig{
+		OnError: func(id string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.Is(err, boom) {
+				failed = append(failed, id)
+			}
+		},
+	})
+	s.Spawn("bad", &countingActor{limit: 2, err: boom})
+	s.Spawn("good", &countingActor{limit: 2})
+	stop := runScheduler(t, s)
+	defer stop()
+
+	waitFor(t, "bad actor to fail", func() bool {
+		st, _ := s.Status("bad")
+		return st.State == StateFailed
+	})
+	st, _ := s.Status("bad")
+	if !errors.Is(st.Err, boom) {
+		t.Errorf("expected status error boom, got %v", st.Err)
+	}
+	waitFor(t, "good actor to park", func() bool {
+		st, _ := s.Status("good")
+		return st.State == StateParked
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) != 1 || failed[0] != "bad" {
+		t.Errorf("expected OnError for bad only, got %v", failed)
+	}
+}
+
+func TestSchedulerSpawnAndStopWhileRunning(t *testing.T) {
+	s := NewScheduler(SchedulerConfig{Workers: 1})
+	stop := runScheduler(t, s)
+	defer stop()
+
+	busy := &countingActor{limit: 1 << 62}
+	if err := s.Spawn("busy", busy); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Spawn("busy", &countingActor{}); !errors.Is(err, ErrActorExists) {
+		t.Errorf("expected ErrActorExists, got %v", err)
+	}
+	waitFor(t, "busy actor to tick", func() bool { return busy.ticks.Load() > 5 })
+
+	if err := s.Stop("busy"); err != nil {
+		t.Fatal(err)
+	}
+	after := busy.ticks.Load()
+	time.Sleep(10 * time.Millisecond)
+	if busy.ticks.Load() != after {
+		t.Error("stopped actor kept ticking")
+	}
+	if _, err := s.Status("busy"); !errors.Is(err, ErrActorNotFound) {
+		t.Errorf("expected ErrActorNotFound, got %v", err)
+	}
+}
+
+// waitingActor is a Waiter whose deadline and wake channel are controlled by the test.
+type waitingActor struct {
+	countingActor
+	deadline time.Time
+	wake     chan struct{}
+}
+
+func (a *waitingActor) NextDeadline() (time.Time, bool) { return a.deadline, !a.deadline.IsZero() }
+func (a *waitingActor) Wake() <-chan struct{}           { return a.wake }
+
+func TestSchedulerHonoursWaiter(t *testing.T) {
+	a := &waitingActor{
+		countingActor: countingActor{limit: 1 << 62},
+		deadline:      time.Now().Add(time.Hour),
+		wake:          make(chan struct{}, 1),
+	}
+	s := NewScheduler(SchedulerConfig{})
+	s.Spawn("waiter", a)
+	stop := runScheduler(t, s)
+	defer stop()
+
+	waitFor(t, "first tick", func() bool { return a.ticks.Load() == 1 })
+	time.Sleep(10 * time.Millisecond)
+	if got := a.ticks.Load(); got != 1 {
+		t.Fatalf("waiter was polled before its deadline: %d ticks", got)
+	}
+
+	a.wake <- struct{}{}
+	waitFor(t, "tick after wake", func() bool { return a.ticks.Load() == 2 })
+}
+
+func TestSchedulerVirtualClock(t *testing.T) {
+	clock := NewVirtualClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	a := &waitingActor{
+		countingActor: countingActor{limit: 1 << 62},
+		deadline:      clock.Now().Add(time.Minute),
+		wake:          make(chan struct{}, 1),
+	}
+	s := NewScheduler(SchedulerConfig{Clock: clock})
+	s.Spawn("waiter", a)
+	stop := runScheduler(t, s)
+	defer stop()
+
+	waitFor(t, "first tick", func() bool { return a.ticks.Load() == 1 })
+	clock.Advance(59 * time.Second)
+	time.Sleep(5 * time.Millisecond)
+	if got := a.ticks.Load(); got != 1 {
+		t.Fatalf("ticked before the virtual deadline: %d ticks", got)
+	}
+
+	a.deadline = time.Time{}
+	clock.Advance(time.Second)
+	waitFor(t, "tick at the virtual deadline", func() bool { return a.ticks.Load() == 2 })
+}
+
+// shutdownActor records how it was shut down.
+type shutdownActor struct {
+	countingActor
+	graceful chan bool
+}
+
+func (a *shutdownActor) Shutdown(ctx context.Context, graceful bool) error {
+	a.graceful <- graceful
+	return nil
+}
+
+func TestSchedulerServe(t *testing.T) {
+	a := &shutdownActor{countingActor: countingActor{limit: 1 << 62}, graceful: make(chan bool, 1)}
+	s := NewScheduler(SchedulerConfig{})
+	s.Spawn("busy", a)
+	s.Spawn("plain", &countingActor{limit: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(ctx, time.Second) }()
+	waitFor(t, "busy actor to tick", func() bool { return a.ticks.Load() > 5 })
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatalf("expected a clean shutdown, got %v", err)
+	}
+	if graceful := <-a.graceful; !graceful {
+		t.Error("Serve should shut actors down gracefully")
+	}
+	after := a.ticks.Load()
+	time.Sleep(5 * time.Millisecond)
+	if a.ticks.Load() != after {
+		t.Error("actor kept ticking after shutdown")
+	}
+	if err := s.Spawn("late", &countingActor{}); !errors.Is(err, ErrShutdown) {
+		t.Errorf("expected ErrShutdown, got %v", err)
+	}
+}
@@ -0,0 +1,385 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ErrRestartIntensity is returned by a Supervisor whose children failed more
+// often than its restart intensity allows.
+var ErrRestartIntensity = errors.New("restart intensity exceeded")
+
+// Strategy decides which children a Supervisor restarts when one fails.
+type Strategy int
+
+const (
+	// OneForOne restarts only the failed child.
+	OneForOne Strategy = iota
+	// OneForAll restarts every child.
+	OneForAll
+	// RestForOne restarts the failed child and the children started after it.
+	RestForOne
+)
+
+func (s Strategy) String() string {
+	switch s {
+	case OneForOne:
+		return "one-for-one"
+	case OneForAll:
+		return "one-for-all"
+	case RestForOne:
+		return "rest-for-one"
+	}
+	return fmt.Sprintf("Strategy(%d)", int(s))
+}
+
+// Defaults used when a SupervisorConfig leaves them out.
+const (
+	DefaultMaxRestarts   = 3
+	DefaultRestartPeriod = 5 * time.Second
+	DefaultMinBackoff    = 100 * time.Millisecond
+	DefaultMaxBackoff    = 30 * time.Second
+)
+
+// ChildSpec describes an actor a Supervisor keeps running.
+type ChildSpec struct {
+	// Name identifies the child within the supervisor and, when the
+	// supervisor has a Registry, is the name it is registered under.
+	Name string
+	// Start creates a fresh instance of the child. id is the child's actor
+	// ID, which stays the same across restarts. A Start error counts as a
+	// failure of the child.
+	Start func(id string) (Actor, error)
+}
+
+// SupervisorConfig configures a Supervisor.
+type SupervisorConfig struct {
+	Strategy Strategy
+	// Children are started in order, and stopped in reverse order.
+	Children []ChildSpec
+
+	// MaxRestarts is how many restarts are allowed within Period before
+	// the supervisor gives up and fails itself. Default to
+	// DefaultMaxRestarts and DefaultRestartPeriod.
+	MaxRestarts int
+	Period      time.Duration
+
+	// A child is restarted MinBackoff after its first failure within
+	// Period, and twice as late after each further one, up to MaxBackoff.
+	// Default to DefaultMinBackoff and DefaultMaxBackoff.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// Scheduler configures the scheduler the children run on. Its Clock
+	// also times restarts, and its OnError still sees every failure.
+	Scheduler SchedulerConfig
+
+	// Registry, if set, has every running child registered under its ID
+	// and Name.
+	Registry *Registry
+}
+
+// Supervisor is an actor that runs child actors and restarts them when
+// their Tick fails, following its Strategy. Children that keep failing are
+// restarted with exponential backoff; once restarts exceed the restart
+// intensity, the supervisor stops every child and its own Tick fails with
+// ErrRestartIntensity, escalating to whoever runs it. Supervisors nest: a
+// ChildSpec may start another Supervisor.
+//
+// Children start on the first Tick and run on their own goroutines until
+// the context passed to that Tick is done or Shutdown is called. Children
+// being restarted, or stopped because the supervisor failed, are shut down
+// without grace.
+type Supervisor struct {
+	config   SupervisorConfig
+	children []*child
+	sched    *Scheduler
+	wake     chan struct{}
+
+	failMu   sync.Mutex
+	failures []string // names of children reported failed by the scheduler
+
+	mu       sync.Mutex
+	cancel   context.CancelFunc // stops the scheduler; set once started
+	done     chan struct{}      // closed when the scheduler returned
+	restarts []restart          // within the last Period, oldest first
+	err      error              // why the supervisor failed
+	stopped  bool
+}
+
+// child is a Supervisor's bookkeeping for one ChildSpec.
+type child struct {
+	spec  ChildSpec
+	id    string
+	actor Actor     // nil while the child is not running
+	due   time.Time // when to start the child, if not running
+}
+
+// restart records that a child failed and was restarted.
+type restart struct {
+	at   time.Time
+	name string
+}
+
+var (
+	_ Actor      = (*Supervisor)(nil)
+	_ Waiter     = (*Supervisor)(nil)
+	_ Shutdowner = (*Supervisor)(nil)
+)
+
+// NewSupervisor creates a Supervisor. It fails if the children are misconfigured.
+func NewSupervisor(config SupervisorConfig) (*Supervisor, error) {
+	if config.MaxRestarts <= 0 {
+		config.MaxRestarts = DefaultMaxRestarts
+	}
+	if config.Period <= 0 {
+		config.Period = DefaultRestartPeriod
+	}
+	if config.MinBackoff <= 0 {
+		config.MinBackoff = DefaultMinBackoff
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = DefaultMaxBackoff
+	}
+	if config.Scheduler.Clock == nil {
+		config.Scheduler.Clock = RealClock
+	}
+
+	var errs []error
+	names := make(map[string]bool)
+	for i, spec := range config.Children {
+		switch {
+		case spec.Name == "":
+			errs = append(errs, fmt.Errorf("child %d: missing name", i))
+		case names[spec.Name]:
+			errs = append(errs, fmt.Errorf("child %s: duplicate name", spec.Name))
+		}
+		if spec.Start == nil {
+			errs = append(errs, fmt.Errorf("child %s: missing Start", spec.Name))
+		}
+		names[spec.Name] = true
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	s := &Supervisor{config: config, wake: make(chan struct{}, 1)}
+	now := config.Scheduler.Clock.Now()
+	for _, spec := range config.Children {
+		id, err := NewID(now, nil)
+		if err != nil {
+			return nil, err
+		}
+		s.children = append(s.children, &child{spec: spec, id: id, due: now})
+	}
+
+	schedConfig := config.Scheduler
+	onError := schedConfig.OnError
+	schedConfig.OnError = func(name string, err error) {
+		if onError != nil {
+			onError(name, err)
+		}
+		s.failMu.Lock()
+		s.failures = append(s.failures, name)
+		s.failMu.Unlock()
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	s.sched = NewScheduler(schedConfig)
+	return s, nil
+}
+
+// Child returns the running instance of the named child. It returns false
+// while the child waits to be restarted.
+func (s *Supervisor) Child(name string) (Actor, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.children {
+		if c.spec.Name == name && c.actor != nil {
+			return c.actor, true
+		}
+	}
+	return nil, false
+}
+
+// Tick starts the children on the first call, then handles their failures
+// and restarts those that are due. It fails once the restart intensity is
+// exceeded.
+func (s *Supervisor) Tick(ctx context.Context) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case s.err != nil:
+		return false, s.err
+	case s.stopped:
+		return false, ErrShutdown
+	}
+	if s.cancel == nil {
+		var runCtx context.Context
+		runCtx, s.cancel = context.WithCancel(ctx)
+		s.done = make(chan struct{})
+		go func() {
+			defer close(s.done)
+			s.sched.Run(runCtx)
+		}()
+	}
+
+	s.failMu.Lock()
+	failures := s.failures
+	s.failures = nil
+	s.failMu.Unlock()
+	for _, name := range failures {
+		if s.err != nil {
+			break
+		}
+		c := s.child(name)
+		// Ignore children stopped, or already restarted, since they failed.
+		st, err := s.sched.Status(name)
+		if c == nil || err != nil || st.State != StateFailed {
+			continue
+		}
+		s.restart(c, st.Err)
+	}
+
+	now := s.config.Scheduler.Clock.Now()
+	for _, c := range s.children {
+		if s.err != nil {
+			break
+		}
+		if c.actor == nil && !c.due.IsZero() && !c.due.After(now) {
+			s.start(c)
+		}
+	}
+
+	if s.err != nil {
+		s.stopChildren(context.Background(), false)
+		return false, s.err
+	}
+	return false, nil
+}
+
+// NextDeadline returns when the next child is due to be restarted.
+func (s *Supervisor) NextDeadline() (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var next time.Time
+	for _, c := range s.children {
+		if c.actor == nil && !c.due.IsZero() && (next.IsZero() || c.due.Before(next)) {
+			next = c.due
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// Wake fires whenever a child fails.
+func (s *Supervisor) Wake() <-chan struct{} {
+	return s.wake
+}
+
+// Shutdown shuts every child down, in reverse order, then stops the
+// scheduler they run on. It returns the children's errors.
+func (s *Supervisor) Shutdown(ctx context.Context, graceful bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.stopped = true
+	return s.stopChildren(ctx, graceful)
+}
+
+// start starts c, handling a failure to do so like any other.
+func (s *Supervisor) start(c *child) {
+	c.due = time.Time{}
+	a, err := c.spec.Start(c.id)
+	if err == nil && s.config.Registry != nil {
+		_, err = s.config.Registry.RegisterAs(c.id, a, c.spec.Name)
+	}
+	if err != nil {
+		s.restart(c, fmt.Errorf("starting %s: %w", c.spec.Name, err))
+		return
+	}
+	c.actor = a
+	// Names are unique and stopped children are removed, so this can't fail.
+	s.sched.Spawn(c.spec.Name, a)
+}
+
+// restart stops the children the strategy says failed along with c and
+// schedules them to start again after a backoff, or fails the supervisor
+// if restarts exceed the restart intensity.
+func (s *Supervisor) restart(c *child, cause error) {
+	now := s.config.Scheduler.Clock.Now()
+	s.restarts = slices.DeleteFunc(s.restarts, func(r restart) bool {
+		return now.Sub(r.at) >= s.config.Period
+	})
+	if len(s.restarts) >= s.config.MaxRestarts {
+		s.err = fmt.Errorf("%w: %s failed: %w", ErrRestartIntensity, c.spec.Name, cause)
+		return
+	}
+	s.restarts = append(s.restarts, restart{at: now, name: c.spec.Name})
+
+	backoff := s.config.MinBackoff
+	for _, r := range s.restarts[:len(s.restarts)-1] {
+		if r.name == c.spec.Name && backoff < s.config.MaxBackoff {
+			backoff *= 2
+		}
+	}
+	backoff = min(backoff, s.config.MaxBackoff)
+
+	affected := []*child{c}
+	switch i := slices.Index(s.children, c); s.config.Strategy {
+	case OneForAll:
+		affected = s.children
+	case RestForOne:
+		affected = s.children[i:]
+	}
+	for _, other := range slices.Backward(affected) {
+		s.stopChild(context.Background(), other, false)
+		other.due = now.Add(backoff)
+	}
+}
+
+// stopChildren stops every child and the scheduler they run on.
+func (s *Supervisor) stopChildren(ctx context.Context, graceful bool) error {
+	var errs []error
+	for _, c := range slices.Backward(s.children) {
+		if err := s.stopChild(ctx, c, graceful); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.spec.Name, err))
+		}
+		c.due = time.Time{}
+	}
+	if s.cancel != nil {
+		s.cancel()
+		<-s.done
+	}
+	return errors.Join(errs...)
+}
+
+// stopChild stops ticking c and shuts it down, if it is running.
+func (s *Supervisor) stopChild(ctx context.Context, c *child, graceful bool) error {
+	if c.actor == nil {
+		return nil
+	}
+	s.sched.Stop(c.spec.Name)
+	if s.config.Registry != nil {
+		s.config.Registry.Unregister(c.id)
+	}
+	a := c.actor
+	c.actor = nil
+	if sd, ok := a.(Shutdowner); ok {
+		return sd.Shutdown(ctx, graceful)
+	}
+	return nil
+}
+
+func (s *Supervisor) child(name string) *child {
+	for _, c := range s.children {
+		if c.spec.Name == name {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package actor
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSupervisorStrategies(t *testing.T) {
+	boom := errors.New("boom")
+	for strategy, want := range map[Strategy]map[string]int{
+		OneForOne:  {"a": 1, "b": 2, "c": 1},
+		OneForAll:  {"a": 2, "b": 2, "c": 2},
+		RestForOne: {"a": 1, "b": 2, "c": 2},
+	} {
+		t.Run(strategy.String(), func(t *testing.T) {
+			var mu sync.Mutex
+			starts := map[string]int{}
+			ids := map[string]string{}
+			spec := func(name string) ChildSpec {
+				return ChildSpec{Name: name, Start: func(id string) (Actor, error) {
+					mu.Lock()
+					defer mu.Unlock()
+					starts[name]++
+					if ids[name] != "" && ids[name] != id {
+						t.Errorf("%s restarted with id %s, was %s", name, id, ids[name])
+					}
+					ids[name] = id
+					if name == "b" && starts[name] == 1 {
+						return &countingActor{limit: 1, err: boom}, nil
+					}
+					return &countingActor{limit: 1}, nil
+				}}
+			}
+			registry := NewRegistry(RegistryConfig{})
+			sup, err := NewSupervisor(SupervisorConfig{
+				Strategy:   strategy,
+				Children:   []ChildSpec{spec("a"), spec("b"), spec("c")},
+				MinBackoff: time.Millisecond,
+				Registry:   registry,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s := NewScheduler(SchedulerConfig{})
+			s.Spawn("supervisor", sup)
+			stop := runScheduler(t, s)
+			defer stop()
+			defer sup.Shutdown(context.Background(), false)
+
+			settled := func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				return maps.Equal(starts, want)
+			}
+			waitFor(t, "restarts", settled)
+			time.Sleep(10 * time.Millisecond)
+			if !settled() {
+				t.Errorf("started %v, want %v", starts, want)
+			}
+			waitFor(t, "b to be registered again", func() bool {
+				mu.Lock()
+				defer mu.Unlock()
+				e, ok := registry.Lookup("b")
+				a, running := sup.Child("b")
+				return ok && running && e.ID == ids["b"] && e.Actor == a
+			})
+		})
+	}
+}
+
+func TestSupervisorBackoffAndEscalation(t *testing.T) {
+	boom := errors.New("boom")
+	clock := NewVirtualClock(time.Unix(0, 0))
+	sup, err := NewSupervisor(SupervisorConfig{
+		Children: []ChildSpec{{Name: "crasher", Start: func(string) (Actor, error) {
+			return &countingActor{limit: 1, err: boom}, nil
+		}}},
+		MaxRestarts: 2,
+		Period:      time.Minute,
+		MinBackoff:  time.Second,
+		Scheduler:   SchedulerConfig{Clock: clock},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sup.Shutdown(context.Background(), false)
+	ctx := context.Background()
+
+	for _, backoff := range []time.Duration{time.Second, 2 * time.Second} {
+		if _, err := sup.Tick(ctx); err != nil {
+			t.Fatal(err)
+		}
+		<-sup.Wake()
+		if _, err := sup.Tick(ctx); err != nil {
+			t.Fatal(err)
+		}
+		deadline, ok := sup.NextDeadline()
+		if !ok || deadline.Sub(clock.Now()) != backoff {
+			t.Fatalf("expected a restart in %v, got %v", backoff, deadline.Sub(clock.Now()))
+		}
+		if _, running := sup.Child("crasher"); running {
+			t.Fatal("the child should wait out its backoff")
+		}
+		clock.Set(deadline)
+	}
+
+	if _, err := sup.Tick(ctx); err != nil {
+		t.Fatal(err)
+	}
+	<-sup.Wake()
+	_, err = sup.Tick(ctx)
+	if !errors.Is(err, ErrRestartIntensity) || !errors.Is(err, boom) {
+		t.Fatalf("expected the supervisor to escalate, got %v", err)
+	}
+	if _, running := sup.Child("crasher"); running {
+		t.Error("children should be stopped when the supervisor fails")
+	}
+
+	if _, err := NewSupervisor(SupervisorConfig{Children: []ChildSpec{{Name: "a"}, {Name: "a"}}}); err == nil {
+		t.Error("expected misconfigured children to be rejected")
+	}
+}
@@ -0,0 +1,117 @@
+package actor
+
+import (
+	"container/heap"
+	"time"
+)
+
+// Timer is a pending setTimeout/setInterval-style timer.
+type Timer struct {
+	ID       int64
+	Deadline time.Time
+	Interval time.Duration // 0 if one-shot
+	// Data is whatever the runtime needs to fire the timer, e.g. a callback.
+	Data any
+
+	index int // heap index, -1 when not queued
+}
+
+// TimerQueue holds an actor's timers ordered by deadline. Runtimes use it to
+// give timers the same semantics: a due timer is popped, fired, then either
+// rescheduled (intervals) or forgotten, and clearing a timer from inside its
+// own callback stops it from repeating. It is not safe for concurrent use.
+type TimerQueue struct {
+	timers map[int64]*Timer
+	queue  timerHeap
+	nextID int64
+}
+
+// NewTimerQueue returns an empty queue. IDs start at 1.
+func NewTimerQueue() *TimerQueue {
+	return &TimerQueue{timers: make(map[int64]*Timer), nextID: 1}
+}
+
+// Add schedules a timer at deadline, repeating every interval if it is positive.
+func (q *TimerQueue) Add(deadline time.Time, interval time.Duration, data any) *Timer {
+	t := &Timer{ID: q.nextID, Deadline: deadline, Interval: interval, Data: data}
+	q.nextID++
+	q.timers[t.ID] = t
+	heap.Push(&q.queue, t)
+	return t
+}
+
+// Clear cancels the timer with the given ID. It reports whether the timer existed.
+func (q *TimerQueue) Clear(id int64) bool {
+	t, ok := q.timers[id]
+	if !ok {
+		return false
+	}
+	if t.index >= 0 {
+		heap.Remove(&q.queue, t.index)
+	}
+	delete(q.timers, id)
+	return true
+}
+
+// Len returns how many timers exist, including one being fired.
+func (q *TimerQueue) Len() int {
+	return len(q.timers)
+}
+
+// Next returns the earliest deadline of the queued timers.
+func (q *TimerQueue) Next() (time.Time, bool) {
+	if len(q.queue) == 0 {
+		return time.Time{}, false
+	}
+	return q.queue[0].Deadline, true
+}
+
+// Pop removes and returns the earliest timer due at now. The timer still
+// counts as existing until Done is called, so it may clear itself.
+func (q *TimerQueue) Pop(now time.Time) (*Timer, bool) {
+	if len(q.queue) == 0 || q.queue[0].Deadline.After(now) {
+		return nil, false
+	}
+	return heap.Pop(&q.queue).(*Timer), true
+}
+
+// Done finishes firing t: an interval that was not cleared is rescheduled
+// one interval after now, anything else is forgotten.
+func (q *TimerQueue) Done(t *Timer, now time.Time) {
+	if _, exists := q.timers[t.ID]; !exists {
+		return
+	}
+	if t.Interval > 0 {
+		t.Deadline = now.Add(t.Interval)
+		heap.Push(&q.queue, t)
+		return
+	}
+	delete(q.timers, t.ID)
+}
+
+// timerHeap implements heap.Interface for timers.
+type timerHeap []*Timer
+
+func (h timerHeap) Len() int           { return len(h) }
+func (h timerHeap) Less(i, j int) bool { return h[i].Deadline.Before(h[j].Deadline) }
+func (h timerHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *timerHeap) Push(x any) {
+	item := x.(*Timer)
+	item.index = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *timerHeap) Pop() any {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil  // avoid memory leak
+	item.index = -1 // for safety
+	*h = old[0 : n-1]
+	return item
+}
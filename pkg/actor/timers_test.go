@@ -0,0 +1,70 @@
+package actor
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimerQueue(t *testing.T) {
+	start := time.Unix(0, 0)
+	q := NewTimerQueue()
+
+	late := q.Add(start.Add(20*time.Millisecond), 0, "late")
+	early := q.Add(start.Add(10*time.Millisecond), 0, "early")
+	if late.ID != 1 || early.ID != 2 {
+		t.Errorf("expected IDs 1 and 2, got %d and %d", late.ID, early.ID)
+	}
+	if next, ok := q.Next(); !ok || !next.Equal(early.Deadline) {
+		t.Errorf("expected the earliest deadline first, got %v, %v", next, ok)
+	}
+
+	if _, ok := q.Pop(start.Add(5 * time.Millisecond)); ok {
+		t.Error("no timer should be due yet")
+	}
+	now := start.Add(30 * time.Millisecond)
+	var fired []any
+	for {
+		timer, ok := q.Pop(now)
+		if !ok {
+			break
+		}
+		fired = append(fired, timer.Data)
+		q.Done(timer, now)
+	}
+	if len(fired) != 2 || fired[0] != "early" || fired[1] != "late" {
+		t.Errorf("timers fired in the wrong order: %v", fired)
+	}
+	if q.Len() != 0 {
+		t.Errorf("one-shot timers should be forgotten, %d left", q.Len())
+	}
+}
+
+func TestTimerQueueIntervals(t *testing.T) {
+	now := time.Unix(0, 0)
+	q := NewTimerQueue()
+	interval := q.Add(now.Add(10*time.Millisecond), 10*time.Millisecond, nil)
+
+	now = now.Add(15 * time.Millisecond)
+	timer, ok := q.Pop(now)
+	if !ok || timer != interval {
+		t.Fatal("expected the interval to be due")
+	}
+	q.Done(timer, now)
+	if next, _ := q.Next(); !next.Equal(now.Add(10 * time.Millisecond)) {
+		t.Errorf("interval should be rescheduled one interval after it fired, got %v", next)
+	}
+
+	// An interval cleared by its own callback stops repeating.
+	now = now.Add(10 * time.Millisecond)
+	timer, _ = q.Pop(now)
+	if !q.Clear(timer.ID) {
+		t.Error("a firing timer should still be clearable")
+	}
+	q.Done(timer, now)
+	if _, ok := q.Next(); ok || q.Len() != 0 {
+		t.Error("a cleared interval was rescheduled")
+	}
+	if q.Clear(timer.ID) {
+		t.Error("clearing twice should report false")
+	}
+}
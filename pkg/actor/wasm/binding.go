@@ -0,0 +1,68 @@
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"orvalho/pkg/actor/binding"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// instantiateBindings defines a host module per binding, following the ABI
+// described in package binding.
+func (r *Runtime) instantiateBindings(ctx context.Context) error {
+	for _, b := range r.bindings {
+		builder := r.engine.NewHostModuleBuilder(b.WASMModule())
+		for _, m := range b.Methods {
+			builder.NewFunctionBuilder().
+				WithFunc(func(ctx context.Context, mod api.Module, ptr, size, out uint32) uint32 {
+					return r.callBinding(ctx, mod, m, ptr, size, out)
+				}).
+				Export(m.Name)
+		}
+		if _, err := builder.Instantiate(ctx); err != nil {
+			return fmt.Errorf("binding %s: %w", b.Name, err)
+		}
+	}
+	return nil
+}
+
+func (r *Runtime) callBinding(ctx context.Context, mod api.Module, m binding.Method, ptr, size, out uint32) uint32 {
+	data, ok := mod.Memory().Read(ptr, size)
+	if !ok {
+		panic(fmt.Errorf("%s: %d bytes at %d are outside memory", m.Name, size, ptr))
+	}
+	args, err := binding.DecodeArgs(data)
+	if err != nil {
+		return r.reply(ctx, mod, out, binding.StatusError, []byte(err.Error()))
+	}
+	result, err := m.Call(ctx, args)
+	if err != nil {
+		return r.reply(ctx, mod, out, binding.StatusError, []byte(err.Error()))
+	}
+	if data, err = json.Marshal(result); err != nil {
+		return r.reply(ctx, mod, out, binding.StatusError, []byte(err.Error()))
+	}
+	return r.reply(ctx, mod, out, binding.StatusOK, data)
+}
+
+// reply copies data into a buffer from the guest's alloc and stores its
+// pointer and length at out.
+func (r *Runtime) reply(ctx context.Context, mod api.Module, out, status uint32, data []byte) uint32 {
+	alloc := mod.ExportedFunction("alloc")
+	if alloc == nil {
+		panic(fmt.Errorf("%s: bindings need an alloc export", r.name))
+	}
+	results, err := alloc.Call(ctx, api.EncodeU32(uint32(len(data))))
+	if err != nil {
+		panic(err)
+	}
+	ptr := api.DecodeU32(results[0])
+	mem := mod.Memory()
+	if !mem.Write(ptr, data) || !mem.WriteUint32Le(out, ptr) || !mem.WriteUint32Le(out+4, uint32(len(data))) {
+		panic(fmt.Errorf("%s: binding reply is outside memory", r.name))
+	}
+	return status
+}
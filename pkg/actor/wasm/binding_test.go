@@ -0,0 +1,102 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"orvalho/pkg/actor/binding"
+	"orvalho/pkg/actor/internal/wasmtest"
+)
+
+func TestWithBinding(t *testing.T) {
+	stored := map[string][]byte{}
+	kv := binding.Binding{
+		Name: "kv",
+		Methods: []binding.Method{
+			{
+				Name: "put",
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					var key string
+					var value []byte
+					if err := args.Decode(0, &key); err != nil {
+						return nil, err
+					}
+					if err := args.Decode(1, &value); err != nil {
+						return nil, err
+					}
+					if key == "" {
+						return nil, errors.New("empty key")
+					}
+					stored[key] = value
+					return nil, nil
+				},
+			},
+			{
+				Name:  "get",
+				Async: true, // WASM calls it synchronously all the same
+				Call: func(ctx context.Context, args binding.Args) (any, error) {
+					var key string
+					if err := args.Decode(0, &key); err != nil {
+						return nil, err
+					}
+					return stored[key], nil
+				},
+			},
+		},
+	}
+
+	const (
+		putArgs  = `["greeting","aGVsbG8="]`
+		badArgs  = `["","AA=="]`
+		getArgs  = `["greeting"]`
+		putOut   = 200 // ptr, len
+		badOut   = 208
+		getOut   = 216
+		statuses = 224 // put, bad put, get
+	)
+	m := &wasmtest.Module{}
+	sig := []byte{wasmtest.I32, wasmtest.I32, wasmtest.I32}
+	put := m.Import("orvalho:kv", "put", sig, i32)
+	get := m.Import("orvalho:kv", "get", sig, i32)
+	m.Memory(1)
+	m.Data(0, []byte(putArgs))
+	m.Data(40, []byte(badArgs))
+	m.Data(80, []byte(getArgs))
+	exportAlloc(m)
+	c := wasmtest.I32Const
+	m.Export("_initialize", m.Func(nil, nil, nil,
+		c(statuses), c(0), c(int32(len(putArgs))), c(putOut), wasmtest.Call(put), wasmtest.I32Store(),
+		c(statuses+4), c(40), c(int32(len(badArgs))), c(badOut), wasmtest.Call(put), wasmtest.I32Store(),
+		c(statuses+8), c(80), c(int32(len(getArgs))), c(getOut), wasmtest.Call(get), wasmtest.I32Store(),
+	))
+
+	r := newRuntime(t, m.Bytes(), WithBinding(kv))
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if string(stored["greeting"]) != "hello" {
+		t.Errorf("put did not store the bytes, got %q", stored["greeting"])
+	}
+
+	mem := r.module.Memory()
+	reply := func(out uint32) string {
+		ptr, _ := mem.ReadUint32Le(out)
+		size, _ := mem.ReadUint32Le(out + 4)
+		data, _ := mem.Read(ptr, size)
+		return string(data)
+	}
+	status := func(i uint32) uint32 {
+		s, _ := mem.ReadUint32Le(statuses + 4*i)
+		return s
+	}
+	if status(0) != binding.StatusOK || reply(putOut) != "null" {
+		t.Errorf("unexpected put reply %d %q", status(0), reply(putOut))
+	}
+	if status(1) != binding.StatusError || reply(badOut) != "empty key" {
+		t.Errorf("unexpected error reply %d %q", status(1), reply(badOut))
+	}
+	if status(2) != binding.StatusOK || reply(getOut) != `"aGVsbG8="` {
+		t.Errorf("unexpected get reply %d %q", status(2), reply(getOut))
+	}
+}
@@ -0,0 +1,34 @@
+package wasm
+
+import (
+	"fmt"
+	"time"
+)
+
+// BudgetExceededError is why a module was terminated when it outran its time
+// budget. Tick returns it wrapped with ErrTerminated; use errors.As to detect it.
+type BudgetExceededError struct {
+	// Scope is "tick" or "handler".
+	Scope string
+	Limit time.Duration
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("%s budget of %v exceeded", e.Scope, e.Limit)
+}
+
+// Stats counts how the runtime has been behaving.
+type Stats struct {
+	Ticks           uint64
+	TickOverruns    uint64
+	HandlerOverruns uint64
+}
+
+// Stats returns the runtime's counters. It is safe to call at any time.
+func (r *Runtime) Stats() Stats {
+	return Stats{
+		Ticks:           r.ticks.Load(),
+		TickOverruns:    r.tickOverruns.Load(),
+		HandlerOverruns: r.handlerOverruns.Load(),
+	}
+}
@@ -0,0 +1,72 @@
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+// initLogger tees the module's logs into the log buffer, like console output
+// from a JS actor.
+func (r *Runtime) initLogger() {
+	base := r.logger
+	if base == nil {
+		base = slog.Default()
+	}
+	r.logger = slog.New(r.logs.Handler(base.Handler()))
+	if r.id != "" {
+		r.logger = r.logger.With("actor", r.id)
+	}
+	if r.version != "" {
+		r.logger = r.logger.With("version", r.version)
+	}
+}
+
+// instantiateHost defines the host functions guests import from HostModule.
+func (r *Runtime) instantiateHost(ctx context.Context) error {
+	_, err := r.engine.NewHostModuleBuilder(HostModule).
+		NewFunctionBuilder().WithFunc(r.hostLog).Export("log").
+		NewFunctionBuilder().WithFunc(r.hostSetTimer).Export("set_timer").
+		NewFunctionBuilder().WithFunc(r.hostClearTimer).Export("clear_timer").
+		NewFunctionBuilder().WithFunc(r.hostNow).Export("now").
+		Instantiate(ctx)
+	return err
+}
+
+func (r *Runtime) hostLog(ctx context.Context, m api.Module, level int32, ptr, size uint32) {
+	msg, ok := m.Memory().Read(ptr, size)
+	if !ok {
+		panic(fmt.Errorf("log: %d bytes at %d are outside memory", size, ptr))
+	}
+	r.logger.Log(ctx, slog.Level(level), string(msg))
+}
+
+func (r *Runtime) hostSetTimer(delayMs int64, repeat int32) int64 {
+	if r.caps != nil && r.caps.Timers == nil {
+		return -1
+	}
+	if r.caps != nil && r.caps.Timers.Max > 0 && r.timers.Len() >= r.caps.Timers.Max {
+		return -1
+	}
+	if delayMs < 0 {
+		delayMs = 0
+	}
+	delay := time.Duration(delayMs) * time.Millisecond
+
+	var interval time.Duration
+	if repeat != 0 {
+		interval = delay
+	}
+	return r.timers.Add(r.clock.Now().Add(delay), interval, nil).ID
+}
+
+func (r *Runtime) hostClearTimer(id int64) {
+	r.timers.Clear(id)
+}
+
+func (r *Runtime) hostNow() int64 {
+	return r.clock.Now().UnixMilli()
+}
@@ -0,0 +1,163 @@
+package wasm
+
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/binding"
+	"orvalho/pkg/actor/manifest"
+)
+
+// Option configures a Runtime.
+type Option func(*Runtime)
+
+// WithName sets the module name used in errors. Defaults to "module.wasm".
+func WithName(name string) Option {
+	return func(r *Runtime) {
+		r.name = name
+	}
+}
+
+// WithMailboxSize sets how many undelivered messages the actor's mailbox holds.
+func WithMailboxSize(size int) Option {
+	return func(r *Runtime) {
+		r.mailbox = actor.NewMailbox(size)
+	}
+}
+
+// WithID sets the actor ID attached to the runtime's logs.
+func WithID(id string) Option {
+	return func(r *Runtime) {
+		r.id = id
+	}
+}
+
+// WithVersion sets the bundle version attached to the runtime's logs.
+func WithVersion(version string) Option {
+	return func(r *Runtime) {
+		r.version = version
+	}
+}
+
+// WithLogger sets where the module's log output goes. Defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(r *Runtime) {
+		r.logger = logger
+	}
+}
+
+// WithLogBuffer sets the ring buffer keeping the actor's recent logs.
+func WithLogBuffer(logs *actor.LogBuffer) Option {
+	return func(r *Runtime) {
+		r.logs = logs
+	}
+}
+
+// WithClock sets the clock timers and now() run on. Defaults to actor.RealClock.
+func WithClock(clock actor.Clock) Option {
+	return func(r *Runtime) {
+		r.clock = clock
+	}
+}
+
+// WithMemoryLimit caps the module's linear memory at limit bytes, rounded
+// down to whole 64KiB pages. memory.grow past it fails, and a module
+// declaring more initial memory fails to instantiate.
+func WithMemoryLimit(limit int64) Option {
+	return func(r *Runtime) {
+		r.memoryLimit = limit
+	}
+}
+
+// WithTickBudget bounds how long a single Tick may run the module for.
+// A Tick that overruns it terminates the module.
+func WithTickBudget(limit time.Duration) Option {
+	return func(r *Runtime) {
+		r.tickBudget = limit
+	}
+}
+
+// WithHandlerBudget bounds how long a single call into the module
+// (_initialize, a timer or a message) may run for.
+func WithHandlerBudget(limit time.Duration) Option {
+	return func(r *Runtime) {
+		r.handlerBudget = limit
+	}
+}
+
+// WithCapabilities restricts the runtime to what caps grants: set_timer
+// fails unless timers are granted and non-zero limits set the memory limit,
+// mailbox size and budgets. Options after it can still override them.
+func WithCapabilities(caps manifest.CapabilitySet) Option {
+	return func(r *Runtime) {
+		caps = caps.Normalize()
+		r.caps = &caps
+
+		if caps.Limits.Memory > 0 {
+			r.memoryLimit = caps.Limits.Memory
+		}
+		if caps.Limits.MailboxSize > 0 {
+			r.mailbox = actor.NewMailbox(caps.Limits.MailboxSize)
+		}
+		if caps.Limits.TickBudget > 0 {
+			r.tickBudget = time.Duration(caps.Limits.TickBudget)
+		}
+		if caps.Limits.HandlerBudget > 0 {
+			r.handlerBudget = time.Duration(caps.Limits.HandlerBudget)
+		}
+	}
+}
+
+// WithActor configures the runtime for an actor from a manifest: its entry
+// names the module, its args and env are passed through WASI, and its
+// capabilities are applied as by WithCapabilities.
+func WithActor(a manifest.Actor) Option {
+	return func(r *Runtime) {
+		r.name = a.Entry
+		r.args = a.Args
+		r.env = a.Env
+		WithCapabilities(a.Capabilities)(r)
+	}
+}
+
+// WithArgs sets the arguments the module sees after its program name.
+func WithArgs(args ...string) Option {
+	return func(r *Runtime) {
+		r.args = args
+	}
+}
+
+// WithEnv sets the module's environment variables.
+func WithEnv(env map[string]string) Option {
+	return func(r *Runtime) {
+		r.env = env
+	}
+}
+
+// WithStorageDir mounts dir as the module's root directory, creating it if
+// needed. Under WithCapabilities it is only mounted when storage is granted;
+// without it the module has no filesystem. The storage quota does not apply
+// to the mount.
+func WithStorageDir(dir string) Option {
+	return func(r *Runtime) {
+		r.storageDir = dir
+	}
+}
+
+// WithRandSource sets where random_get reads from. Defaults to crypto/rand.
+func WithRandSource(random io.Reader) Option {
+	return func(r *Runtime) {
+		r.random = random
+	}
+}
+
+// WithBinding lets the module import a host binding's methods from
+// "orvalho:<name>", as described in package binding. The host decides which
+// bindings an actor gets; they are not gated by WithCapabilities.
+func WithBinding(b binding.Binding) Option {
+	return func(r *Runtime) {
+		r.bindings = append(r.bindings, b)
+	}
+}
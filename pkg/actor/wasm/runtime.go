@@ -0,0 +1,524 @@
+// Package wasm runs actors compiled to WebAssembly on wazero, a pure-Go engine.
+//
+// A guest module talks to the runtime through exports it provides and host
+// functions it imports from the "orvalho" module:
+//
+//	memory                          exported linear memory
+//	alloc(len i32) -> i32           returns a buffer of len bytes for the host to fill
+//	_initialize()                   optional, run once before any event
+//	on_message(ptr i32, len i32)    optional, called with each mailbox message
+//	on_timer(id i64)                optional, called when a timer fires
+//	on_shutdown()                   optional, called on a graceful shutdown
+//
+//	orvalho.log(level i32, ptr i32, len i32)       level is a slog.Level
+//	orvalho.set_timer(delay_ms i64, repeat i32) -> i64
+//	orvalho.clear_timer(id i64)
+//	orvalho.now() -> i64                           Unix milliseconds on the runtime clock
+//
+// set_timer returns -1 when timers are not granted or too many are pending.
+// Messages are passed as raw bytes for []byte and string values and as JSON
+// otherwise; alloc and memory are only required when on_message is exported.
+//
+// Guests built for WASI preview1 also get wasi_snapshot_preview1. A command
+// module without _initialize has its _start run instead; if it exits with
+// status 0 the actor is finished, any other status terminates it.
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/binding"
+	"orvalho/pkg/actor/manifest"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// HostModule is the name guests import host functions from.
+const HostModule = "orvalho"
+
+// ErrTerminated is returned by every Tick after the module was stopped in the
+// middle of a call, by a budget overrun or a cancelled context. The module's
+// state is lost at that point; restart the actor to continue.
+var ErrTerminated = errors.New("wasm module was terminated")
+
+// ErrExited is returned by Deliver once the module exited with status 0.
+var ErrExited = errors.New("wasm module exited")
+
+// Runtime implements the Actor interface for a WebAssembly module.
+type Runtime struct {
+	engine   wazero.Runtime
+	compiled wazero.CompiledModule
+	module   api.Module
+	name     string
+	failed   error       // set once the module was terminated
+	exited   atomic.Bool // set once the module exited with status 0
+	shutdown atomic.Bool // set once Shutdown was called
+
+	// WASI
+	args       []string
+	env        map[string]string
+	storageDir string
+	random     io.Reader
+	stdout     *lineWriter
+	stderr     *lineWriter
+
+	// Host bindings, usually generated from contracts.
+	bindings []binding.Binding
+
+	// Identity and logging
+	id      string
+	version string
+	logger  *slog.Logger
+	logs    *actor.LogBuffer
+
+	// Timer management
+	clock        actor.Clock
+	epoch        time.Time // when the runtime was created, on clock
+	lastNanotime int64     // last value nanotime returned
+	timers       *actor.TimerQueue
+
+	mailbox *actor.Mailbox
+	wake    chan struct{}
+
+	// lifetime ends when the runtime shuts down, cancelling the call still
+	// running.
+	lifetime context.Context
+	end      context.CancelCauseFunc
+
+	// Capabilities granted at install time; nil means unrestricted.
+	caps        *manifest.CapabilitySet
+	memoryLimit int64 // bytes, 0 for the engine default
+
+	// Runaway-module protection
+	tickBudget      time.Duration
+	handlerBudget   time.Duration
+	ticks           atomic.Uint64
+	tickOverruns    atomic.Uint64
+	handlerOverruns atomic.Uint64
+
+	mutex sync.Mutex
+}
+
+// Ensure Runtime implements Actor, Waiter, Receiver and Shutdowner interfaces.
+var (
+	_ actor.Actor      = (*Runtime)(nil)
+	_ actor.Waiter     = (*Runtime)(nil)
+	_ actor.Receiver   = (*Runtime)(nil)
+	_ actor.Shutdowner = (*Runtime)(nil)
+)
+
+// New compiles a WebAssembly module into a runtime. The module is
+// instantiated and initialized by the first Tick.
+func New(code []byte, opts ...Option) (*Runtime, error) {
+	r := &Runtime{
+		name:    "module.wasm",
+		timers:  actor.NewTimerQueue(),
+		mailbox: actor.NewMailbox(actor.DefaultMailboxSize),
+		wake:    make(chan struct{}, 1),
+		logs:    actor.NewLogBuffer(actor.DefaultLogBufferSize),
+		clock:   actor.RealClock,
+		random:  rand.Reader,
+	}
+	r.lifetime, r.end = context.WithCancelCause(context.Background())
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.epoch = r.clock.Now()
+	r.initLogger()
+	r.stdout = &lineWriter{runtime: r, level: slog.LevelInfo, stream: "stdout"}
+	r.stderr = &lineWriter{runtime: r, level: slog.LevelError, stream: "stderr"}
+
+	ctx := context.Background()
+	config := wazero.NewRuntimeConfig().WithCloseOnContextDone(true)
+	if r.memoryLimit > 0 {
+		config = config.WithMemoryLimitPages(memoryPages(r.memoryLimit))
+	}
+	r.engine = wazero.NewRuntimeWithConfig(ctx, config)
+
+	if err := r.instantiateHost(ctx); err != nil {
+		r.engine.Close(ctx)
+		return nil, err
+	}
+	if err := r.instantiateWASI(ctx); err != nil {
+		r.engine.Close(ctx)
+		return nil, err
+	}
+	if err := r.instantiateBindings(ctx); err != nil {
+		r.engine.Close(ctx)
+		return nil, err
+	}
+	compiled, err := r.engine.CompileModule(ctx, code)
+	if err != nil {
+		r.engine.Close(ctx)
+		return nil, fmt.Errorf("compiling %s: %w", r.name, err)
+	}
+	r.compiled = compiled
+	if err := r.checkExports(); err != nil {
+		r.engine.Close(ctx)
+		return nil, err
+	}
+	return r, nil
+}
+
+// memoryPages converts a limit in bytes to 64KiB pages, rounding down but
+// keeping at least one page.
+func memoryPages(limit int64) uint32 {
+	pages := limit / 65536
+	switch {
+	case pages < 1:
+		return 1
+	case pages > 65536:
+		return 65536
+	}
+	return uint32(pages)
+}
+
+// checkExports rejects modules whose exports don't match the ABI.
+func (r *Runtime) checkExports() error {
+	funcs := r.compiled.ExportedFunctions()
+	want := map[string][]api.ValueType{
+		"alloc":       {api.ValueTypeI32},
+		"_initialize": nil,
+		"_start":      nil,
+		"on_message":  {api.ValueTypeI32, api.ValueTypeI32},
+		"on_timer":    {api.ValueTypeI64},
+		"on_shutdown": nil,
+	}
+	for name, params := range want {
+		def, ok := funcs[name]
+		if ok && !sameTypes(def.ParamTypes(), params) {
+			return fmt.Errorf("%s: export %s has parameters %v, want %v", r.name, name, def.ParamTypes(), params)
+		}
+	}
+	if _, ok := funcs["on_message"]; ok {
+		alloc, ok := funcs["alloc"]
+		if !ok || !sameTypes(alloc.ResultTypes(), []api.ValueType{api.ValueTypeI32}) {
+			return fmt.Errorf("%s: on_message needs an alloc(i32) -> i32 export", r.name)
+		}
+		if _, ok := r.compiled.ExportedMemories()["memory"]; !ok {
+			return fmt.Errorf("%s: on_message needs an exported memory", r.name)
+		}
+	}
+	return nil
+}
+
+func sameTypes(a, b []api.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Logs returns the buffer holding the actor's most recent log entries.
+func (r *Runtime) Logs() *actor.LogBuffer {
+	return r.logs
+}
+
+// Close releases the engine and everything compiled on it.
+func (r *Runtime) Close(ctx context.Context) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.engine.Close(ctx)
+}
+
+// Shutdown stops the module for good; Deliver and Tick fail with
+// actor.ErrShutdown from then on. A graceful shutdown first lets a running
+// call return, then delivers the messages already queued and calls the
+// on_shutdown export, within ctx. Otherwise, or once ctx is done, the
+// running call is cancelled. Pending timers are dropped, and the engine and
+// bindings are released.
+func (r *Runtime) Shutdown(ctx context.Context, graceful bool) error {
+	if r.shutdown.Swap(true) {
+		return nil
+	}
+	// Stop whatever is still running before waiting for it.
+	if graceful {
+		stop := context.AfterFunc(ctx, func() { r.end(actor.ErrShutdown) })
+		defer stop()
+	} else {
+		r.end(actor.ErrShutdown)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	defer r.end(actor.ErrShutdown)
+
+	var errs []error
+	if graceful && r.module != nil && r.failed == nil && !r.exited.Load() {
+		errs = append(errs, r.finish(ctx))
+	}
+	r.timers = actor.NewTimerQueue()
+	for {
+		if _, ok := r.mailbox.Take(); !ok {
+			break
+		}
+	}
+	errs = append(errs, r.engine.Close(context.WithoutCancel(ctx)))
+	for _, b := range r.bindings {
+		if b.Close != nil {
+			if err := b.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("closing %s: %w", b.WASMModule(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// finish delivers the queued messages and calls on_shutdown.
+func (r *Runtime) finish(ctx context.Context) error {
+	for {
+		msg, ok := r.mailbox.Take()
+		if !ok {
+			break
+		}
+		if err := r.dispatchMessage(ctx, msg.([]byte)); err != nil {
+			return err
+		}
+	}
+	if fn := r.module.ExportedFunction("on_shutdown"); fn != nil {
+		return r.call(ctx, fn)
+	}
+	return nil
+}
+
+// Tick executes one step of the actor's logic.
+func (r *Runtime) Tick(ctx context.Context) (bool, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.shutdown.Load() {
+		return false, actor.ErrShutdown
+	}
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	if r.failed != nil {
+		return false, r.failed
+	}
+	if r.exited.Load() {
+		return false, nil
+	}
+
+	// Calls end with the Tick or with the runtime.
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	stop := context.AfterFunc(r.lifetime, func() { cancel(context.Cause(r.lifetime)) })
+	defer stop()
+
+	r.ticks.Add(1)
+	if r.tickBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, r.tickBudget, &BudgetExceededError{Scope: "tick", Limit: r.tickBudget})
+		defer cancel()
+	}
+	return r.tick(ctx)
+}
+
+func (r *Runtime) tick(ctx context.Context) (bool, error) {
+	// Lazy initialization
+	if r.module == nil {
+		if err := r.instantiate(ctx); err != nil {
+			return false, err
+		}
+		return r.hasWork(), nil
+	}
+
+	// Process timers
+	now := r.clock.Now()
+	for {
+		t, ok := r.timers.Pop(now)
+		if !ok {
+			break
+		}
+		if fn := r.module.ExportedFunction("on_timer"); fn != nil {
+			if err := r.call(ctx, fn, api.EncodeI64(t.ID)); err != nil {
+				return false, err
+			}
+		}
+		// Reschedule intervals, unless cleared by the callback.
+		r.timers.Done(t, now)
+	}
+
+	// Process messages that were queued before this tick started.
+	for pending := r.mailbox.Len(); pending > 0; pending-- {
+		msg, ok := r.mailbox.Take()
+		if !ok {
+			break
+		}
+		if err := r.dispatchMessage(ctx, msg.([]byte)); err != nil {
+			return false, err
+		}
+	}
+	return r.hasWork(), nil
+}
+
+func (r *Runtime) instantiate(ctx context.Context) error {
+	config, err := r.moduleConfig()
+	if err != nil {
+		return err
+	}
+	module, err := r.engine.InstantiateModule(ctx, r.compiled, config)
+	if err != nil {
+		return fmt.Errorf("instantiating %s: %w", r.name, err)
+	}
+	r.module = module
+	if fn := module.ExportedFunction("_initialize"); fn != nil {
+		return r.call(ctx, fn)
+	}
+	if fn := module.ExportedFunction("_start"); fn != nil {
+		return r.call(ctx, fn)
+	}
+	return nil
+}
+
+func (r *Runtime) dispatchMessage(ctx context.Context, data []byte) error {
+	onMessage := r.module.ExportedFunction("on_message")
+	if onMessage == nil {
+		r.logger.Debug("message dropped, module has no on_message export")
+		return nil
+	}
+	ptr, err := r.callResult(ctx, r.module.ExportedFunction("alloc"), api.EncodeU32(uint32(len(data))))
+	if err != nil {
+		return err
+	}
+	if !r.module.Memory().Write(uint32(ptr), data) {
+		return fmt.Errorf("%s: alloc returned %d, outside memory for %d bytes", r.name, uint32(ptr), len(data))
+	}
+	return r.call(ctx, onMessage, ptr, api.EncodeU32(uint32(len(data))))
+}
+
+// call runs an exported function under the handler budget.
+func (r *Runtime) call(ctx context.Context, fn api.Function, params ...uint64) error {
+	_, err := r.callResult(ctx, fn, params...)
+	return err
+}
+
+func (r *Runtime) callResult(ctx context.Context, fn api.Function, params ...uint64) (uint64, error) {
+	if r.handlerBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, r.handlerBudget, &BudgetExceededError{Scope: "handler", Limit: r.handlerBudget})
+		defer cancel()
+	}
+	results, err := fn.Call(ctx, params...)
+	r.stdout.flush()
+	r.stderr.flush()
+	if err != nil {
+		// The engine closes the module when ctx is done mid-call.
+		if cause := context.Cause(ctx); cause != nil {
+			return 0, r.terminate(cause)
+		}
+		var exit *sys.ExitError
+		if errors.As(err, &exit) {
+			if exit.ExitCode() == 0 {
+				r.exit()
+				return 0, nil
+			}
+			return 0, r.terminate(exit)
+		}
+		return 0, fmt.Errorf("%s: %w", r.name, err)
+	}
+	if len(results) == 0 {
+		return 0, nil
+	}
+	return results[0], nil
+}
+
+// terminate records that the module was stopped by cause.
+func (r *Runtime) terminate(cause error) error {
+	var budget *BudgetExceededError
+	if errors.As(cause, &budget) {
+		if budget.Scope == "tick" {
+			r.tickOverruns.Add(1)
+		} else {
+			r.handlerOverruns.Add(1)
+		}
+	}
+	r.failed = fmt.Errorf("%w: %w", ErrTerminated, cause)
+	return r.failed
+}
+
+// exit finishes the actor after the module exited with status 0.
+func (r *Runtime) exit() {
+	r.exited.Store(true)
+	r.timers = actor.NewTimerQueue()
+	for {
+		if _, ok := r.mailbox.Take(); !ok {
+			break
+		}
+	}
+}
+
+// hasWork reports whether there are timers or messages pending.
+func (r *Runtime) hasWork() bool {
+	return r.timers.Len() > 0 || r.mailbox.Len() > 0
+}
+
+// Deliver queues a message for the module's on_message export.
+// It returns actor.ErrMailboxFull if the mailbox is at capacity, or an
+// error if msg cannot be encoded.
+func (r *Runtime) Deliver(msg any) error {
+	switch {
+	case r.shutdown.Load():
+		return actor.ErrShutdown
+	case r.exited.Load():
+		return ErrExited
+	}
+
+	var data []byte
+	switch m := msg.(type) {
+	case []byte:
+		// Copy, the sender may reuse the slice once Deliver returns.
+		data = bytes.Clone(m)
+	case string:
+		data = []byte(m)
+	default:
+		var err error
+		if data, err = json.Marshal(msg); err != nil {
+			return fmt.Errorf("encoding message: %w", err)
+		}
+	}
+	if err := r.mailbox.Put(data); err != nil {
+		return err
+	}
+	r.signal()
+	return nil
+}
+
+// NextDeadline returns the deadline of the earliest pending timer.
+func (r *Runtime) NextDeadline() (time.Time, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.timers.Next()
+}
+
+// Wake returns a channel that receives a value when an external event arrives.
+func (r *Runtime) Wake() <-chan struct{} {
+	return r.wake
+}
+
+// signal notifies whoever is driving the runtime that it has work to do.
+// It never blocks; pending notifications are coalesced.
+func (r *Runtime) signal() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
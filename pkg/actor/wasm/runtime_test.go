@@ -0,0 +1,377 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/binding"
+	"orvalho/pkg/actor/internal/wasmtest"
+	"orvalho/pkg/actor/manifest"
+)
+
+var (
+	i32 = []byte{wasmtest.I32}
+	i64 = []byte{wasmtest.I64}
+)
+
+// host imports the runtime's host functions into m.
+type host struct {
+	log, setTimer, clearTimer uint32
+}
+
+func importHost(m *wasmtest.Module) host {
+	return host{
+		log:        m.Import(HostModule, "log", []byte{wasmtest.I32, wasmtest.I32, wasmtest.I32}, nil),
+		setTimer:   m.Import(HostModule, "set_timer", []byte{wasmtest.I64, wasmtest.I32}, i64),
+		clearTimer: m.Import(HostModule, "clear_timer", i64, nil),
+	}
+}
+
+// exportAlloc exports a bump allocator starting after the data segments.
+func exportAlloc(m *wasmtest.Module) {
+	heap := m.Global(wasmtest.I32, wasmtest.I32Const(1024))
+	m.Export("alloc", m.Func(i32, i32, nil,
+		wasmtest.GlobalGet(heap),
+		wasmtest.GlobalGet(heap), wasmtest.LocalGet(0), wasmtest.I32Add, wasmtest.GlobalSet(heap),
+	))
+}
+
+// echoModule logs every message it receives.
+func echoModule() []byte {
+	m := &wasmtest.Module{}
+	h := importHost(m)
+	m.Memory(1)
+	exportAlloc(m)
+	m.Export("on_message", m.Func([]byte{wasmtest.I32, wasmtest.I32}, nil, nil,
+		wasmtest.I32Const(0), wasmtest.LocalGet(0), wasmtest.LocalGet(1), wasmtest.Call(h.log),
+	))
+	return m.Bytes()
+}
+
+// timerModule sets timers during initialization and logs "tick" whenever
+// one fires. With once, the callback clears its own timer.
+func timerModule(delayMs int64, repeat bool, count int, once bool) []byte {
+	m := &wasmtest.Module{}
+	h := importHost(m)
+	m.Memory(1)
+	m.Data(0, []byte("tick"))
+
+	var init [][]byte
+	for range count {
+		r := int32(0)
+		if repeat {
+			r = 1
+		}
+		init = append(init, wasmtest.I64Const(delayMs), wasmtest.I32Const(r), wasmtest.Call(h.setTimer), wasmtest.Drop)
+	}
+	m.Export("_initialize", m.Func(nil, nil, nil, init...))
+
+	onTimer := [][]byte{wasmtest.I32Const(0), wasmtest.I32Const(0), wasmtest.I32Const(4), wasmtest.Call(h.log)}
+	if once {
+		onTimer = append(onTimer, wasmtest.LocalGet(0), wasmtest.Call(h.clearTimer))
+	}
+	m.Export("on_timer", m.Func(i64, nil, nil, onTimer...))
+	return m.Bytes()
+}
+
+func newRuntime(t *testing.T, code []byte, opts ...Option) *Runtime {
+	t.Helper()
+	r, err := New(code, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close(context.Background()) })
+	return r
+}
+
+func logMessages(r *Runtime) []string {
+	var msgs []string
+	for _, e := range r.Logs().Tail(0) {
+		msgs = append(msgs, e.Message)
+	}
+	return msgs
+}
+
+func TestDeliverMessage(t *testing.T) {
+	r := newRuntime(t, echoModule())
+	ctx := context.Background()
+
+	if err := r.Deliver("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Deliver(map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Deliver(func() {}); err == nil {
+		t.Error("expected an error for a message that can't be encoded")
+	}
+	reused := []byte("bytes")
+	if err := r.Deliver(reused); err != nil {
+		t.Fatal(err)
+	}
+	copy(reused, "reuse")
+
+	// The first tick instantiates the module; messages wait for the next one.
+	if more, err := r.Tick(ctx); err != nil || !more {
+		t.Fatalf("expected pending messages after initialization, got %v, %v", more, err)
+	}
+	if more, err := r.Tick(ctx); err != nil || more {
+		t.Fatalf("expected the mailbox to be drained, got %v, %v", more, err)
+	}
+	got := strings.Join(logMessages(r), "|")
+	if got != `hello|{"n":1}|bytes` {
+		t.Errorf("unexpected messages %q", got)
+	}
+}
+
+func TestMailboxFull(t *testing.T) {
+	r := newRuntime(t, echoModule(), WithMailboxSize(1))
+	r.Deliver("one")
+	if err := r.Deliver("two"); !errors.Is(err, actor.ErrMailboxFull) {
+		t.Errorf("expected ErrMailboxFull, got %v", err)
+	}
+}
+
+func TestTimers(t *testing.T) {
+	start := time.Unix(1000, 0)
+	clock := actor.NewVirtualClock(start)
+	r := newRuntime(t, timerModule(10, true, 1, false), WithClock(clock))
+	ctx := context.Background()
+
+	if more, err := r.Tick(ctx); err != nil || !more {
+		t.Fatalf("expected a pending timer, got %v, %v", more, err)
+	}
+	if next, ok := r.NextDeadline(); !ok || !next.Equal(start.Add(10*time.Millisecond)) {
+		t.Errorf("unexpected deadline %v, %v", next, ok)
+	}
+
+	r.Tick(ctx)
+	if n := len(logMessages(r)); n != 0 {
+		t.Fatalf("timer fired early, %d times", n)
+	}
+	for range 3 {
+		clock.Advance(10 * time.Millisecond)
+		if _, err := r.Tick(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := logMessages(r); len(got) != 3 || got[0] != "tick" {
+		t.Errorf("expected the interval to fire 3 times, got %v", got)
+	}
+}
+
+func TestTimerClearedByCallback(t *testing.T) {
+	clock := actor.NewVirtualClock(time.Unix(1000, 0))
+	r := newRuntime(t, timerModule(10, true, 1, true), WithClock(clock))
+	ctx := context.Background()
+
+	r.Tick(ctx)
+	clock.Advance(10 * time.Millisecond)
+	more, err := r.Tick(ctx)
+	if err != nil || more {
+		t.Errorf("a cleared interval should leave no work, got %v, %v", more, err)
+	}
+	clock.Advance(10 * time.Millisecond)
+	r.Tick(ctx)
+	if got := logMessages(r); len(got) != 1 {
+		t.Errorf("expected the interval to fire once, got %v", got)
+	}
+}
+
+func TestTimerCapabilities(t *testing.T) {
+	ctx := context.Background()
+
+	r := newRuntime(t, timerModule(10, false, 1, false), WithCapabilities(manifest.CapabilitySet{}))
+	if more, err := r.Tick(ctx); err != nil || more {
+		t.Errorf("set_timer should fail without the timers capability, got %v, %v", more, err)
+	}
+
+	caps := manifest.CapabilitySet{Timers: &manifest.TimerCapability{Max: 2}}
+	r = newRuntime(t, timerModule(10, false, 3, false), WithCapabilities(caps))
+	if _, err := r.Tick(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.timers.Len(); n != 2 {
+		t.Errorf("expected 2 pending timers, got %d", n)
+	}
+}
+
+func TestHandlerBudget(t *testing.T) {
+	m := &wasmtest.Module{}
+	m.Memory(1)
+	exportAlloc(m)
+	m.Export("on_message", m.Func([]byte{wasmtest.I32, wasmtest.I32}, nil, nil,
+		wasmtest.Loop(), wasmtest.Br(0), wasmtest.End,
+	))
+	r := newRuntime(t, m.Bytes(), WithHandlerBudget(50*time.Millisecond))
+	ctx := context.Background()
+
+	if _, err := r.Tick(ctx); err != nil {
+		t.Fatal(err)
+	}
+	r.Deliver("spin")
+	_, err := r.Tick(ctx)
+	var budget *BudgetExceededError
+	if !errors.As(err, &budget) || budget.Scope != "handler" {
+		t.Fatalf("expected a handler budget error, got %v", err)
+	}
+	if !errors.Is(err, ErrTerminated) {
+		t.Errorf("expected ErrTerminated, got %v", err)
+	}
+	if s := r.Stats(); s.HandlerOverruns != 1 || s.TickOverruns != 0 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+	if _, err := r.Tick(ctx); !errors.Is(err, ErrTerminated) {
+		t.Errorf("a terminated module should stay terminated, got %v", err)
+	}
+}
+
+func TestTickBudget(t *testing.T) {
+	m := &wasmtest.Module{}
+	m.Export("_initialize", m.Func(nil, nil, nil, wasmtest.Loop(), wasmtest.Br(0), wasmtest.End))
+	r := newRuntime(t, m.Bytes(), WithTickBudget(50*time.Millisecond))
+
+	_, err := r.Tick(context.Background())
+	var budget *BudgetExceededError
+	if !errors.As(err, &budget) || budget.Scope != "tick" {
+		t.Fatalf("expected a tick budget error, got %v", err)
+	}
+	if s := r.Stats(); s.TickOverruns != 1 || s.Ticks != 1 {
+		t.Errorf("unexpected stats %+v", s)
+	}
+}
+
+func TestContextCancel(t *testing.T) {
+	m := &wasmtest.Module{}
+	m.Export("_initialize", m.Func(nil, nil, nil, wasmtest.Loop(), wasmtest.Br(0), wasmtest.End))
+	r := newRuntime(t, m.Bytes())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.Tick(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the tick to be interrupted, got %v", err)
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	grow := func(pages int32) []byte {
+		m := &wasmtest.Module{}
+		m.Memory(1)
+		m.Export("_initialize", m.Func(nil, nil, nil,
+			wasmtest.I32Const(pages), wasmtest.MemoryGrow, wasmtest.I32Const(-1), wasmtest.I32Eq,
+			wasmtest.If(), wasmtest.Unreachable, wasmtest.End,
+		))
+		return m.Bytes()
+	}
+	ctx := context.Background()
+
+	r := newRuntime(t, grow(2), WithMemoryLimit(4*65536))
+	if _, err := r.Tick(ctx); err != nil {
+		t.Errorf("growing within the limit failed: %v", err)
+	}
+
+	caps := manifest.CapabilitySet{Limits: manifest.ResourceLimits{Memory: 4 * 65536}}
+	r = newRuntime(t, grow(8), WithCapabilities(caps))
+	if _, err := r.Tick(ctx); err == nil || !strings.Contains(err.Error(), "unreachable") {
+		t.Errorf("expected memory.grow past the limit to fail, got %v", err)
+	}
+}
+
+func TestNewRejectsBadModules(t *testing.T) {
+	if _, err := New([]byte("not wasm")); err == nil {
+		t.Error("expected an error for invalid code")
+	}
+
+	m := &wasmtest.Module{}
+	m.Memory(1)
+	m.Export("on_message", m.Func([]byte{wasmtest.I32, wasmtest.I32}, nil, nil))
+	if _, err := New(m.Bytes()); err == nil || !strings.Contains(err.Error(), "alloc") {
+		t.Errorf("expected on_message without alloc to be rejected, got %v", err)
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	for _, graceful := range []bool{true, false} {
+		m := &wasmtest.Module{}
+		h := importHost(m)
+		m.Memory(1)
+		m.Data(0, []byte("bye"))
+		exportAlloc(m)
+		m.Export("on_message", m.Func([]byte{wasmtest.I32, wasmtest.I32}, nil, nil,
+			wasmtest.I32Const(0), wasmtest.LocalGet(0), wasmtest.LocalGet(1), wasmtest.Call(h.log),
+		))
+		m.Export("on_shutdown", m.Func(nil, nil, nil,
+			wasmtest.I32Const(0), wasmtest.I32Const(0), wasmtest.I32Const(3), wasmtest.Call(h.log),
+		))
+		closed := 0
+		r := newRuntime(t, m.Bytes(), WithBinding(binding.Binding{Name: "kv", Close: func() error {
+			closed++
+			return nil
+		}}))
+		ctx := context.Background()
+
+		if _, err := r.Tick(ctx); err != nil {
+			t.Fatal(err)
+		}
+		r.Deliver("queued")
+		if err := r.Shutdown(ctx, graceful); err != nil {
+			t.Fatal(err)
+		}
+		want := ""
+		if graceful {
+			want = "queued|bye"
+		}
+		if got := strings.Join(logMessages(r), "|"); got != want {
+			t.Errorf("graceful=%v: logged %q, want %q", graceful, got, want)
+		}
+		if closed != 1 {
+			t.Errorf("graceful=%v: binding closed %d times", graceful, closed)
+		}
+		if _, err := r.Tick(ctx); !errors.Is(err, actor.ErrShutdown) {
+			t.Errorf("expected Tick to fail with ErrShutdown, got %v", err)
+		}
+		if err := r.Deliver("late"); !errors.Is(err, actor.ErrShutdown) {
+			t.Errorf("expected Deliver to fail with ErrShutdown, got %v", err)
+		}
+	}
+}
+
+func TestShutdownCancelsRunningCall(t *testing.T) {
+	for _, graceful := range []bool{true, false} {
+		m := &wasmtest.Module{}
+		m.Memory(1)
+		exportAlloc(m)
+		m.Export("on_message", m.Func([]byte{wasmtest.I32, wasmtest.I32}, nil, nil,
+			wasmtest.Loop(), wasmtest.Br(0), wasmtest.End,
+		))
+		r := newRuntime(t, m.Bytes())
+		if _, err := r.Tick(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		r.Deliver("spin")
+		ticked := make(chan error, 1)
+		go func() {
+			_, err := r.Tick(context.Background())
+			ticked <- err
+		}()
+		for r.mailbox.Len() > 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		r.Shutdown(ctx, graceful)
+		cancel()
+		select {
+		case err := <-ticked:
+			if !errors.Is(err, actor.ErrShutdown) {
+				t.Errorf("graceful=%v: expected the call to end with ErrShutdown, got %v", graceful, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("graceful=%v: Shutdown did not stop the running call", graceful)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// maxLineLength is how much of an unterminated stdout or stderr line is
+// buffered before it is logged anyway.
+const maxLineLength = 4096
+
+// instantiateWASI provides WASI preview1 to guests.
+func (r *Runtime) instantiateWASI(ctx context.Context) error {
+	_, err := wasi_snapshot_preview1.Instantiate(ctx, r.engine)
+	return err
+}
+
+// moduleConfig sets up what the guest sees through WASI: the arguments and
+// environment, stdout and stderr logged line by line, the runtime clock and
+// random source, and the storage directory mounted at "/" when storage is
+// granted. Sleeping returns immediately; actors wait with timers instead.
+//
+// The mount is not bounded by the storage quota, which only the KV store
+// enforces; a module granted storage may fill the directory's file system.
+func (r *Runtime) moduleConfig() (wazero.ModuleConfig, error) {
+	config := wazero.NewModuleConfig().
+		WithName(r.name).
+		WithStartFunctions().
+		WithArgs(append([]string{r.name}, r.args...)...).
+		WithStdout(r.stdout).
+		WithStderr(r.stderr).
+		WithRandSource(r.random).
+		WithWalltime(r.walltime, sys.ClockResolution(time.Microsecond)).
+		WithNanotime(r.nanotime, sys.ClockResolution(time.Microsecond))
+	for _, key := range slices.Sorted(maps.Keys(r.env)) {
+		config = config.WithEnv(key, r.env[key])
+	}
+
+	if r.storageDir != "" && (r.caps == nil || r.caps.Storage != nil) {
+		if err := os.MkdirAll(r.storageDir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating storage directory: %w", err)
+		}
+		if r.caps != nil && r.caps.Storage.Quota > 0 {
+			r.logger.Warn("storage quota is not enforced on the mounted directory", "dir", r.storageDir, "quota", r.caps.Storage.Quota)
+		}
+		config = config.WithFSConfig(wazero.NewFSConfig().WithDirMount(r.storageDir, "/"))
+	}
+	return config, nil
+}
+
+func (r *Runtime) walltime() (int64, int32) {
+	now := r.clock.Now()
+	return now.Unix(), int32(now.Nanosecond())
+}
+
+// nanotime counts from when the runtime was created, so the real clock's
+// monotonic reading is used, and never goes back even if the clock does.
+// Guest calls run one at a time, under the runtime's lock.
+func (r *Runtime) nanotime() int64 {
+	if n := int64(r.clock.Now().Sub(r.epoch)); n > r.lastNanotime {
+		r.lastNanotime = n
+	}
+	return r.lastNanotime
+}
+
+// lineWriter logs every line written to it as a separate entry.
+type lineWriter struct {
+	runtime *Runtime
+	level   slog.Level
+	stream  string
+	buf     []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) >= maxLineLength {
+		w.flush()
+	}
+	return len(p), nil
+}
+
+// flush logs whatever is left of an unterminated line.
+func (w *lineWriter) flush() {
+	if len(w.buf) > 0 {
+		w.emit(w.buf)
+	}
+	w.buf = nil
+}
+
+func (w *lineWriter) emit(line []byte) {
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	w.runtime.logger.LogAttrs(context.Background(), w.level, string(line), slog.String("stream", w.stream))
+}
@@ -0,0 +1,182 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"orvalho/pkg/actor"
+	"orvalho/pkg/actor/internal/wasmtest"
+	"orvalho/pkg/actor/manifest"
+)
+
+const wasi = "wasi_snapshot_preview1"
+
+// Memory layout of wasiModule.
+const (
+	stdoutIOV   = 0   // {16, 6}
+	stderrIOV   = 24  // {40, 4}
+	fileIOV     = 48  // {56, 4}
+	clockResult = 64  // u64
+	randomBuf   = 72  // 8 bytes
+	argc        = 80  // u32, followed by the argv buffer size
+	envc        = 88  // u32, followed by the environ buffer size
+	openedFD    = 96  // u32
+	openErrno   = 100 // u32
+	fileName    = 104 // "notes.txt"
+	nwritten    = 136 // u32
+)
+
+// wasiModule exercises WASI from _initialize, leaving results in memory.
+func wasiModule() []byte {
+	m := &wasmtest.Module{}
+	fdWrite := m.Import(wasi, "fd_write", []byte{wasmtest.I32, wasmtest.I32, wasmtest.I32, wasmtest.I32}, i32)
+	clockTimeGet := m.Import(wasi, "clock_time_get", []byte{wasmtest.I32, wasmtest.I64, wasmtest.I32}, i32)
+	randomGet := m.Import(wasi, "random_get", []byte{wasmtest.I32, wasmtest.I32}, i32)
+	argsSizesGet := m.Import(wasi, "args_sizes_get", []byte{wasmtest.I32, wasmtest.I32}, i32)
+	environSizesGet := m.Import(wasi, "environ_sizes_get", []byte{wasmtest.I32, wasmtest.I32}, i32)
+	pathOpen := m.Import(wasi, "path_open", []byte{
+		wasmtest.I32, wasmtest.I32, wasmtest.I32, wasmtest.I32, wasmtest.I32,
+		wasmtest.I64, wasmtest.I64, wasmtest.I32, wasmtest.I32,
+	}, i32)
+
+	m.Memory(1)
+	m.Data(stdoutIOV, []byte{16, 0, 0, 0, 6, 0, 0, 0})
+	m.Data(16, []byte("hello\n"))
+	m.Data(stderrIOV, []byte{40, 0, 0, 0, 4, 0, 0, 0})
+	m.Data(40, []byte("oops"))
+	m.Data(fileIOV, []byte{56, 0, 0, 0, 4, 0, 0, 0})
+	m.Data(56, []byte("data"))
+	m.Data(fileName, []byte("notes.txt"))
+
+	c := wasmtest.I32Const
+	m.Export("_initialize", m.Func(nil, nil, nil,
+		c(1), c(stdoutIOV), c(1), c(nwritten), wasmtest.Call(fdWrite), wasmtest.Drop,
+		c(2), c(stderrIOV), c(1), c(nwritten), wasmtest.Call(fdWrite), wasmtest.Drop,
+		c(0), wasmtest.I64Const(1), c(clockResult), wasmtest.Call(clockTimeGet), wasmtest.Drop,
+		c(randomBuf), c(8), wasmtest.Call(randomGet), wasmtest.Drop,
+		c(argc), c(argc+4), wasmtest.Call(argsSizesGet), wasmtest.Drop,
+		c(envc), c(envc+4), wasmtest.Call(environSizesGet), wasmtest.Drop,
+		// Create notes.txt in the preopened root directory and write to it.
+		c(openErrno),
+		c(3), c(0), c(fileName), c(9), c(1), wasmtest.I64Const(-1), wasmtest.I64Const(-1), c(0), c(openedFD),
+		wasmtest.Call(pathOpen), wasmtest.I32Store(),
+		c(openedFD), wasmtest.I32Load(), c(fileIOV), c(1), c(nwritten), wasmtest.Call(fdWrite), wasmtest.Drop,
+	))
+	return m.Bytes()
+}
+
+func TestWASI(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	storage := filepath.Join(t.TempDir(), "storage")
+	a := manifest.Actor{
+		Name:    "worker",
+		Runtime: manifest.RuntimeWASM,
+		Entry:   "worker.wasm",
+		Args:    []string{"--verbose", "fast"},
+		Env:     map[string]string{"MODE": "test"},
+		Capabilities: manifest.CapabilitySet{
+			Storage: &manifest.StorageCapability{},
+		},
+	}
+	r := newRuntime(t, wasiModule(),
+		WithActor(a),
+		WithStorageDir(storage),
+		WithClock(actor.NewVirtualClock(start)),
+		WithRandSource(bytes.NewReader([]byte("01234567"))),
+	)
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range r.Logs().Tail(0) {
+		var stream string
+		for _, attr := range e.Attrs {
+			if attr.Key == "stream" {
+				stream = attr.Value.String()
+			}
+		}
+		got = append(got, stream+":"+e.Message)
+	}
+	if len(got) != 2 || got[0] != "stdout:hello" || got[1] != "stderr:oops" {
+		t.Errorf("unexpected output %q", got)
+	}
+
+	mem := r.module.Memory()
+	if ns, _ := mem.ReadUint64Le(clockResult); int64(ns) != start.UnixNano() {
+		t.Errorf("expected the runtime clock, got %v", time.Unix(0, int64(ns)))
+	}
+	if random, _ := mem.Read(randomBuf, 8); string(random) != "01234567" {
+		t.Errorf("expected the configured random source, got %q", random)
+	}
+	if n, _ := mem.ReadUint32Le(argc); n != 3 {
+		t.Errorf("expected the program name and 2 args, got %d", n)
+	}
+	if n, _ := mem.ReadUint32Le(envc); n != 1 {
+		t.Errorf("expected 1 environment variable, got %d", n)
+	}
+	if errno, _ := mem.ReadUint32Le(openErrno); errno != 0 {
+		t.Fatalf("path_open failed with errno %d", errno)
+	}
+	data, err := os.ReadFile(filepath.Join(storage, "notes.txt"))
+	if err != nil || string(data) != "data" {
+		t.Errorf("expected the file in the storage directory, got %q, %v", data, err)
+	}
+}
+
+func TestWASIWithoutStorage(t *testing.T) {
+	storage := t.TempDir()
+	r := newRuntime(t, wasiModule(), WithStorageDir(storage), WithCapabilities(manifest.CapabilitySet{}))
+	if _, err := r.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if errno, _ := r.module.Memory().ReadUint32Le(openErrno); errno == 0 {
+		t.Error("path_open should fail when storage is not granted")
+	}
+	if _, err := os.Stat(filepath.Join(storage, "notes.txt")); !os.IsNotExist(err) {
+		t.Errorf("no file should be created, got %v", err)
+	}
+}
+
+func TestCommandExit(t *testing.T) {
+	exitModule := func(status int32) []byte {
+		m := &wasmtest.Module{}
+		procExit := m.Import(wasi, "proc_exit", i32, nil)
+		m.Export("_start", m.Func(nil, nil, nil, wasmtest.I32Const(status), wasmtest.Call(procExit)))
+		return m.Bytes()
+	}
+	ctx := context.Background()
+
+	r := newRuntime(t, exitModule(0))
+	if more, err := r.Tick(ctx); err != nil || more {
+		t.Errorf("exiting with status 0 should finish the actor, got %v, %v", more, err)
+	}
+	if err := r.Deliver("late"); !errors.Is(err, ErrExited) {
+		t.Errorf("expected ErrExited, got %v", err)
+	}
+
+	r = newRuntime(t, exitModule(3))
+	if _, err := r.Tick(ctx); !errors.Is(err, ErrTerminated) {
+		t.Errorf("a non-zero exit status should terminate the module, got %v", err)
+	}
+}
+
+func TestNanotimeIsMonotonic(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := actor.NewVirtualClock(start)
+	r := newRuntime(t, wasiModule(), WithClock(clock))
+
+	clock.Advance(time.Second)
+	if got := r.nanotime(); got != int64(time.Second) {
+		t.Errorf("expected the time since the runtime was created, got %v", time.Duration(got))
+	}
+	clock.Set(start.Add(-time.Hour))
+	if got := r.nanotime(); got != int64(time.Second) {
+		t.Errorf("nanotime went back with the clock to %v", time.Duration(got))
+	}
+}
@@ -0,0 +1,351 @@
+// Package bundle reads and writes actor packages: ZIP archives holding a
+// manifest plus the JS and WASM files of every actor it declares.
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"testing/fstest"
+	"time"
+
+	"orvalho/pkg/actor/manifest"
+)
+
+const (
+	// MaxEntrySize is the largest uncompressed file a bundle may contain.
+	MaxEntrySize = 32 << 20
+	// MaxTotalSize is the largest a bundle may be once uncompressed.
+	MaxTotalSize = 128 << 20
+	// MaxEntries is the most files a bundle may contain.
+	MaxEntries = 4096
+)
+
+var (
+	// ErrInvalidPath is returned for entries that are absolute, escape the
+	// bundle root or are otherwise not clean relative paths.
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrTooLarge is returned when an entry or the whole bundle exceeds its size limit.
+	ErrTooLarge = errors.New("bundle too large")
+	// ErrNoManifest is returned when the bundle has no manifest.json at its root.
+	ErrNoManifest = errors.New("bundle has no " + manifest.FileName)
+	// ErrMissingEntry is returned when an actor's entry point is not in the bundle.
+	ErrMissingEntry = errors.New("entry point not found")
+)
+
+// wasmMagic starts every WebAssembly binary.
+var wasmMagic = []byte("\x00asm")
+
+// Hash is the content hash of a bundle.
+type Hash [sha256.Size]byte
+
+// String formats h as "sha256:<hex>".
+func (h Hash) String() string {
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+// ParseHash parses the output of Hash.String.
+func ParseHash(s string) (Hash, error) {
+	var h Hash
+	hexed, ok := strings.CutPrefix(s, "sha256:")
+	if !ok {
+		return h, fmt.Errorf("hash %q: unsupported algorithm", s)
+	}
+	raw, err := hex.DecodeString(hexed)
+	if err != nil || len(raw) != len(h) {
+		return h, fmt.Errorf("hash %q: malformed digest", s)
+	}
+	copy(h[:], raw)
+	return h, nil
+}
+
+// Bundle is a validated actor package held in memory.
+type Bundle struct {
+	Manifest  *manifest.Manifest
+	files     map[string][]byte
+	hash      Hash
+	signature *Signature // nil if unsigned
+}
+
+// ReadFile reads and validates the bundle at name.
+func ReadFile(name string) (*Bundle, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return Read(f, info.Size())
+}
+
+// Read reads and validates a bundle. Every entry must be a clean relative
+// path within the size limits, the manifest must be valid and every actor's
+// entry point must be present.
+func Read(r io.ReaderAt, size int64) (*Bundle, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bundle: %w", err)
+	}
+	if len(zr.File) > MaxEntries {
+		return nil, fmt.Errorf("%w: more than %d entries", ErrTooLarge, MaxEntries)
+	}
+
+	files := make(map[string][]byte, len(zr.File))
+	var total int64
+	for _, f := range zr.File {
+		if strings.HasSuffix(f.Name, "/") && f.UncompressedSize64 == 0 {
+			continue // directory
+		}
+		if err := checkPath(f.Name); err != nil {
+			return nil, err
+		}
+		if !f.Mode().IsRegular() {
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrInvalidPath, f.Name)
+		}
+		if _, dup := files[f.Name]; dup {
+			return nil, fmt.Errorf("%w: duplicate entry %s", ErrInvalidPath, f.Name)
+		}
+
+		data, err := readEntry(f)
+		if err != nil {
+			return nil, err
+		}
+		total += int64(len(data))
+		if total > MaxTotalSize {
+			return nil, fmt.Errorf("%w: more than %d bytes uncompressed", ErrTooLarge, MaxTotalSize)
+		}
+		files[f.Name] = data
+	}
+
+	var signature *Signature
+	if data, ok := files[SignatureFile]; ok {
+		delete(files, SignatureFile)
+		signature = new(Signature)
+		if err := signature.UnmarshalText(data); err != nil {
+			return nil, err
+		}
+	}
+
+	b, err := newBundle(files)
+	if err != nil {
+		return nil, err
+	}
+	b.signature = signature
+	return b, nil
+}
+
+// readEntry decompresses f, trusting neither its declared size nor its compression ratio.
+func readEntry(f *zip.File) ([]byte, error) {
+	if f.UncompressedSize64 > MaxEntrySize {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrTooLarge, f.Name, MaxEntrySize)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(io.LimitReader(rc, MaxEntrySize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", f.Name, err)
+	}
+	if len(data) > MaxEntrySize {
+		return nil, fmt.Errorf("%w: %s exceeds %d bytes", ErrTooLarge, f.Name, MaxEntrySize)
+	}
+	return data, nil
+}
+
+// checkPath rejects names that could escape the bundle root once extracted.
+func checkPath(name string) error {
+	switch {
+	case name == "",
+		strings.HasPrefix(name, "/"),
+		strings.Contains(name, "\\"),
+		strings.ContainsRune(name, 0),
+		path.Clean(name) != name,
+		name == "..", strings.HasPrefix(name, "../"):
+		return fmt.Errorf("%w: %q", ErrInvalidPath, name)
+	}
+	return nil
+}
+
+func newBundle(files map[string][]byte) (*Bundle, error) {
+	data, ok := files[manifest.FileName]
+	if !ok {
+		return nil, ErrNoManifest
+	}
+	m, err := manifest.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range m.Actors {
+		entry, ok := files[a.Entry]
+		if !ok {
+			return nil, fmt.Errorf("%w: actor %s: %s", ErrMissingEntry, a.Name, a.Entry)
+		}
+		if a.Runtime == manifest.RuntimeWASM && !bytes.HasPrefix(entry, wasmMagic) {
+			return nil, fmt.Errorf("actor %s: %s is not a WebAssembly module", a.Name, a.Entry)
+		}
+	}
+
+	return &Bundle{Manifest: m, files: files, hash: contentHash(files)}, nil
+}
+
+// contentHash digests every file's path and contents in path order, so it
+// depends only on what the bundle holds, not on how the ZIP was written.
+func contentHash(files map[string][]byte) Hash {
+	h := sha256.New()
+	for _, name := range sortedNames(files) {
+		sum := sha256.Sum256(files[name])
+		fmt.Fprintf(h, "%x  %s\n", sum, name)
+	}
+	var out Hash
+	h.Sum(out[:0])
+	return out
+}
+
+func sortedNames(files map[string][]byte) []string {
+	return slices.Sorted(maps.Keys(files))
+}
+
+// Hash returns the bundle's content hash.
+func (b *Bundle) Hash() Hash {
+	return b.hash
+}
+
+// Files lists the paths of every file in the bundle, sorted. The signature
+// is not one of them.
+func (b *Bundle) Files() []string {
+	return sortedNames(b.files)
+}
+
+// File returns the contents of the file at name.
+func (b *Bundle) File(name string) ([]byte, bool) {
+	data, ok := b.files[name]
+	return data, ok
+}
+
+// Entry returns the manifest entry of the named actor and the contents of its entry point.
+func (b *Bundle) Entry(actor string) (manifest.Actor, []byte, error) {
+	a, ok := b.Manifest.Actor(actor)
+	if !ok {
+		return manifest.Actor{}, nil, fmt.Errorf("bundle %s has no actor %q", b.Manifest.Name, actor)
+	}
+	return a, b.files[a.Entry], nil
+}
+
+// FS returns the bundle's files as a read-only file system, e.g. for js.Load.
+func (b *Bundle) FS() fs.FS {
+	fsys := make(fstest.MapFS, len(b.files))
+	for name, data := range b.files {
+		fsys[name] = &fstest.MapFile{Data: data, Mode: 0o444}
+	}
+	return fsys
+}
+
+// New packs m and the files of fsys into an unsigned bundle. The manifest is
+// stored in canonical form; any manifest.json in fsys is replaced by m.
+func New(m *manifest.Manifest, fsys fs.FS) (*Bundle, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	manifestData, err := manifest.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	files := map[string][]byte{manifest.FileName: manifestData}
+	err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || name == manifest.FileName {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return fmt.Errorf("%w: %s is not a regular file", ErrInvalidPath, name)
+		}
+		if name == SignatureFile {
+			return fmt.Errorf("%w: %s is reserved", ErrInvalidPath, name)
+		}
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		if len(data) > MaxEntrySize {
+			return fmt.Errorf("%w: %s exceeds %d bytes", ErrTooLarge, name, MaxEntrySize)
+		}
+		files[name] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newBundle(files)
+}
+
+// Write packs m and the files of fsys into an unsigned bundle archive.
+func Write(w io.Writer, m *manifest.Manifest, fsys fs.FS) error {
+	b, err := New(m, fsys)
+	if err != nil {
+		return err
+	}
+	_, err = b.WriteTo(w)
+	return err
+}
+
+// WriteTo writes b as a ZIP archive, including its signature if it has one.
+// Entries are ordered and timestamped deterministically, so the same bundle
+// always produces the same archive.
+func (b *Bundle) WriteTo(w io.Writer) (int64, error) {
+	files := b.files
+	if b.signature != nil {
+		data, err := b.signature.MarshalText()
+		if err != nil {
+			return 0, err
+		}
+		files = maps.Clone(files)
+		files[SignatureFile] = data
+	}
+
+	cw := &countingWriter{w: w}
+	zw := zip.NewWriter(cw)
+	for _, name := range sortedNames(files) {
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			return cw.n, err
+		}
+		if _, err := fw.Write(files[name]); err != nil {
+			return cw.n, err
+		}
+	}
+	err := zw.Close()
+	return cw.n, err
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,195 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"orvalho/pkg/actor/manifest"
+)
+
+func testManifest() *manifest.Manifest {
+	return &manifest.Manifest{
+		Name:    "dev.example.photos",
+		Version: "1.0.0",
+		Actors: []manifest.Actor{
+			{Name: "web", Runtime: manifest.RuntimeJS, Entry: "web/main.js"},
+			{Name: "thumbs", Runtime: manifest.RuntimeWASM, Entry: "thumbs.wasm"},
+		},
+	}
+}
+
+func testFiles() fstest.MapFS {
+	return fstest.MapFS{
+		"web/main.js":  {Data: []byte(`export default { fetch() { return new Response("hi"); } };`)},
+		"web/lib.js":   {Data: []byte(`export const x = 1;`)},
+		"thumbs.wasm":  {Data: []byte("\x00asm\x01\x00\x00\x00")},
+		"assets/a.txt": {Data: []byte("asset")},
+	}
+}
+
+func writeBundle(t *testing.T, m *manifest.Manifest, fsys fstest.MapFS) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, m, fsys); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rawZip builds an archive by hand, bypassing Write's checks.
+func rawZip(t *testing.T, files map[string][]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(files[name])
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestWriteRead(t *testing.T) {
+	data := writeBundle(t, testManifest(), testFiles())
+
+	b, err := Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.Manifest.Name != "dev.example.photos" || len(b.Manifest.Actors) != 2 {
+		t.Errorf("unexpected manifest %+v", b.Manifest)
+	}
+
+	want := []string{"assets/a.txt", "manifest.json", "thumbs.wasm", "web/lib.js", "web/main.js"}
+	if got := b.Files(); !slices.Equal(got, want) {
+		t.Errorf("expected files %v, got %v", want, got)
+	}
+
+	a, entry, err := b.Entry("web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Runtime != manifest.RuntimeJS || !bytes.Contains(entry, []byte("Response")) {
+		t.Errorf("unexpected entry for web: %+v %q", a, entry)
+	}
+	if _, _, err := b.Entry("missing"); err == nil {
+		t.Error("expected an error for an unknown actor")
+	}
+
+	if _, err := b.FS().Open("web/lib.js"); err != nil {
+		t.Errorf("bundle FS should serve files: %v", err)
+	}
+}
+
+func TestWriteIsReproducible(t *testing.T) {
+	first := writeBundle(t, testManifest(), testFiles())
+
+	m := testManifest()
+	slices.Reverse(m.Actors)
+	second := writeBundle(t, m, testFiles())
+	if !bytes.Equal(first, second) {
+		t.Error("equal inputs should produce identical archives")
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	data := writeBundle(t, testManifest(), testFiles())
+	b, err := Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The hash only depends on contents, not on how the archive was built.
+	files := map[string][]byte{}
+	for _, name := range b.Files() {
+		files[name], _ = b.File(name)
+	}
+	raw := rawZip(t, files)
+	same, err := Read(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same.Hash() != b.Hash() {
+		t.Errorf("hash changed with archive layout: %s vs %s", same.Hash(), b.Hash())
+	}
+
+	files["assets/a.txt"] = []byte("tampered")
+	raw = rawZip(t, files)
+	changed, err := Read(bytes.NewReader(raw), int64(len(raw)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed.Hash() == b.Hash() {
+		t.Error("changing a file should change the hash")
+	}
+
+	parsed, err := ParseHash(b.Hash().String())
+	if err != nil || parsed != b.Hash() {
+		t.Errorf("ParseHash(%s) = %s, %v", b.Hash(), parsed, err)
+	}
+}
+
+func TestReadRejects(t *testing.T) {
+	manifestData, err := manifest.Marshal(testManifest())
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := func() map[string][]byte {
+		return map[string][]byte{
+			"manifest.json": manifestData,
+			"web/main.js":   []byte("1"),
+			"thumbs.wasm":   []byte("\x00asm\x01\x00\x00\x00"),
+		}
+	}
+
+	tests := []struct {
+		name   string
+		modify func(files map[string][]byte)
+		want   error
+	}{
+		{"path traversal", func(f map[string][]byte) { f["../evil.js"] = []byte("x") }, ErrInvalidPath},
+		{"nested traversal", func(f map[string][]byte) { f["web/../../evil.js"] = []byte("x") }, ErrInvalidPath},
+		{"absolute path", func(f map[string][]byte) { f["/etc/passwd"] = []byte("x") }, ErrInvalidPath},
+		{"backslash", func(f map[string][]byte) { f[`web\..\evil.js`] = []byte("x") }, ErrInvalidPath},
+		{"oversized entry", func(f map[string][]byte) { f["big.bin"] = make([]byte, MaxEntrySize+1) }, ErrTooLarge},
+		{"no manifest", func(f map[string][]byte) { delete(f, "manifest.json") }, ErrNoManifest},
+		{"missing entry", func(f map[string][]byte) { delete(f, "web/main.js") }, ErrMissingEntry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := valid()
+			tt.modify(files)
+			raw := rawZip(t, files)
+			if _, err := Read(bytes.NewReader(raw), int64(len(raw))); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	t.Run("not wasm", func(t *testing.T) {
+		files := valid()
+		files["thumbs.wasm"] = []byte("not wasm")
+		raw := rawZip(t, files)
+		if _, err := Read(bytes.NewReader(raw), int64(len(raw))); err == nil {
+			t.Error("expected a non-WebAssembly entry to be rejected")
+		}
+	})
+}
+
+func TestWriteRejectsMissingEntry(t *testing.T) {
+	files := testFiles()
+	delete(files, "thumbs.wasm")
+	if err := Write(&bytes.Buffer{}, testManifest(), files); !errors.Is(err, ErrMissingEntry) {
+		t.Errorf("expected ErrMissingEntry, got %v", err)
+	}
+}
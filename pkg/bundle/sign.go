@@ -0,0 +1,153 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// SignatureFile is the archive entry holding a bundle's signature. It is
+// excluded from the content hash, so signing does not change what is signed.
+const SignatureFile = "SIGNATURE"
+
+// signatureNamespace separates bundle signatures from anything else the same key signs.
+const signatureNamespace = "orvalho-bundle-v1"
+
+var (
+	// ErrUnsigned is returned when verifying a bundle that carries no signature.
+	ErrUnsigned = errors.New("bundle is not signed")
+	// ErrUnknownSigner is returned when a bundle is signed by a key that is not trusted.
+	ErrUnknownSigner = errors.New("bundle signed by an unknown publisher")
+	// ErrTampered is returned when a trusted publisher's signature does not
+	// match the bundle's contents.
+	ErrTampered = errors.New("bundle contents do not match its signature")
+)
+
+// Signature is a detached signature over a bundle's content hash.
+type Signature struct {
+	PublicKey ssh.PublicKey
+	Blob      *ssh.Signature
+}
+
+// signatureFile is how a Signature is stored in SignatureFile.
+type signatureFile struct {
+	PublicKey string `json:"public_key"` // authorized_keys format
+	Format    string `json:"format"`
+	Signature string `json:"signature"` // base64
+}
+
+func (s *Signature) MarshalText() ([]byte, error) {
+	data, err := json.MarshalIndent(signatureFile{
+		PublicKey: strings.TrimSpace(string(ssh.MarshalAuthorizedKey(s.PublicKey))),
+		Format:    s.Blob.Format,
+		Signature: base64.StdEncoding.EncodeToString(s.Blob.Blob),
+	}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+func (s *Signature) UnmarshalText(data []byte) error {
+	var f signatureFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("malformed %s: %w", SignatureFile, err)
+	}
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(f.PublicKey))
+	if err != nil {
+		return fmt.Errorf("malformed %s: public key: %w", SignatureFile, err)
+	}
+	blob, err := base64.StdEncoding.DecodeString(f.Signature)
+	if err != nil {
+		return fmt.Errorf("malformed %s: signature: %w", SignatureFile, err)
+	}
+	s.PublicKey = key
+	s.Blob = &ssh.Signature{Format: f.Format, Blob: blob}
+	return nil
+}
+
+// signedMessage is what a bundle signature covers.
+func signedMessage(h Hash) []byte {
+	return []byte(signatureNamespace + "\n" + h.String() + "\n")
+}
+
+// Sign signs b's content hash with signer, replacing any previous signature.
+// identity.Identity.Signer provides a suitable signer.
+func (b *Bundle) Sign(signer ssh.Signer) error {
+	blob, err := signer.Sign(nil, signedMessage(b.hash))
+	if err != nil {
+		return fmt.Errorf("failed to sign bundle: %w", err)
+	}
+	b.signature = &Signature{PublicKey: signer.PublicKey(), Blob: blob}
+	return nil
+}
+
+// Signature returns the bundle's signature, if it has one.
+func (b *Bundle) Signature() (*Signature, bool) {
+	return b.signature, b.signature != nil
+}
+
+// Keyring is the set of publisher keys a node trusts bundles from.
+type Keyring struct {
+	keys []ssh.PublicKey
+}
+
+// NewKeyring returns a keyring trusting keys.
+func NewKeyring(keys ...ssh.PublicKey) *Keyring {
+	return &Keyring{keys: keys}
+}
+
+// ParseKeyring reads trusted keys in authorized_keys format, one per line,
+// such as identity.Identity.SSHPublicKey of the manager.
+func ParseKeyring(data []byte) (*Keyring, error) {
+	k := &Keyring{}
+	for len(bytes.TrimSpace(data)) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse trusted key: %w", err)
+		}
+		k.Add(key)
+		data = rest
+	}
+	return k, nil
+}
+
+// Add trusts key.
+func (k *Keyring) Add(key ssh.PublicKey) {
+	if !k.Trusts(key) {
+		k.keys = append(k.keys, key)
+	}
+}
+
+// Trusts reports whether key is in the keyring.
+func (k *Keyring) Trusts(key ssh.PublicKey) bool {
+	for _, trusted := range k.keys {
+		if bytes.Equal(trusted.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Verify checks that b was signed by a trusted publisher and has not been
+// modified since. It returns the signer's key, or ErrUnsigned,
+// ErrUnknownSigner or ErrTampered.
+func (k *Keyring) Verify(b *Bundle) (ssh.PublicKey, error) {
+	sig, ok := b.Signature()
+	if !ok {
+		return nil, ErrUnsigned
+	}
+	fingerprint := ssh.FingerprintSHA256(sig.PublicKey)
+	if !k.Trusts(sig.PublicKey) {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownSigner, fingerprint)
+	}
+	if err := sig.PublicKey.Verify(signedMessage(b.hash), sig.Blob); err != nil {
+		return nil, fmt.Errorf("%w: signed by %s, content hash %s", ErrTampered, fingerprint, b.hash)
+	}
+	return sig.PublicKey, nil
+}
@@ -0,0 +1,150 @@
+package bundle
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+
+	"orvalho/pkg/identity"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func manager(t *testing.T) (*identity.Identity, ssh.Signer) {
+	t.Helper()
+	id, err := identity.DeriveIdentities("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := id.Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id, signer
+}
+
+func signedBundle(t *testing.T, signer ssh.Signer) []byte {
+	t.Helper()
+	b, err := New(testManifest(), testFiles())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Sign(signer); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func readBundle(t *testing.T, data []byte) *Bundle {
+	t.Helper()
+	b, err := Read(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// rewrite copies an archive, replacing the contents of one entry.
+func rewrite(t *testing.T, data []byte, name string, contents []byte) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		files[f.Name], _ = io.ReadAll(rc)
+		rc.Close()
+	}
+	files[name] = contents
+	return rawZip(t, files)
+}
+
+func TestSignVerify(t *testing.T) {
+	id, signer := manager(t)
+	keyring, err := ParseKeyring([]byte(id.SSHPublicKey + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := readBundle(t, signedBundle(t, signer))
+	if _, ok := b.Signature(); !ok {
+		t.Fatal("signature was not read back")
+	}
+	for _, name := range b.Files() {
+		if name == SignatureFile {
+			t.Error("the signature should not be listed as a bundle file")
+		}
+	}
+
+	key, err := keyring.Verify(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key.Marshal(), signer.PublicKey().Marshal()) {
+		t.Error("Verify returned the wrong signer")
+	}
+
+	unsigned, _ := New(testManifest(), testFiles())
+	if unsigned.Hash() != b.Hash() {
+		t.Error("signing should not change the content hash")
+	}
+}
+
+func TestVerifyErrors(t *testing.T) {
+	id, signer := manager(t)
+	keyring, err := ParseKeyring([]byte(id.SSHPublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := signedBundle(t, signer)
+
+	t.Run("unsigned", func(t *testing.T) {
+		b, _ := New(testManifest(), testFiles())
+		if _, err := keyring.Verify(b); !errors.Is(err, ErrUnsigned) {
+			t.Errorf("expected ErrUnsigned, got %v", err)
+		}
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		b := readBundle(t, rewrite(t, data, "web/main.js", []byte(`export default {};`)))
+		_, err := keyring.Verify(b)
+		if !errors.Is(err, ErrTampered) || errors.Is(err, ErrUnknownSigner) {
+			t.Errorf("expected ErrTampered, got %v", err)
+		}
+	})
+
+	t.Run("unknown signer", func(t *testing.T) {
+		_, priv, _ := ed25519.GenerateKey(rand.Reader)
+		other, err := ssh.NewSignerFromKey(priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := readBundle(t, signedBundle(t, other))
+		_, err = keyring.Verify(b)
+		if !errors.Is(err, ErrUnknownSigner) || errors.Is(err, ErrTampered) {
+			t.Errorf("expected ErrUnknownSigner, got %v", err)
+		}
+
+		keyring.Add(other.PublicKey())
+		if _, err := keyring.Verify(b); err != nil {
+			t.Errorf("a newly trusted publisher should verify: %v", err)
+		}
+	})
+
+	t.Run("malformed signature", func(t *testing.T) {
+		raw := rewrite(t, data, SignatureFile, []byte("garbage"))
+		if _, err := Read(bytes.NewReader(raw), int64(len(raw))); err == nil {
+			t.Error("expected a malformed signature to be rejected")
+		}
+	})
+}
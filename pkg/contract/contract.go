@@ -0,0 +1,209 @@
+// Package contract reads host interface definitions and generates the glue
+// that exposes them to actors: a Go interface for the host to implement, a
+// runtime-independent binding.Binding wrapping it, options installing it
+// into the JS and WASM runtimes, and TypeScript declarations for actor
+// authors.
+//
+// Contracts are XML files in the spirit of Wayland protocol specs:
+//
+//	<contract name="kv">
+//	  <description>provides key-value storage private to the actor.</description>
+//	  <record name="entry">
+//	    <field name="key" type="string"/>
+//	    <field name="expires_at" type="s64" optional="true" summary="Unix milliseconds"/>
+//	  </record>
+//	  <method name="get" async="true">
+//	    <description>returns the value stored under key.</description>
+//	    <arg name="key" type="string"/>
+//	    <result type="bytes" optional="true"/>
+//	  </method>
+//	</contract>
+//
+// Descriptions complete a sentence starting with the name of what they
+// describe, like Go doc comments; summaries are free-form phrases.
+//
+// Types are bool, s32, s64, u32, u64, f64, string, bytes, any, or the name of
+// a record; list="true" makes a list of them. bytes may only be used as a
+// whole argument or result, since it crosses to JS as an ArrayBuffer.
+package contract
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+)
+
+// Primitive types.
+const (
+	Bool   = "bool"
+	S32    = "s32"
+	S64    = "s64"
+	U32    = "u32"
+	U64    = "u64"
+	F64    = "f64"
+	String = "string"
+	Bytes  = "bytes"
+	Any    = "any"
+)
+
+var primitives = []string{Bool, S32, S64, U32, U64, F64, String, Bytes, Any}
+
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// Contract is a set of host methods exposed to actors under one name.
+type Contract struct {
+	XMLName     xml.Name `xml:"contract"`
+	Name        string   `xml:"name,attr"`
+	Description string   `xml:"description"`
+	Records     []Record `xml:"record"`
+	Methods     []Method `xml:"method"`
+}
+
+// Record is a structured type passed to or returned from methods.
+type Record struct {
+	Name        string  `xml:"name,attr"`
+	Description string  `xml:"description"`
+	Fields      []Value `xml:"field"`
+}
+
+// Method is a host method callable by actors.
+type Method struct {
+	Name        string  `xml:"name,attr"`
+	Async       bool    `xml:"async,attr"`
+	Description string  `xml:"description"`
+	Args        []Value `xml:"arg"`
+	Result      *Value  `xml:"result"`
+}
+
+// Value is a typed record field, method argument or method result.
+type Value struct {
+	Name     string `xml:"name,attr"`
+	Type     string `xml:"type,attr"`
+	List     bool   `xml:"list,attr"`
+	Optional bool   `xml:"optional,attr"`
+	Summary  string `xml:"summary,attr"`
+}
+
+// Parse reads and validates a contract.
+func Parse(data []byte) (*Contract, error) {
+	var c Contract
+	if err := xml.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parsing contract: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Record returns the record with the given name.
+func (c *Contract) Record(name string) (Record, bool) {
+	i := slices.IndexFunc(c.Records, func(r Record) bool { return r.Name == name })
+	if i < 0 {
+		return Record{}, false
+	}
+	return c.Records[i], true
+}
+
+// Validate checks names, types and references. It returns every problem found.
+func (c *Contract) Validate() error {
+	var errs []error
+	fail := func(where, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", where, fmt.Sprintf(format, args...)))
+	}
+	checkName := func(where, name string, seen map[string]bool) {
+		switch {
+		case !namePattern.MatchString(name):
+			fail(where, "%q is not a snake_case name", name)
+		case seen[name]:
+			fail(where, "duplicate name %q", name)
+		}
+		seen[name] = true
+	}
+	checkValue := func(where string, v Value, nested bool) {
+		_, isRecord := c.Record(v.Type)
+		switch {
+		case !slices.Contains(primitives, v.Type) && !isRecord:
+			fail(where, "unknown type %q", v.Type)
+		case v.Type == Bytes && (nested || v.List):
+			fail(where, "bytes may only be used as a whole argument or result")
+		}
+	}
+
+	if !namePattern.MatchString(c.Name) {
+		fail("contract", "%q is not a snake_case name", c.Name)
+	}
+
+	types := map[string]bool{}
+	for _, p := range primitives {
+		types[p] = true
+	}
+	for _, r := range c.Records {
+		where := "record " + r.Name
+		checkName(where, r.Name, types)
+		fields := map[string]bool{}
+		for _, f := range r.Fields {
+			checkName(where+" field "+f.Name, f.Name, fields)
+			checkValue(where+" field "+f.Name, f, true)
+		}
+	}
+	if cycle := c.recordCycle(); cycle != "" {
+		fail("record "+cycle, "contains itself; make the field optional or a list")
+	}
+
+	methods := map[string]bool{}
+	for _, m := range c.Methods {
+		where := "method " + m.Name
+		checkName(where, m.Name, methods)
+		args := map[string]bool{}
+		for _, a := range m.Args {
+			checkName(where+" arg "+a.Name, a.Name, args)
+			checkValue(where+" arg "+a.Name, a, false)
+		}
+		if m.Result != nil {
+			checkValue(where+" result", *m.Result, false)
+		}
+	}
+	if len(c.Methods) == 0 {
+		fail("contract", "no methods")
+	}
+	return errors.Join(errs...)
+}
+
+// recordCycle returns a record that contains itself through required,
+// non-list fields, which no language could represent.
+func (c *Contract) recordCycle() string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var visit func(name string) string
+	visit = func(name string) string {
+		switch state[name] {
+		case visiting:
+			return name
+		case done:
+			return ""
+		}
+		state[name] = visiting
+		r, _ := c.Record(name)
+		for _, f := range r.Fields {
+			if _, ok := c.Record(f.Type); ok && !f.List && !f.Optional {
+				if cycle := visit(f.Type); cycle != "" {
+					return cycle
+				}
+			}
+		}
+		state[name] = done
+		return ""
+	}
+	for _, r := range c.Records {
+		if cycle := visit(r.Name); cycle != "" {
+			return cycle
+		}
+	}
+	return ""
+}
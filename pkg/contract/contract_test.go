@@ -0,0 +1,105 @@
+package contract
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func readContract(t *testing.T, name string) *Contract {
+	t.Helper()
+	data, err := os.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := "testdata/" + name
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s is out of date, run go test -update; got\n%s", path, got)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	c := readContract(t, "kv.xml")
+
+	code, err := GenerateGo(c, GoOptions{Package: "kv", Source: "kv.xml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "kv.go.golden", code)
+	checkGolden(t, "kv.d.ts.golden", GenerateTS(c, "kv.xml"))
+}
+
+func TestValidate(t *testing.T) {
+	_, err := Parse([]byte(`
+		<contract name="Store">
+			<record name="node">
+				<field name="next" type="node"/>
+				<field name="data" type="bytes"/>
+			</record>
+			<method name="get">
+				<arg name="key" type="strng"/>
+				<arg name="key" type="string"/>
+				<result type="bytes" list="true"/>
+			</method>
+			<method name="get"/>
+		</contract>
+	`))
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+	for _, want := range []string{
+		`contract: "Store" is not a snake_case name`,
+		"record node field data: bytes may only be used",
+		"record node: contains itself",
+		`method get arg key: unknown type "strng"`,
+		`method get arg key: duplicate name "key"`,
+		"method get result: bytes may only be used",
+		`method get: duplicate name "get"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q in\n%v", want, err)
+		}
+	}
+}
+
+func TestNames(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"kv":         {"KV", "kv"},
+		"list_keys":  {"ListKeys", "listKeys"},
+		"user_id":    {"UserID", "userID"},
+		"id_token":   {"IDToken", "idToken"},
+		"type":       {"Type", "type_"},
+		"args":       {"Args", "args_"},
+		"expires_at": {"ExpiresAt", "expiresAt"},
+	} {
+		if got := exportedName(name); got != want[0] {
+			t.Errorf("exportedName(%q) = %q, want %q", name, got, want[0])
+		}
+		if got := localName(name); got != want[1] {
+			t.Errorf("localName(%q) = %q, want %q", name, got, want[1])
+		}
+	}
+}
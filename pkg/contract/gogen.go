@@ -0,0 +1,162 @@
+package contract
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+)
+
+// GoOptions configures GenerateGo.
+type GoOptions struct {
+	// Package is the package clause of the generated file.
+	Package string
+	// Source is the contract file name mentioned in the generated header.
+	Source string
+}
+
+var goPrimitives = map[string]string{
+	Bool: "bool", S32: "int32", S64: "int64", U32: "uint32", U64: "uint64",
+	F64: "float64", String: "string", Bytes: "[]byte", Any: "any",
+}
+
+// GenerateGo generates the host side of a contract: a record struct per
+// record, an interface for the host to implement, a function wrapping an
+// implementation in a binding.Binding, and options installing it into the
+// JS and WASM runtimes.
+func GenerateGo(c *Contract, opts GoOptions) ([]byte, error) {
+	iface := exportedName(c.Name)
+	var b bytes.Buffer
+	p := func(format string, args ...any) { fmt.Fprintf(&b, format+"\n", args...) }
+	doc := func(indent, name, text, fallback string) {
+		if strings.TrimSpace(text) != "" {
+			text = name + " " + text
+		} else {
+			text = fallback
+		}
+		lines := comment(text, 76)
+		for _, line := range lines {
+			p("%s// %s", indent, line)
+		}
+	}
+
+	p("// Code generated by orvalho-bindgen from %s. DO NOT EDIT.", opts.Source)
+	p("")
+	p("package %s", opts.Package)
+	p("")
+	p("import (")
+	p("\t\"context\"")
+	p("")
+	p("\t\"orvalho/pkg/actor/binding\"")
+	p("\t\"orvalho/pkg/actor/js\"")
+	p("\t\"orvalho/pkg/actor/wasm\"")
+	p(")")
+
+	for _, r := range c.Records {
+		p("")
+		doc("", exportedName(r.Name), r.Description, fmt.Sprintf("%s is a record of the %s contract.", exportedName(r.Name), c.Name))
+		p("type %s struct {", exportedName(r.Name))
+		for _, f := range r.Fields {
+			doc("\t", "", "", f.Summary)
+			tag := f.Name
+			if f.Optional {
+				tag += ",omitempty"
+			}
+			p("\t%s %s `json:%q`", exportedName(f.Name), c.goType(f), tag)
+		}
+		p("}")
+	}
+
+	p("")
+	doc("", iface, c.Description, fmt.Sprintf("%s is implemented by the host to provide the %s contract.", iface, c.Name))
+	p("type %s interface {", iface)
+	for i, m := range c.Methods {
+		if i > 0 {
+			p("")
+		}
+		doc("\t", exportedName(m.Name), m.Description, "")
+		p("\t%s(%s) %s", exportedName(m.Name), c.goParams(m), c.goResults(m))
+	}
+	p("}")
+
+	p("")
+	p("// %sBinding exposes impl to actors as the %s contract.", iface, c.Name)
+	p("func %sBinding(impl %s) binding.Binding {", iface, iface)
+	p("\treturn binding.Binding{")
+	p("\t\tName: %q,", c.Name)
+	p("\t\tMethods: []binding.Method{")
+	for _, m := range c.Methods {
+		p("\t\t\t{")
+		p("\t\t\t\tName: %q,", m.Name)
+		if m.Async {
+			p("\t\t\t\tAsync: true,")
+		}
+		p("\t\t\t\tCall: func(ctx context.Context, args binding.Args) (any, error) {")
+		call := []string{"ctx"}
+		for i, a := range m.Args {
+			name := localName(a.Name)
+			p("\t\t\t\t\tvar %s %s", name, c.goType(a))
+			p("\t\t\t\t\tif err := args.Decode(%d, &%s); err != nil {", i, name)
+			p("\t\t\t\t\t\treturn nil, err")
+			p("\t\t\t\t\t}")
+			call = append(call, name)
+		}
+		invoke := fmt.Sprintf("impl.%s(%s)", exportedName(m.Name), strings.Join(call, ", "))
+		if m.Result != nil {
+			p("\t\t\t\t\treturn %s", invoke)
+		} else {
+			p("\t\t\t\t\treturn nil, %s", invoke)
+		}
+		p("\t\t\t\t},")
+		p("\t\t\t},")
+	}
+	p("\t\t},")
+	p("\t}")
+	p("}")
+
+	p("")
+	p("// With%sJS exposes impl to a JS actor as env.%s.", iface, strings.ToUpper(c.Name))
+	p("func With%sJS(impl %s) js.Option {", iface, iface)
+	p("\treturn js.WithBinding(%sBinding(impl))", iface)
+	p("}")
+	p("")
+	p("// With%sWASM lets a WASM actor import impl's methods from %q.", iface, "orvalho:"+c.Name)
+	p("func With%sWASM(impl %s) wasm.Option {", iface, iface)
+	p("\treturn wasm.WithBinding(%sBinding(impl))", iface)
+	p("}")
+
+	out, err := format.Source(b.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+	return out, nil
+}
+
+func (c *Contract) goType(v Value) string {
+	t, ok := goPrimitives[v.Type]
+	if !ok {
+		t = exportedName(v.Type)
+	}
+	switch {
+	case v.List:
+		return "[]" + t
+	case v.Optional && v.Type != Bytes && v.Type != Any:
+		return "*" + t
+	}
+	return t
+}
+
+func (c *Contract) goParams(m Method) string {
+	params := []string{"ctx context.Context"}
+	for _, a := range m.Args {
+		params = append(params, localName(a.Name)+" "+c.goType(a))
+	}
+	return strings.Join(params, ", ")
+}
+
+func (c *Contract) goResults(m Method) string {
+	if m.Result == nil {
+		return "error"
+	}
+	return "(" + c.goType(*m.Result) + ", error)"
+}
@@ -0,0 +1,65 @@
+package contract
+
+import (
+	"go/token"
+	"strings"
+)
+
+// initialisms are written in upper case in Go and TypeScript names.
+var initialisms = map[string]bool{
+	"api": true, "http": true, "id": true, "io": true, "ip": true, "json": true,
+	"kv": true, "ttl": true, "uri": true, "url": true, "uuid": true, "xml": true,
+}
+
+// exportedName converts a snake_case name to an exported Go or TypeScript
+// type name, e.g. "list_keys" to "ListKeys" and "kv" to "KV".
+func exportedName(name string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(name, "_") {
+		switch {
+		case part == "":
+		case initialisms[part]:
+			b.WriteString(strings.ToUpper(part))
+		default:
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// localName converts a snake_case name to a Go local variable name that
+// doesn't clash with keywords or the generated code's own variables.
+func localName(name string) string {
+	exported := exportedName(name)
+	first := strings.Split(name, "_")[0]
+	local := strings.ToLower(exported[:len(first)]) + exported[len(first):]
+	switch local {
+	case "ctx", "args", "impl", "err":
+		return local + "_"
+	}
+	if token.IsKeyword(local) {
+		return local + "_"
+	}
+	return local
+}
+
+// comment wraps text into lines of at most width characters, collapsing the
+// indentation XML descriptions tend to carry.
+func comment(text string, width int) []string {
+	var lines []string
+	var line strings.Builder
+	for _, word := range strings.Fields(text) {
+		if line.Len() > 0 && line.Len()+1+len(word) > width {
+			lines = append(lines, line.String())
+			line.Reset()
+		}
+		if line.Len() > 0 {
+			line.WriteByte(' ')
+		}
+		line.WriteString(word)
+	}
+	if line.Len() > 0 {
+		lines = append(lines, line.String())
+	}
+	return lines
+}
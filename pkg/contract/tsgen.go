@@ -0,0 +1,122 @@
+package contract
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"orvalho/pkg/actor/binding"
+)
+
+var tsPrimitives = map[string]string{
+	Bool: "boolean", S32: "number", S64: "number", U32: "number", U64: "number",
+	F64: "number", String: "string", Any: "unknown",
+}
+
+// GenerateTS generates TypeScript declarations for actor authors: an
+// interface per record, one for the binding object, and an Env interface
+// declaring where the binding lives. source names the contract file in the
+// generated header.
+func GenerateTS(c *Contract, source string) []byte {
+	iface := exportedName(c.Name)
+	var b bytes.Buffer
+	p := func(format string, args ...any) { fmt.Fprintf(&b, format+"\n", args...) }
+	doc := func(indent, name, text string) {
+		if name != "" && strings.TrimSpace(text) != "" {
+			text = name + " " + text
+		}
+		lines := comment(text, 76)
+		switch len(lines) {
+		case 0:
+		case 1:
+			p("%s/** %s */", indent, lines[0])
+		default:
+			p("%s/**", indent)
+			for _, line := range lines {
+				p("%s * %s", indent, line)
+			}
+			p("%s */", indent)
+		}
+	}
+
+	p("// Code generated by orvalho-bindgen from %s. DO NOT EDIT.", source)
+
+	p("")
+	doc("", iface, c.Description)
+	p("export interface %s {", iface)
+	for _, m := range c.Methods {
+		doc("  ", binding.JSName(m.Name), m.Description)
+		result := "void"
+		if m.Result != nil {
+			result = c.tsType(*m.Result, false)
+			if m.Result.Optional {
+				result += " | null"
+			}
+		}
+		if m.Async {
+			result = "Promise<" + result + ">"
+		}
+		p("  %s(%s): %s;", binding.JSName(m.Name), c.tsParams(m), result)
+	}
+	p("}")
+
+	for _, r := range c.Records {
+		p("")
+		doc("", exportedName(r.Name), r.Description)
+		p("export interface %s {", exportedName(r.Name))
+		for _, f := range r.Fields {
+			doc("  ", "", f.Summary)
+			optional := ""
+			if f.Optional {
+				optional = "?"
+			}
+			p("  %s%s: %s;", f.Name, optional, c.tsType(f, false))
+		}
+		p("}")
+	}
+
+	p("")
+	p("/** The bindings the %s contract adds to env. */", c.Name)
+	p("export interface Env {")
+	p("  %s: %s;", strings.ToUpper(c.Name), iface)
+	p("}")
+	return b.Bytes()
+}
+
+// tsType returns the TypeScript type of v. bytes arguments accept an
+// ArrayBuffer or Uint8Array; bytes results are always ArrayBuffers.
+func (c *Contract) tsType(v Value, arg bool) string {
+	t, ok := tsPrimitives[v.Type]
+	switch {
+	case v.Type == Bytes && arg:
+		t = "ArrayBuffer | Uint8Array"
+	case v.Type == Bytes:
+		t = "ArrayBuffer"
+	case !ok:
+		t = exportedName(v.Type)
+	}
+	if v.List {
+		t += "[]"
+	}
+	return t
+}
+
+func (c *Contract) tsParams(m Method) string {
+	var params []string
+	for i, a := range m.Args {
+		name, t := binding.JSName(a.Name), c.tsType(a, true)
+		if a.Optional {
+			t += " | null"
+			// Only trailing arguments may be left out.
+			trailing := true
+			for _, rest := range m.Args[i+1:] {
+				trailing = trailing && rest.Optional
+			}
+			if trailing {
+				name += "?"
+			}
+		}
+		params = append(params, name+": "+t)
+	}
+	return strings.Join(params, ", ")
+}
@@ -109,3 +109,13 @@ func DeriveIdentities(mnemonic, passphrase string) (*Identity, error) {
 		AgeRecipient:     ageIdentity.Recipient().String(),
 	}, nil
 }
+
+// Signer returns an SSH signer for the identity's Ed25519 key, used for
+// example to sign actor bundles.
+func (id *Identity) Signer() (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(id.SSHPrivateKeyPEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SSH private key: %w", err)
+	}
+	return signer, nil
+}
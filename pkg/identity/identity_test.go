@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"crypto/rand"
 	"io"
+	"strings"
 	"testing"
 
 	"filippo.io/age"
@@ -116,6 +117,29 @@ func TestDeriveIdentities(t *testing.T) {
 	}
 }
 
+func TestSigner(t *testing.T) {
+	id, err := DeriveIdentities("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := id.Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))); got != id.SSHPublicKey {
+		t.Errorf("signer key %q does not match the identity's %q", got, id.SSHPublicKey)
+	}
+	msg := []byte("hello")
+	sig, err := signer.Sign(rand.Reader, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := signer.PublicKey().Verify(msg, sig); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+}
+
 func TestVector(t *testing.T) {
 	// Optional: add a known vector if we had one to ensure we don't regress on the derivation path logic.
 	// Since we use a custom path, we just ensure stability.
@@ -0,0 +1,447 @@
+// Package install manages the bundles installed on a node: it unpacks them
+// into versioned directories, records the permissions the user granted, and
+// switches between versions atomically so upgrades and rollbacks survive a
+// crash or power loss at any point.
+//
+// The layout under the store's directory is:
+//
+//	packages/<name>/state.json           current and previous version
+//	packages/<name>/<version>_<hash>/    unpacked bundle files
+//	tmp/                                 staging area, emptied on Open
+package install
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"orvalho/pkg/actor/manifest"
+	"orvalho/pkg/bundle"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const stateFile = "state.json"
+
+var (
+	// ErrNotInstalled is returned for packages that are not installed.
+	ErrNotInstalled = errors.New("package not installed")
+	// ErrNoPrevious is returned when rolling back a package with no previous version.
+	ErrNoPrevious = errors.New("no previous version to roll back to")
+	// ErrDenied is returned when the new permissions of an install were not approved.
+	ErrDenied = errors.New("permissions not granted")
+)
+
+// Config configures a Store.
+type Config struct {
+	// Dir is where packages are installed.
+	Dir string
+	// Keyring holds the publishers bundles must be signed by. It defaults
+	// to trusting ManagerKey alone.
+	Keyring *bundle.Keyring
+	// ManagerKey is the SSH public key of the manager the node is paired
+	// with, identity.Identity.SSHPublicKey, in authorized_keys format.
+	ManagerKey string
+	// AllowUnsigned accepts bundles without a signature, for development.
+	// Signed bundles are still verified when there is a Keyring.
+	AllowUnsigned bool
+	// OnSwitch, if set, is called after a package's current version changed,
+	// so running actors can be restarted on it. from is nil on first install.
+	OnSwitch func(from, to *Installed)
+}
+
+// Installed describes one installed version of a package.
+type Installed struct {
+	Name    string              `json:"name"`
+	Version string              `json:"version"`
+	Hash    string              `json:"hash"`
+	Signer  string              `json:"signer,omitempty"` // SHA256 fingerprint of the publisher key
+	Granted map[string][]string `json:"granted"`          // permissions per actor, see manifest.CapabilitySet.Permissions
+	Time    time.Time           `json:"installed_at"`
+
+	// Dir is the directory holding the unpacked bundle.
+	Dir string `json:"-"`
+}
+
+// FS returns the installed files, e.g. for js.Load. Their signature was
+// checked when they were installed and is not checked again: the files are
+// the store's own read-only copy.
+func (i *Installed) FS() fs.FS {
+	return os.DirFS(i.Dir)
+}
+
+// Manifest reads the installed manifest.
+func (i *Installed) Manifest() (*manifest.Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(i.Dir, manifest.FileName))
+	if err != nil {
+		return nil, err
+	}
+	return manifest.Parse(data)
+}
+
+func (i *Installed) dirName() string {
+	return i.Version + "_" + i.Hash[len("sha256:"):][:12]
+}
+
+// state is the content of a package's state.json.
+type state struct {
+	Current  *Installed `json:"current"`
+	Previous *Installed `json:"previous,omitempty"`
+}
+
+// Store is the set of packages installed on a node.
+type Store struct {
+	cfg Config
+	mu  sync.Mutex
+}
+
+// Open opens the store in cfg.Dir, creating it if needed and discarding
+// anything a crash left half-written.
+func Open(cfg Config) (*Store, error) {
+	if cfg.Keyring == nil && cfg.ManagerKey != "" {
+		keyring, err := bundle.ParseKeyring([]byte(cfg.ManagerKey))
+		if err != nil {
+			return nil, fmt.Errorf("install: manager key: %w", err)
+		}
+		cfg.Keyring = keyring
+	}
+	if cfg.Keyring == nil && !cfg.AllowUnsigned {
+		return nil, errors.New("install: a Keyring or ManagerKey is required unless AllowUnsigned is set")
+	}
+	s := &Store{cfg: cfg}
+	if err := os.RemoveAll(s.tmpDir()); err != nil {
+		return nil, err
+	}
+	for _, dir := range []string{s.tmpDir(), s.packagesDir()} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	// Drop version directories that were unpacked but never switched to.
+	names, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		st, err := s.readState(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.prune(name, st); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Store) tmpDir() string      { return filepath.Join(s.cfg.Dir, "tmp") }
+func (s *Store) packagesDir() string { return filepath.Join(s.cfg.Dir, "packages") }
+func (s *Store) packageDir(name string) string {
+	return filepath.Join(s.packagesDir(), name)
+}
+
+// List returns the names of installed packages, sorted.
+func (s *Store) List() ([]string, error) {
+	entries, err := os.ReadDir(s.packagesDir())
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if _, err := os.Stat(filepath.Join(s.packageDir(e.Name()), stateFile)); err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// Current returns the running version of a package.
+func (s *Store) Current(name string) (*Installed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.readState(name)
+	if err != nil {
+		return nil, err
+	}
+	return st.Current, nil
+}
+
+// Previous returns the version a Rollback would switch to.
+func (s *Store) Previous(name string) (*Installed, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.readState(name)
+	if err != nil {
+		return nil, err
+	}
+	if st.Previous == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoPrevious, name)
+	}
+	return st.Previous, nil
+}
+
+// Install verifies b, unpacks it and makes it the current version of its
+// package, keeping the old version for Rollback. approve is shown every
+// permission the new version adds over the current one; if it returns false,
+// or is nil while there are new permissions, nothing is changed and ErrDenied
+// is returned. Installing the version that is already current is a no-op.
+func (s *Store) Install(b *bundle.Bundle, approve func(changes []manifest.Change) bool) (*Installed, error) {
+	signer, err := s.verify(b)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	from, to, err := s.install(b, signer, approve)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	s.notify(from, to)
+	return to, nil
+}
+
+func (s *Store) install(b *bundle.Bundle, signer string, approve func([]manifest.Change) bool) (from, to *Installed, err error) {
+	name := b.Manifest.Name
+	st, err := s.readState(name)
+	if err != nil && !errors.Is(err, ErrNotInstalled) {
+		return nil, nil, err
+	}
+	if st.Current != nil && st.Current.Hash == b.Hash().String() {
+		return st.Current, st.Current, nil
+	}
+
+	var old *manifest.Manifest
+	if st.Current != nil {
+		if old, err = st.Current.Manifest(); err != nil {
+			return nil, nil, fmt.Errorf("failed to read installed manifest: %w", err)
+		}
+	}
+	if granted := manifest.Granted(manifest.Diff(old, b.Manifest)); len(granted) > 0 {
+		if approve == nil || !approve(granted) {
+			return nil, nil, fmt.Errorf("%w: %s %s", ErrDenied, name, b.Manifest.Version)
+		}
+	}
+
+	inst := &Installed{
+		Name:    name,
+		Version: b.Manifest.Version,
+		Hash:    b.Hash().String(),
+		Signer:  signer,
+		Granted: map[string][]string{},
+		Time:    time.Now().UTC(),
+	}
+	for _, a := range b.Manifest.Actors {
+		inst.Granted[a.Name] = a.Capabilities.Permissions()
+	}
+	inst.Dir = filepath.Join(s.packageDir(name), inst.dirName())
+
+	if err := s.unpack(b, inst.Dir); err != nil {
+		return nil, nil, err
+	}
+	if err := s.switchTo(name, &state{Current: inst, Previous: st.Current}); err != nil {
+		return nil, nil, err
+	}
+	return st.Current, inst, nil
+}
+
+// Rollback makes the previous version of a package current again. The
+// version rolled back from becomes the previous one, so a second Rollback
+// undoes the first.
+func (s *Store) Rollback(name string) (*Installed, error) {
+	s.mu.Lock()
+	st, err := s.readState(name)
+	if err == nil && st.Previous == nil {
+		err = fmt.Errorf("%w: %s", ErrNoPrevious, name)
+	}
+	if err == nil {
+		err = s.switchTo(name, &state{Current: st.Previous, Previous: st.Current})
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	s.notify(st.Current, st.Previous)
+	return st.Previous, nil
+}
+
+// notify reports a version switch to OnSwitch. It runs without the lock held,
+// so the callback may use the store.
+func (s *Store) notify(from, to *Installed) {
+	if s.cfg.OnSwitch != nil && from != to {
+		s.cfg.OnSwitch(from, to)
+	}
+}
+
+func (s *Store) verify(b *bundle.Bundle) (string, error) {
+	_, signed := b.Signature()
+	if !signed && s.cfg.AllowUnsigned {
+		return "", nil
+	}
+	if s.cfg.Keyring == nil {
+		return "", nil
+	}
+	key, err := s.cfg.Keyring.Verify(b)
+	if err != nil {
+		return "", err
+	}
+	return ssh.FingerprintSHA256(key), nil
+}
+
+// unpack writes b's files to dir, going through a staging directory so dir
+// either holds the complete, synced bundle or does not exist.
+func (s *Store) unpack(b *bundle.Bundle, dir string) error {
+	if _, err := os.Stat(dir); err == nil {
+		return nil // already unpacked, e.g. reinstalling the previous version
+	}
+
+	staging, err := os.MkdirTemp(s.tmpDir(), "unpack-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	dirs := map[string]bool{staging: true}
+	for _, name := range b.Files() {
+		data, _ := b.File(name)
+		path := filepath.Join(staging, filepath.FromSlash(name))
+		for d := filepath.Dir(path); !dirs[d]; d = filepath.Dir(d) {
+			dirs[d] = true
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := writeFileSync(path, data, 0o444); err != nil {
+			return err
+		}
+	}
+	// Sync deepest directories first so every entry is durable before its parent.
+	sorted := make([]string, 0, len(dirs))
+	for d := range dirs {
+		sorted = append(sorted, d)
+	}
+	slices.SortFunc(sorted, func(a, b string) int { return len(b) - len(a) })
+	for _, d := range sorted {
+		if err := syncDir(d); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(staging, dir); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(dir))
+}
+
+// switchTo atomically replaces a package's state, then removes version
+// directories neither state references anymore.
+func (s *Store) switchTo(name string, next *state) error {
+	data, err := json.MarshalIndent(next, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.packageDir(name), stateFile), data); err != nil {
+		return fmt.Errorf("failed to switch %s: %w", name, err)
+	}
+	return s.prune(name, next)
+}
+
+// prune removes the version directories of name that st does not reference.
+func (s *Store) prune(name string, st *state) error {
+	keep := map[string]bool{stateFile: true}
+	for _, inst := range []*Installed{st.Current, st.Previous} {
+		if inst != nil {
+			keep[inst.dirName()] = true
+		}
+	}
+	entries, err := os.ReadDir(s.packageDir(name))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !keep[e.Name()] {
+			if err := os.RemoveAll(filepath.Join(s.packageDir(name), e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Store) readState(name string) (*state, error) {
+	data, err := os.ReadFile(filepath.Join(s.packageDir(name), stateFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &state{}, fmt.Errorf("%w: %s", ErrNotInstalled, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, fmt.Errorf("corrupt state for %s: %w", name, err)
+	}
+	if st.Current == nil {
+		return nil, fmt.Errorf("corrupt state for %s: no current version", name)
+	}
+	for _, inst := range []*Installed{st.Current, st.Previous} {
+		if inst != nil {
+			inst.Dir = filepath.Join(s.packageDir(name), inst.dirName())
+		}
+	}
+	return &st, nil
+}
+
+// writeFileAtomic replaces path with data such that, even across a power
+// loss, path holds either its old or its new contents in full.
+func writeFileAtomic(path string, data []byte) error {
+	suffix := make([]byte, 8)
+	rand.Read(suffix)
+	tmp := path + ".tmp-" + hex.EncodeToString(suffix)
+	if err := writeFileSync(tmp, data, 0o644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func writeFileSync(path string, data []byte, perm fs.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,273 @@
+package install
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+
+	"orvalho/pkg/actor/manifest"
+	"orvalho/pkg/bundle"
+	"orvalho/pkg/identity"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// testSigner uses a fixed key so the tests are deterministic.
+var testSigner = func() ssh.Signer {
+	seed := make([]byte, ed25519.SeedSize)
+	signer, err := ssh.NewSignerFromKey(ed25519.NewKeyFromSeed(seed))
+	if err != nil {
+		panic(err)
+	}
+	return signer
+}()
+
+func testBundle(t *testing.T, version string, hosts ...string) *bundle.Bundle {
+	t.Helper()
+	m := &manifest.Manifest{
+		Name:    "dev.example.photos",
+		Version: version,
+		Actors: []manifest.Actor{{
+			Name:    "web",
+			Runtime: manifest.RuntimeJS,
+			Entry:   "main.js",
+			Capabilities: manifest.CapabilitySet{
+				Network: &manifest.NetworkCapability{Hosts: hosts},
+			},
+		}},
+	}
+	b, err := bundle.New(m, fstest.MapFS{
+		"main.js":     {Data: []byte(`export const version = "` + version + `";`)},
+		"lib/util.js": {Data: []byte(`export default 1;`)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Sign(testSigner); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func openStore(t *testing.T, dir string, onSwitch func(from, to *Installed)) *Store {
+	t.Helper()
+	s, err := Open(Config{Dir: dir, Keyring: bundle.NewKeyring(testSigner.PublicKey()), OnSwitch: onSwitch})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func approveAll([]manifest.Change) bool { return true }
+
+func TestInstall(t *testing.T) {
+	s := openStore(t, t.TempDir(), nil)
+
+	if _, err := s.Install(testBundle(t, "1.0.0", "api.example.com"), nil); !errors.Is(err, ErrDenied) {
+		t.Errorf("installing without approving permissions should fail, got %v", err)
+	}
+
+	var asked []manifest.Change
+	inst, err := s.Install(testBundle(t, "1.0.0", "api.example.com"), func(changes []manifest.Change) bool {
+		asked = changes
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(asked) != 1 || asked[0].Permission != "network:api.example.com" {
+		t.Errorf("unexpected permission prompt %v", asked)
+	}
+	if got := inst.Granted["web"]; !slices.Equal(got, []string{"network:api.example.com"}) {
+		t.Errorf("unexpected granted permissions %v", got)
+	}
+	if inst.Signer != ssh.FingerprintSHA256(testSigner.PublicKey()) {
+		t.Errorf("unexpected signer %q", inst.Signer)
+	}
+
+	data, err := os.ReadFile(filepath.Join(inst.Dir, "lib", "util.js"))
+	if err != nil || string(data) != `export default 1;` {
+		t.Errorf("bundle files were not unpacked: %q, %v", data, err)
+	}
+	m, err := inst.Manifest()
+	if err != nil || m.Version != "1.0.0" {
+		t.Errorf("unexpected installed manifest %+v, %v", m, err)
+	}
+
+	names, err := s.List()
+	if err != nil || !slices.Equal(names, []string{"dev.example.photos"}) {
+		t.Errorf("unexpected package list %v, %v", names, err)
+	}
+	if _, err := s.Current("dev.example.other"); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("expected ErrNotInstalled, got %v", err)
+	}
+}
+
+func TestInstallRejectsUntrustedBundles(t *testing.T) {
+	s := openStore(t, t.TempDir(), nil)
+
+	unsigned, err := bundle.New(testBundle(t, "1.0.0").Manifest, fstest.MapFS{"main.js": {Data: []byte("1")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Install(unsigned, approveAll); !errors.Is(err, bundle.ErrUnsigned) {
+		t.Errorf("expected ErrUnsigned, got %v", err)
+	}
+
+	dev, err := Open(Config{Dir: t.TempDir(), AllowUnsigned: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dev.Install(unsigned, approveAll); err != nil {
+		t.Errorf("AllowUnsigned should accept unsigned bundles: %v", err)
+	}
+}
+
+func TestManagerKeyIsTrustedByDefault(t *testing.T) {
+	id, err := identity.DeriveIdentities("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := id.Signer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(Config{Dir: t.TempDir()}); err == nil {
+		t.Error("a store trusting no one should not open without AllowUnsigned")
+	}
+	s, err := Open(Config{Dir: t.TempDir(), ManagerKey: id.SSHPublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := testBundle(t, "1.0.0")
+	if _, err := s.Install(b, approveAll); !errors.Is(err, bundle.ErrUnknownSigner) {
+		t.Errorf("expected ErrUnknownSigner for another publisher, got %v", err)
+	}
+	if err := b.Sign(signer); err != nil {
+		t.Fatal(err)
+	}
+	inst, err := s.Install(b, approveAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.Signer != ssh.FingerprintSHA256(signer.PublicKey()) {
+		t.Errorf("unexpected signer %q", inst.Signer)
+	}
+}
+
+func TestUpgradeAndRollback(t *testing.T) {
+	var switches []string
+	s := openStore(t, t.TempDir(), func(from, to *Installed) {
+		name := "none"
+		if from != nil {
+			name = from.Version
+		}
+		switches = append(switches, name+"->"+to.Version)
+	})
+
+	v1, err := s.Install(testBundle(t, "1.0.0", "api.example.com"), approveAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Denying the new permission leaves everything as it was.
+	if _, err := s.Install(testBundle(t, "2.0.0", "api.example.com", "upload.example.com"), func(changes []manifest.Change) bool {
+		if len(changes) != 1 || changes[0].Permission != "network:upload.example.com" {
+			t.Errorf("only the new permission should be asked for, got %v", changes)
+		}
+		return false
+	}); !errors.Is(err, ErrDenied) {
+		t.Fatalf("expected ErrDenied, got %v", err)
+	}
+	if cur, _ := s.Current(v1.Name); cur.Version != "1.0.0" {
+		t.Errorf("a denied upgrade changed the current version to %s", cur.Version)
+	}
+
+	// Upgrades that need no new permission do not prompt.
+	v2, err := s.Install(testBundle(t, "2.0.0"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev, _ := s.Previous(v1.Name); prev.Version != "1.0.0" {
+		t.Errorf("expected 1.0.0 to be kept for rollback, got %s", prev.Version)
+	}
+
+	// Reinstalling the current version is a no-op.
+	if _, err := s.Install(testBundle(t, "2.0.0"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	back, err := s.Rollback(v1.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Version != "1.0.0" || back.Dir != v1.Dir {
+		t.Errorf("rollback switched to %s in %s", back.Version, back.Dir)
+	}
+	if _, err := os.Stat(v2.Dir); err != nil {
+		t.Errorf("the rolled back version should be kept: %v", err)
+	}
+
+	// A third version drops the oldest one.
+	v3, err := s.Install(testBundle(t, "3.0.0", "api.example.com"), approveAll)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(v2.Dir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", v2.Dir, err)
+	}
+	if _, err := os.Stat(v3.Dir); err != nil {
+		t.Error(err)
+	}
+
+	want := []string{"none->1.0.0", "1.0.0->2.0.0", "2.0.0->1.0.0", "1.0.0->3.0.0"}
+	if !slices.Equal(switches, want) {
+		t.Errorf("expected switches %v, got %v", want, switches)
+	}
+
+	if _, err := openStore(t, t.TempDir(), nil).Rollback(v1.Name); !errors.Is(err, ErrNotInstalled) {
+		t.Errorf("expected ErrNotInstalled, got %v", err)
+	}
+}
+
+func TestOpenRecoversFromCrash(t *testing.T) {
+	dir := t.TempDir()
+	s := openStore(t, dir, nil)
+	v1, err := s.Install(testBundle(t, "1.0.0"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Leave behind what a crash in the middle of an upgrade would.
+	pkgDir := filepath.Dir(v1.Dir)
+	leftovers := []string{
+		filepath.Join(dir, "tmp", "unpack-123", "main.js"),
+		filepath.Join(pkgDir, "2.0.0_0123456789ab", "main.js"),
+		filepath.Join(pkgDir, "state.json.tmp-42"),
+	}
+	for _, path := range leftovers {
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte("half"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s = openStore(t, dir, nil)
+	for _, path := range leftovers {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be cleaned up, got %v", path, err)
+		}
+	}
+	cur, err := s.Current(v1.Name)
+	if err != nil || cur.Version != "1.0.0" {
+		t.Errorf("expected 1.0.0 to survive, got %+v, %v", cur, err)
+	}
+	if _, err := os.Stat(filepath.Join(cur.Dir, "main.js")); err != nil {
+		t.Error(err)
+	}
+}